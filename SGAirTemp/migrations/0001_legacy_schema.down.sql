@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS readings;
+DROP TABLE IF EXISTS stations;
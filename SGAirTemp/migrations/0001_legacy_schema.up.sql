@@ -0,0 +1,3 @@
+-- The schema of the first version: Date/Time split in TEXT columns, latitude/longitude as TEXT.
+CREATE TABLE IF NOT EXISTS stations (station_id TEXT PRIMARY KEY, station_name TEXT, loc_latitude TEXT, loc_longitude TEXT);
+CREATE TABLE IF NOT EXISTS readings (station_id TEXT, yr TEXT, mo TEXT, dt TEXT, hr TEXT, mi TEXT, value REAL);
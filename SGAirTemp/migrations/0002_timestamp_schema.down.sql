@@ -0,0 +1,18 @@
+ALTER TABLE readings RENAME TO readings_ts;
+CREATE TABLE readings (station_id TEXT, yr TEXT, mo TEXT, dt TEXT, hr TEXT, mi TEXT, value REAL);
+INSERT INTO readings (station_id, yr, mo, dt, hr, mi, value)
+	SELECT station_id,
+		strftime('%Y', ts + 28800, 'unixepoch'),
+		strftime('%m', ts + 28800, 'unixepoch'),
+		strftime('%d', ts + 28800, 'unixepoch'),
+		strftime('%H', ts + 28800, 'unixepoch'),
+		strftime('%M', ts + 28800, 'unixepoch'),
+		value
+	FROM readings_ts;
+DROP TABLE readings_ts;
+
+ALTER TABLE stations RENAME TO stations_real;
+CREATE TABLE stations (station_id TEXT PRIMARY KEY, station_name TEXT, loc_latitude TEXT, loc_longitude TEXT);
+INSERT INTO stations (station_id, station_name, loc_latitude, loc_longitude)
+	SELECT station_id, station_name, printf('%.5f', loc_latitude), printf('%.5f', loc_longitude) FROM stations_real;
+DROP TABLE stations_real;
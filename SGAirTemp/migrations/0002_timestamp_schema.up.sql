@@ -0,0 +1,20 @@
+-- readings.ts is the Unix time (UTC) of the reading and readings.ts_sgt is the same time in SGT as returned by the API (RFC3339).
+-- The legacy Date/Time columns are in SGT (UTC+8).
+ALTER TABLE stations RENAME TO stations_legacy;
+CREATE TABLE stations (station_id TEXT PRIMARY KEY, station_name TEXT NOT NULL, loc_latitude REAL, loc_longitude REAL);
+INSERT OR IGNORE INTO stations (station_id, station_name, loc_latitude, loc_longitude)
+	SELECT station_id, IFNULL(station_name, ''), CAST(loc_latitude AS REAL), CAST(loc_longitude AS REAL) FROM stations_legacy;
+DROP TABLE stations_legacy;
+
+ALTER TABLE readings RENAME TO readings_legacy;
+CREATE TABLE readings (station_id TEXT NOT NULL, ts INTEGER NOT NULL, ts_sgt TEXT NOT NULL, value REAL NOT NULL, PRIMARY KEY (station_id, ts)) WITHOUT ROWID;
+INSERT OR IGNORE INTO readings (station_id, ts, ts_sgt, value)
+	SELECT station_id,
+		CAST(strftime('%s', yr || '-' || mo || '-' || dt || ' ' || hr || ':' || mi || ':00') AS INTEGER) - 28800,
+		yr || '-' || mo || '-' || dt || 'T' || hr || ':' || mi || ':00+08:00',
+		value
+	FROM readings_legacy WHERE value IS NOT NULL;
+DROP TABLE readings_legacy;
+
+-- Covering index for the date range queries of all stations.
+CREATE INDEX idx_readings_ts ON readings (ts, station_id, value);
@@ -0,0 +1,10 @@
+-- The ledger of the backfill command, one row per day (SGT) fetched from the API.
+CREATE TABLE backfill_jobs (
+	job_date TEXT PRIMARY KEY,
+	status TEXT NOT NULL CHECK (status IN ('pending', 'done', 'failed', 'empty')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	total_readings INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	updated_at TEXT NOT NULL
+);
+CREATE INDEX idx_backfill_jobs_status ON backfill_jobs (status, job_date);
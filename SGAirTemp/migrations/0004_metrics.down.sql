@@ -0,0 +1,21 @@
+-- Only the air-temperature readings and backfill days are kept.
+ALTER TABLE readings RENAME TO readings_metric;
+CREATE TABLE readings (station_id TEXT NOT NULL, ts INTEGER NOT NULL, ts_sgt TEXT NOT NULL, value REAL NOT NULL, PRIMARY KEY (station_id, ts)) WITHOUT ROWID;
+INSERT INTO readings (station_id, ts, ts_sgt, value)
+	SELECT station_id, ts, ts_sgt, value FROM readings_metric WHERE metric = 'air-temperature';
+DROP TABLE readings_metric;
+CREATE INDEX idx_readings_ts ON readings (ts, station_id, value);
+
+ALTER TABLE backfill_jobs RENAME TO backfill_jobs_metric;
+CREATE TABLE backfill_jobs (
+	job_date TEXT PRIMARY KEY,
+	status TEXT NOT NULL CHECK (status IN ('pending', 'done', 'failed', 'empty')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	total_readings INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	updated_at TEXT NOT NULL
+);
+INSERT INTO backfill_jobs (job_date, status, attempts, total_readings, last_error, updated_at)
+	SELECT job_date, status, attempts, total_readings, last_error, updated_at FROM backfill_jobs_metric WHERE metric = 'air-temperature';
+DROP TABLE backfill_jobs_metric;
+CREATE INDEX idx_backfill_jobs_status ON backfill_jobs (status, job_date);
@@ -0,0 +1,24 @@
+-- readings.metric is the measurement type (the API endpoint name), the readings saved before are all air-temperature.
+ALTER TABLE readings RENAME TO readings_temperature;
+CREATE TABLE readings (metric TEXT NOT NULL, station_id TEXT NOT NULL, ts INTEGER NOT NULL, ts_sgt TEXT NOT NULL, value REAL NOT NULL, PRIMARY KEY (metric, station_id, ts)) WITHOUT ROWID;
+INSERT INTO readings (metric, station_id, ts, ts_sgt, value)
+	SELECT 'air-temperature', station_id, ts, ts_sgt, value FROM readings_temperature;
+DROP TABLE readings_temperature;
+CREATE INDEX idx_readings_ts ON readings (metric, ts, station_id, value);
+
+-- The backfill ledger is per metric too.
+ALTER TABLE backfill_jobs RENAME TO backfill_jobs_temperature;
+CREATE TABLE backfill_jobs (
+	metric TEXT NOT NULL,
+	job_date TEXT NOT NULL,
+	status TEXT NOT NULL CHECK (status IN ('pending', 'done', 'failed', 'empty')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	total_readings INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	updated_at TEXT NOT NULL,
+	PRIMARY KEY (metric, job_date)
+);
+INSERT INTO backfill_jobs (metric, job_date, status, attempts, total_readings, last_error, updated_at)
+	SELECT 'air-temperature', job_date, status, attempts, total_readings, last_error, updated_at FROM backfill_jobs_temperature;
+DROP TABLE backfill_jobs_temperature;
+CREATE INDEX idx_backfill_jobs_status ON backfill_jobs (metric, status, job_date);
@@ -0,0 +1,2 @@
+DROP TABLE region_readings;
+DROP TABLE regions;
@@ -0,0 +1,5 @@
+-- The region based air quality readings (PSI and PM2.5), the region label_location is used to map the stations to the nearest region.
+CREATE TABLE regions (region TEXT PRIMARY KEY, loc_latitude REAL NOT NULL, loc_longitude REAL NOT NULL);
+-- region_readings.reading_type is the key of the API readings, ie: psi_twenty_four_hourly or pm25_one_hourly.
+CREATE TABLE region_readings (reading_type TEXT NOT NULL, region TEXT NOT NULL, ts INTEGER NOT NULL, ts_sgt TEXT NOT NULL, value REAL NOT NULL, PRIMARY KEY (reading_type, region, ts)) WITHOUT ROWID;
+CREATE INDEX idx_region_readings_ts ON region_readings (reading_type, ts, region, value);
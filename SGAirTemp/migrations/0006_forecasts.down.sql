@@ -0,0 +1,3 @@
+DROP INDEX idx_forecasts_valid;
+DROP TABLE forecasts;
+DROP TABLE forecast_areas;
@@ -0,0 +1,22 @@
+-- Every issuance of the 2-hour, 24-hour and 4-day weather forecasts, one row per area (or region, or national) and valid period.
+-- forecasts.issued_ts is the Unix time of the forecast issuance, valid_from/valid_to are the Unix time of its valid period (valid_to excluded).
+-- The temperature, relative humidity and wind are only given by the 24-hour (national) and 4-day forecasts, NULL for the others.
+CREATE TABLE forecast_areas (area TEXT PRIMARY KEY, loc_latitude REAL NOT NULL, loc_longitude REAL NOT NULL);
+CREATE TABLE forecasts (
+	source TEXT NOT NULL,
+	issued_ts INTEGER NOT NULL,
+	issued_sgt TEXT NOT NULL,
+	area TEXT NOT NULL,
+	valid_from INTEGER NOT NULL,
+	valid_to INTEGER NOT NULL,
+	forecast TEXT NOT NULL,
+	temp_low REAL,
+	temp_high REAL,
+	humidity_low REAL,
+	humidity_high REAL,
+	wind_speed_low REAL,
+	wind_speed_high REAL,
+	wind_direction TEXT,
+	PRIMARY KEY (source, issued_ts, area, valid_from)
+) WITHOUT ROWID;
+CREATE INDEX idx_forecasts_valid ON forecasts (source, valid_from);
@@ -0,0 +1,3 @@
+DROP INDEX idx_heat_stress_ts;
+DROP TABLE heat_stress;
+DELETE FROM readings WHERE metric = 'wbgt';
@@ -0,0 +1,10 @@
+-- The heat stress category of the WBGT (Wet Bulb Globe Temperature) readings, the WBGT value itself is on readings (metric wbgt).
+-- heat_stress.station_id refers to the same stations table, ts is the Unix time of the reading.
+CREATE TABLE heat_stress (
+	station_id TEXT NOT NULL,
+	ts INTEGER NOT NULL,
+	ts_sgt TEXT NOT NULL,
+	category TEXT NOT NULL CHECK (category IN ('low', 'moderate', 'high')),
+	PRIMARY KEY (station_id, ts)
+) WITHOUT ROWID;
+CREATE INDEX idx_heat_stress_ts ON heat_stress (ts, station_id, category);
@@ -0,0 +1,314 @@
+package SGAirTemp
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Filter struct - which saved readings are used by the aggregation. Empty field means no filter for that field.
+type Filter struct {
+	//DateFrom - first date (YYYY-MM-DD) included.
+	DateFrom string
+	//DateTo - last date (YYYY-MM-DD) included.
+	DateTo string
+	//Hours - the hours (0-23) of the reading.
+	Hours []int
+	//Minutes - the minutes (0-59) of the reading, []int{0} for the hourly readings.
+	Minutes []int
+	//StationIDs - the stations of the reading.
+	StationIDs []string
+	//Metric - the Metric name of the reading, empty for the DefaultMetric (or the Metric of the DB).
+	//The derived Metric uses the air temperature readings (r) with the relative humidity (rh) of the same station and time.
+	Metric string
+}
+
+//whereClause - the Where clause (with the leading WHERE) of the readings (r) for the filter and its arguments.
+//The dates, hours and minutes are in SGT, converted to the range/expression of the Unix time readings.ts
+func (f Filter) whereClause() (string, []interface{}, error) {
+	//Flexible array, by using splices
+	WhereCondition := []string{"r.metric = ?"}
+	args := []interface{}{DefaultMetric.Name}
+	if IsDerivedMetric(f.Metric) {
+		args[0] = MetricAirTemperature.Name
+		if condition := derivedCondition(f.Metric); len(condition) > 0 {
+			WhereCondition = append(WhereCondition, condition)
+		}
+	} else if len(f.Metric) > 0 {
+		args[0] = f.Metric
+	}
+
+	if len(f.DateFrom) > 0 {
+		DateFrom, err := sgtDayStart(f.DateFrom)
+		if err != nil {
+			return "", args, err
+		}
+		WhereCondition = append(WhereCondition, "r.ts >= ?")
+		args = append(args, DateFrom.Unix())
+	}
+	if len(f.DateTo) > 0 {
+		DateTo, err := sgtDayStart(f.DateTo)
+		if err != nil {
+			return "", args, err
+		}
+		//Until the end of the day, before the next day 00:00
+		WhereCondition = append(WhereCondition, "r.ts < ?")
+		args = append(args, DateTo.AddDate(0, 0, 1).Unix())
+	}
+	if len(f.Hours) > 0 {
+		WhereCondition = append(WhereCondition, fmt.Sprintf("((r.ts + %v) / 3600) %% 24 IN (%v)", sgtOffsetSeconds, placeholders(len(f.Hours))))
+		for _, h := range f.Hours {
+			args = append(args, h)
+		}
+	}
+	if len(f.Minutes) > 0 {
+		WhereCondition = append(WhereCondition, fmt.Sprintf("(r.ts / 60) %% 60 IN (%v)", placeholders(len(f.Minutes))))
+		for _, m := range f.Minutes {
+			args = append(args, m)
+		}
+	}
+	if len(f.StationIDs) > 0 {
+		WhereCondition = append(WhereCondition, fmt.Sprintf("r.station_id IN (%v)", placeholders(len(f.StationIDs))))
+		for _, id := range f.StationIDs {
+			args = append(args, id)
+		}
+	}
+
+	return fmt.Sprintf(" WHERE %v ", strings.Join(WhereCondition[:], " AND ")), args, nil
+}
+
+//sgtDayStart - the date (YYYY-MM-DD) 00:00 in SGT.
+func sgtDayStart(strDate string) (time.Time, error) {
+	dayStart, err := time.ParseInLocation(strStandardFormat, strDate, SGTLocation)
+	if err != nil {
+		return dayStart, &InputError{Value: strDate, Message: "please use YYYY-MM-DD format", Err: ErrInvalidDateFormat}
+	}
+	return dayStart, nil
+}
+
+//readingsFrom - the readings (r) of the filter, joined with the relative humidity (rh) of the same station and time for the derived Metric.
+func (f Filter) readingsFrom() string {
+	if IsDerivedMetric(f.Metric) {
+		return fmt.Sprintf("readings r INNER JOIN readings rh ON rh.metric = '%v' AND rh.station_id = r.station_id AND rh.ts = r.ts", MetricRelativeHumidity.Name)
+	}
+	return "readings r"
+}
+
+//valueColumns - the value (and the relative humidity, NULL if not derived) columns of the readingsFrom.
+func (f Filter) valueColumns() string {
+	if IsDerivedMetric(f.Metric) {
+		return "r.value, rh.value"
+	}
+	return "r.value, NULL"
+}
+
+//valueOrder - the SQL order of the readings by the value, then the station name and time.
+//The derived value is computed after the query, so only the station name and time are ordered, the same order as the readings of the same value.
+func (f Filter) valueOrder() string {
+	if IsDerivedMetric(f.Metric) {
+		return "s.station_name, r.ts"
+	}
+	return "r.value, s.station_name, r.ts"
+}
+
+//dbFilter - the filter on the Metric of the DB if the filter has no Metric.
+func (dbc *DB) dbFilter(filter Filter) Filter {
+	if len(filter.Metric) == 0 {
+		filter.Metric = dbc.metric().Name
+	}
+	return filter
+}
+
+//Page struct - the LIMIT and OFFSET of the query, zero Limit for all the rows.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+//sql - the LIMIT clause of the page, empty for all the rows.
+func (p Page) sql() string {
+	if p.Limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", p.Limit, p.Offset)
+}
+
+//queryReadings - call fn for every reading matching the filter (on the page), with its station, in the SQL order (ie: "r.value, s.station_name, r.ts").
+//The value of the derived Metric is computed from the temperature and humidity, the reading it can't be computed for is skipped.
+//The query stops on the first error returned by fn, and that error is returned.
+func (dbc *DB) queryReadings(filter Filter, orderBy string, page Page, fn func(StationID, StationName string, ts int64, value float64) error) error {
+	var StationID string
+	var StationName string
+	var ts int64
+	var value float64
+	var humidity sql.NullFloat64
+
+	filter = dbc.dbFilter(filter)
+	whereCondSQL, args, err := filter.whereClause()
+	if err != nil {
+		return err
+	}
+	rows, err := dbc.Query(fmt.Sprintf("SELECT r.station_id, s.station_name, r.ts, %v FROM %v INNER JOIN stations s ON s.station_id = r.station_id %v ORDER BY %v%v",
+		filter.valueColumns(), filter.readingsFrom(), whereCondSQL, orderBy, page.sql()), args...)
+	if err != nil {
+		return dbError("query readings", err)
+	}
+	defer rows.Close()
+
+	derived := IsDerivedMetric(filter.Metric)
+	for rows.Next() {
+		if err := rows.Scan(&StationID, &StationName, &ts, &value, &humidity); err != nil {
+			return dbError("query readings", err)
+		}
+		if derived == true {
+			var ok bool
+			if value, ok = derivedValue(filter.Metric, value, humidity.Float64); ok == false {
+				continue
+			}
+		}
+		if err := fn(StationID, StationName, ts, value); err != nil {
+			return err
+		}
+	}
+	return dbError("query readings", rows.Err())
+}
+
+//placeholders - "?, ?, ?" for n arguments.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+//Aggregator struct - calculate the count, mean, median and min/max with the occurrences from the readings added one by one.
+type Aggregator struct {
+	values []float64
+	total  float64
+	stats  Statistics
+}
+
+//NewAggregator - create an empty Aggregator.
+func NewAggregator() *Aggregator {
+	return &Aggregator{values: []float64{}}
+}
+
+//Add - add one reading to the aggregation, readings with the same value as the min/max are kept as another occurrence.
+func (agg *Aggregator) Add(value float64, occ Occurrence) {
+	if len(agg.values) == 0 || value > agg.stats.Max {
+		agg.stats.Max = value
+		agg.stats.MaxOccurrences = []Occurrence{occ}
+	} else if value == agg.stats.Max {
+		agg.stats.MaxOccurrences = append(agg.stats.MaxOccurrences, occ)
+	}
+
+	if len(agg.values) == 0 || value < agg.stats.Min {
+		agg.stats.Min = value
+		agg.stats.MinOccurrences = []Occurrence{occ}
+	} else if value == agg.stats.Min {
+		agg.stats.MinOccurrences = append(agg.stats.MinOccurrences, occ)
+	}
+
+	agg.total += value
+	agg.values = append(agg.values, value)
+}
+
+//Result - the Statistics of all the added readings.
+func (agg *Aggregator) Result() Statistics {
+	stats := agg.stats
+	stats.Count = len(agg.values)
+	if stats.Count > 0 {
+		stats.Mean = agg.total / float64(stats.Count)
+		stats.Median = median(agg.values)
+	}
+	return stats
+}
+
+//median - the middle value, or the average of the two middle values when the total is even.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := values
+	if sort.Float64sAreSorted(values) == false {
+		sorted = append([]float64{}, values...)
+		sort.Float64s(sorted)
+	}
+	centerData := len(sorted) / 2
+	//Is Even, so we need to get the average of the two of the center data
+	if len(sorted)%2 == 0 {
+		return (sorted[centerData-1] + sorted[centerData]) / 2
+	}
+	//Is ODD, just get the center/middle data
+	return sorted[centerData]
+}
+
+//Aggregate - calculate the statistic of the saved (or derived) readings matching the filter.
+func (dbc *DB) Aggregate(filter Filter) (stats Statistics, err error) {
+	StartExecutionTime := time.Now()
+	agg := NewAggregator()
+	err = dbc.queryReadings(filter, dbc.dbFilter(filter).valueOrder(), Page{}, func(StationID, StationName string, ts int64, value float64) error {
+		agg.Add(value, Occurrence{StationID: StationID, StationName: StationName, Timestamp: time.Unix(ts, 0).In(SGTLocation)})
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	stats = agg.Result()
+	stats.Elapsed = time.Since(StartExecutionTime)
+	return stats, nil
+}
+
+//CountReadingsByStation - the total saved readings matching the filter for every station ID.
+func (dbc *DB) CountReadingsByStation(filter Filter) (map[string]int, error) {
+	var StationID string
+	var cnt int
+	counts := map[string]int{}
+
+	filter = dbc.dbFilter(filter)
+	whereCondSQL, args, err := filter.whereClause()
+	if err != nil {
+		return counts, err
+	}
+	rows, err := dbc.Query(fmt.Sprintf("SELECT r.station_id, count(r.value) AS cnt FROM %v %v GROUP BY r.station_id", filter.readingsFrom(), whereCondSQL), args...)
+	if err != nil {
+		return counts, dbError("count readings", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&StationID, &cnt); err != nil {
+			return counts, dbError("count readings", err)
+		}
+		counts[StationID] = cnt
+	}
+	return counts, dbError("count readings", rows.Err())
+}
+
+//CountReadings - the total saved readings matching the filter.
+func (dbc *DB) CountReadings(filter Filter) (int, error) {
+	counts, err := dbc.CountReadingsByStation(filter)
+	total := 0
+	for _, cnt := range counts {
+		total += cnt
+	}
+	return total, err
+}
+
+//StationReading struct - one reading of the Metric with its station.
+type StationReading struct {
+	StationID   string
+	StationName string
+	Timestamp   time.Time
+	Value       float64
+}
+
+//Readings - the readings matching the filter on the page, ordered by the station name and the time.
+func (dbc *DB) Readings(filter Filter, page Page) ([]StationReading, error) {
+	readings := []StationReading{}
+	err := dbc.queryReadings(filter, "s.station_name, r.ts", page, func(StationID, StationName string, ts int64, value float64) error {
+		readings = append(readings, StationReading{StationID: StationID, StationName: StationName, Timestamp: time.Unix(ts, 0).In(SGTLocation), Value: value})
+		return nil
+	})
+	return readings, err
+}
@@ -0,0 +1,256 @@
+package SGAirTemp
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//openTestDB - the empty Sqlite database in the temporary directory of the test, closed when the test ends.
+func openTestDB(t testing.TB) *DB {
+	t.Helper()
+	dbc, err := InitDBConn("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbc.Close() })
+	return dbc
+}
+
+//newTestDB - the openTestDB with all the migrations applied.
+func newTestDB(t testing.TB) *DB {
+	t.Helper()
+	dbc := openTestDB(t)
+	if err := dbc.PrepareDBTable(); err != nil {
+		t.Fatal(err)
+	}
+	return dbc
+}
+
+//sgtTime - the time of the date and HH:mm in SGT.
+func sgtTime(t testing.TB, dateTime string) time.Time {
+	t.Helper()
+	ts, err := time.ParseInLocation("2006-01-02 15:04", dateTime, SGTLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+//saveTestReading - save the station (named after its ID) and its reading of the metric at the SGT date and HH:mm.
+func saveTestReading(t testing.TB, dbc *DB, metric Metric, StationID, dateTime string, value float64) {
+	t.Helper()
+	ts := sgtTime(t, dateTime)
+	if _, err := dbc.Exec(sqlUpsertStation, StationID, "Station "+StationID, 1.35, 103.8); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbc.Exec(sqlUpsertReading, metric.Name, StationID, ts.Unix(), ts.Format(time.RFC3339), value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAggregatorAdd(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         []float64
+		min, max       float64
+		minOccurrences int
+		maxOccurrences int
+	}{
+		{"single reading is both min and max", []float64{27.5}, 27.5, 27.5, 1, 1},
+		{"repeated min and max", []float64{30, 25, 30, 28, 25, 30}, 25, 30, 2, 3},
+		{"all the same value", []float64{26, 26, 26}, 26, 26, 3, 3},
+		{"new min resets the occurrences", []float64{25, 25, 24}, 24, 25, 1, 2},
+		{"negative values", []float64{-1.5, -3, -1.5}, -3, -1.5, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := NewAggregator()
+			start := sgtTime(t, "2024-05-01 00:00")
+			for i, value := range tt.values {
+				agg.Add(value, Occurrence{StationID: "S1", Timestamp: start.Add(time.Duration(i) * time.Minute)})
+			}
+			stats := agg.Result()
+			if stats.Count != len(tt.values) {
+				t.Errorf("Count = %v, want %v", stats.Count, len(tt.values))
+			}
+			if stats.Min != tt.min || stats.Max != tt.max {
+				t.Errorf("Min/Max = %v/%v, want %v/%v", stats.Min, stats.Max, tt.min, tt.max)
+			}
+			if len(stats.MinOccurrences) != tt.minOccurrences || len(stats.MaxOccurrences) != tt.maxOccurrences {
+				t.Errorf("occurrences of Min/Max = %v/%v, want %v/%v", len(stats.MinOccurrences), len(stats.MaxOccurrences), tt.minOccurrences, tt.maxOccurrences)
+			}
+			//The occurrences are kept in the order they were added.
+			for i := 1; i < len(stats.MaxOccurrences); i++ {
+				if stats.MaxOccurrences[i].Timestamp.Before(stats.MaxOccurrences[i-1].Timestamp) {
+					t.Errorf("MaxOccurrences not in the order added: %v", stats.MaxOccurrences)
+				}
+			}
+		})
+	}
+}
+
+func TestAggregatorEmpty(t *testing.T) {
+	stats := NewAggregator().Result()
+	if stats.Count != 0 || stats.Mean != 0 || stats.Median != 0 || len(stats.MinOccurrences) != 0 {
+		t.Errorf("empty Result = %+v, want the zero statistic", stats)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"empty", []float64{}, 0},
+		{"single", []float64{27}, 27},
+		{"odd sorted", []float64{1, 2, 3}, 2},
+		{"odd unsorted", []float64{3, 1, 2}, 2},
+		{"even sorted", []float64{1, 2, 3, 4}, 2.5},
+		{"even unsorted", []float64{4, 1, 3, 2}, 2.5},
+		{"even with ties", []float64{5, 5, 1, 9}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := append([]float64{}, tt.values...)
+			if got := median(values); got != tt.want {
+				t.Errorf("median(%v) = %v, want %v", tt.values, got, tt.want)
+			}
+			//The unsorted values of the caller are not sorted in place.
+			for i := range values {
+				if values[i] != tt.values[i] {
+					t.Fatalf("median changed the values to %v", values)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterWhereClauseSGTDay(t *testing.T) {
+	_, args, err := Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01"}.whereClause()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//2024-05-01 00:00 SGT is 2024-04-30 16:00 UTC, until the next day 00:00 SGT (excluded).
+	wantFrom := time.Date(2024, 4, 30, 16, 0, 0, 0, time.UTC).Unix()
+	want := []interface{}{DefaultMetric.Name, wantFrom, wantFrom + 24*60*60}
+	if len(args) != len(want) {
+		t.Fatalf("args = %v, want %v", args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Errorf("args[%v] = %v, want %v", i, args[i], want[i])
+		}
+	}
+
+	_, _, err = Filter{DateFrom: "01-05-2024"}.whereClause()
+	if errors.Is(err, ErrInvalidDateFormat) == false {
+		t.Errorf("invalid DateFrom error = %v, want ErrInvalidDateFormat", err)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	dbc := newTestDB(t)
+	metric := MetricAirTemperature
+	//The readings just outside the SGT day are not counted.
+	saveTestReading(t, dbc, metric, "S1", "2024-04-30 23:59", 40)
+	saveTestReading(t, dbc, metric, "S1", "2024-05-01 00:00", 25)
+	saveTestReading(t, dbc, metric, "S1", "2024-05-01 10:00", 30)
+	saveTestReading(t, dbc, metric, "S2", "2024-05-01 10:00", 30)
+	saveTestReading(t, dbc, metric, "S2", "2024-05-01 10:30", 27)
+	saveTestReading(t, dbc, metric, "S2", "2024-05-01 23:59", 26)
+	saveTestReading(t, dbc, metric, "S2", "2024-05-02 00:00", 10)
+	//The other metric of the same time is not counted.
+	saveTestReading(t, dbc, MetricRelativeHumidity, "S1", "2024-05-01 10:00", 80)
+
+	tests := []struct {
+		name           string
+		filter         Filter
+		count          int
+		min, max       float64
+		maxOccurrences int
+	}{
+		{"every minute of the SGT day", Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01"}, 5, 25, 30, 2},
+		{"hourly readings only", Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01", Minutes: []int{0}}, 3, 25, 30, 2},
+		{"hour 10 SGT", Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01", Hours: []int{10}}, 3, 27, 30, 2},
+		{"one station", Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01", StationIDs: []string{"S2"}}, 3, 26, 30, 1},
+		{"every saved reading", Filter{}, 7, 10, 40, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := dbc.Aggregate(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Count != tt.count || stats.Min != tt.min || stats.Max != tt.max || len(stats.MaxOccurrences) != tt.maxOccurrences {
+				t.Errorf("Count/Min/Max/MaxOccurrences = %v/%v/%v/%v, want %v/%v/%v/%v", stats.Count, stats.Min, stats.Max, len(stats.MaxOccurrences),
+					tt.count, tt.min, tt.max, tt.maxOccurrences)
+			}
+			total, err := dbc.CountReadings(tt.filter)
+			if err != nil || total != tt.count {
+				t.Errorf("CountReadings = %v, %v, want %v", total, err, tt.count)
+			}
+		})
+	}
+
+	//The occurrence is in SGT, with its station name.
+	stats, err := dbc.Aggregate(Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if occ := stats.MinOccurrences[0]; occ.StationName != "Station S1" || occ.Timestamp.Format("2006-01-02 15:04 -0700") != "2024-05-01 00:00 +0800" {
+		t.Errorf("MinOccurrences[0] = %+v, want Station S1 at 2024-05-01 00:00 SGT", occ)
+	}
+}
+
+func TestDerivedMetricPage(t *testing.T) {
+	dbc := newTestDB(t)
+	//Every other minute has 0% humidity, the dew point can't be computed for it.
+	for m := 0; m < 10; m++ {
+		dateTime := fmt.Sprintf("2024-05-01 10:%02d", m)
+		saveTestReading(t, dbc, MetricAirTemperature, "S1", dateTime, 30)
+		saveTestReading(t, dbc, MetricRelativeHumidity, "S1", dateTime, float64(m%2)*80)
+	}
+
+	tests := []struct {
+		metric Metric
+		total  int
+	}{
+		{MetricDewPoint, 5},
+		{MetricHumidex, 5},
+		{MetricHeatIndex, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric.Name, func(t *testing.T) {
+			filter := Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01", Metric: tt.metric.Name}
+			total, err := dbc.CountReadings(filter)
+			if err != nil || total != tt.total {
+				t.Fatalf("CountReadings = %v, %v, want %v", total, err, tt.total)
+			}
+			//Every page is full until the total, the rows not computed are not counted by the LIMIT.
+			seen := 0
+			for offset := 0; offset < total; offset += 2 {
+				readings, err := dbc.Readings(filter, Page{Limit: 2, Offset: offset})
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := 2
+				if total-offset < want {
+					want = total - offset
+				}
+				if len(readings) != want {
+					t.Errorf("page at offset %v has %v readings, want %v", offset, len(readings), want)
+				}
+				seen += len(readings)
+			}
+			if seen != total {
+				t.Errorf("%v readings on the pages, want the total %v", seen, total)
+			}
+		})
+	}
+}
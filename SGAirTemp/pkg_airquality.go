@@ -0,0 +1,326 @@
+package SGAirTemp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+//AirQualitySource struct - an air quality API endpoint (environment/NAME), it reports per region instead of per station.
+type AirQualitySource struct {
+	Name  string
+	Label string
+}
+
+//The air quality APIs.
+var (
+	SourcePSI  = AirQualitySource{Name: "psi", Label: "PSI"}
+	SourcePM25 = AirQualitySource{Name: "pm25", Label: "PM2.5"}
+)
+
+//AirQualitySources - all the supported air quality APIs.
+func AirQualitySources() []AirQualitySource {
+	return []AirQualitySource{SourcePSI, SourcePM25}
+}
+
+//AirQualitySourceByName - the supported air quality API with the name.
+func AirQualitySourceByName(name string) (AirQualitySource, error) {
+	names := []string{}
+	for _, src := range AirQualitySources() {
+		if src.Name == strings.TrimSpace(name) {
+			return src, nil
+		}
+		names = append(names, src.Name)
+	}
+	return AirQualitySource{}, &InputError{Value: name, Message: "please choose " + strings.Join(names, ", "), Err: ErrUnknownMetric}
+}
+
+//airQualityReadingTypes - the label and unit of the main reading types, the other reading types of the API are saved too.
+var airQualityReadingTypes = []Metric{
+	{Name: "psi_twenty_four_hourly", Label: "24-hour PSI", Unit: "index"},
+	{Name: "pm25_one_hourly", Label: "1-hour PM2.5", Unit: "ug/m3"},
+	{Name: "pm25_twenty_four_hourly", Label: "24-hour PM2.5", Unit: "ug/m3"},
+	{Name: "pm10_twenty_four_hourly", Label: "24-hour PM10", Unit: "ug/m3"},
+}
+
+//DefaultReadingType - the air quality reading type used when it is not chosen.
+const DefaultReadingType = "psi_twenty_four_hourly"
+
+//AirQualityReadingTypes - all the reading types of the PSI and PM2.5 API, saved on the region_readings.
+func AirQualityReadingTypes() []string {
+	return []string{"psi_twenty_four_hourly", "pm25_one_hourly", "pm25_twenty_four_hourly", "pm10_twenty_four_hourly",
+		"pm25_sub_index", "pm10_sub_index", "o3_sub_index", "so2_sub_index", "co_sub_index",
+		"o3_eight_hour_max", "co_eight_hour_max", "no2_one_hour_max", "so2_twenty_four_hourly"}
+}
+
+//CheckReadingType - the air quality reading type, DefaultReadingType for the empty name.
+func CheckReadingType(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return DefaultReadingType, nil
+	}
+	for _, readingType := range AirQualityReadingTypes() {
+		if readingType == name {
+			return readingType, nil
+		}
+	}
+	return "", &InputError{Value: name, Message: "please choose " + strings.Join(AirQualityReadingTypes(), ", "), Err: ErrUnknownMetric}
+}
+
+//NationalRegion - the region of the whole Singapore, it is not used to map the stations.
+const NationalRegion = "national"
+
+//DefaultRegions - the label_location of the regions returned by the API, used to map the stations when no region is saved yet.
+var DefaultRegions = []RegionMetadata{
+	{Name: "central", LabelLocation: Location{Latitude: 1.35735, Longitude: 103.82}},
+	{Name: "east", LabelLocation: Location{Latitude: 1.35735, Longitude: 103.94}},
+	{Name: "north", LabelLocation: Location{Latitude: 1.41803, Longitude: 103.82}},
+	{Name: "south", LabelLocation: Location{Latitude: 1.29587, Longitude: 103.82}},
+	{Name: "west", LabelLocation: Location{Latitude: 1.35735, Longitude: 103.7}},
+}
+
+//RegionMetadata struct - the region and its label location.
+type RegionMetadata struct {
+	Name          string   `json:"name"`
+	LabelLocation Location `json:"label_location"`
+}
+
+//RegionData struct - the readings of every reading type for every region at the timestamp: readings[reading type][region].
+type RegionData struct {
+	Timestamp       string                        `json:"timestamp"`
+	UpdateTimestamp string                        `json:"update_timestamp"`
+	Readings        map[string]map[string]float64 `json:"readings"`
+}
+
+//RegionResponse struct - response body of the air quality API.
+type RegionResponse struct {
+	RegionMetadata []RegionMetadata `json:"region_metadata"`
+	Items          []RegionData     `json:"items"`
+}
+
+//GetRegionReadings - call the air quality API for the Date (YYYY-MM-DD) and Time (HH:mm), empty Time for the FULL day.
+func (c *APIClient) GetRegionReadings(ctx context.Context, source AirQualitySource, ValDate, ValTime string) (response RegionResponse, err error) {
+	err = c.getEndpoint(ctx, source.Name, ValDate, ValTime, &response)
+	return response, err
+}
+
+//CallAirQualityAPIAndSave - call the air quality API and save the regions and readings to the Database, return the total readings saved.
+func (dbc *DB) CallAirQualityAPIAndSave(ctx context.Context, source AirQualitySource, ValDate, ValTime string) (int, error) {
+	response, err := dbc.apiClient().GetRegionReadings(ctx, source, ValDate, ValTime)
+	if err != nil {
+		return 0, err
+	}
+	return dbc.SaveRegionResponse(response)
+}
+
+//SaveRegionResponse - save the regions and readings of the air quality API response in a single transaction, return the total readings saved.
+func (dbc *DB) SaveRegionResponse(response RegionResponse) (int, error) {
+	tx, err := dbc.Begin()
+	if err != nil {
+		return 0, dbError("begin ingestion", err)
+	}
+	defer tx.Rollback()
+
+	totalSaved, err := saveRegionResponse(tx, response)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, dbError("commit ingestion", err)
+	}
+	return totalSaved, nil
+}
+
+//saveRegionResponse - upsert the regions and readings of the response on the transaction.
+func saveRegionResponse(tx *sql.Tx, response RegionResponse) (totalSaved int, err error) {
+	for _, rg := range response.RegionMetadata {
+		if _, err := tx.Exec("INSERT INTO regions (region, loc_latitude, loc_longitude) VALUES (?, ?, ?) "+
+			"ON CONFLICT (region) DO UPDATE SET loc_latitude = excluded.loc_latitude, loc_longitude = excluded.loc_longitude",
+			rg.Name, rg.LabelLocation.Latitude, rg.LabelLocation.Longitude); err != nil {
+			return 0, dbError("upsert region", err)
+		}
+	}
+
+	stmtReading, err := tx.Prepare("INSERT INTO region_readings (reading_type, region, ts, ts_sgt, value) VALUES (?, ?, ?, ?, ?) " +
+		"ON CONFLICT (reading_type, region, ts) DO UPDATE SET ts_sgt = excluded.ts_sgt, value = excluded.value")
+	if err != nil {
+		return 0, dbError("prepare region reading upsert", err)
+	}
+	defer stmtReading.Close()
+
+	for _, RegionData := range response.Items {
+		ts, err := time.Parse(time.RFC3339, RegionData.Timestamp)
+		if err != nil {
+			return 0, &InputError{Value: RegionData.Timestamp, Message: "reading timestamp is not RFC3339", Err: ErrInvalidDateFormat}
+		}
+		tsSGT := ts.In(SGTLocation).Format(time.RFC3339)
+		for readingType, values := range RegionData.Readings {
+			for region, value := range values {
+				if _, err := stmtReading.Exec(readingType, region, ts.Unix(), tsSGT, value); err != nil {
+					return 0, dbError("upsert region reading", err)
+				}
+				totalSaved++
+			}
+		}
+	}
+	return totalSaved, nil
+}
+
+//RegionStatisticQuery struct - the reading type, date range (YYYY-MM-DD, empty for no bound) and region (empty for all) of the air quality statistic.
+type RegionStatisticQuery struct {
+	ReadingType string
+	DateFrom    string
+	DateTo      string
+	Region      string
+}
+
+//GetRegionStatistics - calculate the statistic of the saved air quality readings, the Occurrence StationID/StationName is the region.
+func (dbc *DB) GetRegionStatistics(query RegionStatisticQuery) (stats Statistics, err error) {
+	var region string
+	var ts int64
+	var value float64
+
+	StartExecutionTime := time.Now()
+	if query.ReadingType, err = CheckReadingType(query.ReadingType); err != nil {
+		return stats, err
+	}
+	WhereCondition := []string{"reading_type = ?"}
+	args := []interface{}{query.ReadingType}
+	if len(query.DateFrom) > 0 {
+		DateFrom, err := sgtDayStart(query.DateFrom)
+		if err != nil {
+			return stats, err
+		}
+		WhereCondition = append(WhereCondition, "ts >= ?")
+		args = append(args, DateFrom.Unix())
+	}
+	if len(query.DateTo) > 0 {
+		DateTo, err := sgtDayStart(query.DateTo)
+		if err != nil {
+			return stats, err
+		}
+		WhereCondition = append(WhereCondition, "ts < ?")
+		args = append(args, DateTo.AddDate(0, 0, 1).Unix())
+	}
+	if len(query.Region) > 0 {
+		WhereCondition = append(WhereCondition, "region = ?")
+		args = append(args, query.Region)
+	}
+
+	rows, err := dbc.Query(fmt.Sprintf("SELECT region, ts, value FROM region_readings WHERE %v ORDER BY value, region, ts", strings.Join(WhereCondition, " AND ")), args...)
+	if err != nil {
+		return stats, dbError("query region statistic", err)
+	}
+	defer rows.Close()
+
+	agg := NewAggregator()
+	for rows.Next() {
+		if err := rows.Scan(&region, &ts, &value); err != nil {
+			return stats, dbError("query region statistic", err)
+		}
+		agg.Add(value, Occurrence{StationID: region, StationName: region, Timestamp: time.Unix(ts, 0).In(SGTLocation)})
+	}
+	if err := rows.Err(); err != nil {
+		return stats, dbError("query region statistic", err)
+	}
+
+	stats = agg.Result()
+	stats.Query = StatisticQuery{DateFrom: query.DateFrom, DateTo: query.DateTo, StationID: query.Region, Granularity: GranularityMinute, Metric: query.ReadingType}
+	stats.Elapsed = time.Since(StartExecutionTime)
+	return stats, nil
+}
+
+//StationRegion struct - the region of the Station, the region with the nearest label location.
+type StationRegion struct {
+	StationID   string
+	StationName string
+	Region      string
+	DistanceKm  float64
+}
+
+//Regions - the saved regions (without the NationalRegion), DefaultRegions if nothing is saved yet.
+func (dbc *DB) Regions() ([]RegionMetadata, error) {
+	regions := []RegionMetadata{}
+	rows, err := dbc.Query("SELECT region, loc_latitude, loc_longitude FROM regions WHERE region <> ? ORDER BY region", NationalRegion)
+	if err != nil {
+		return regions, dbError("query regions", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rg := RegionMetadata{}
+		if err := rows.Scan(&rg.Name, &rg.LabelLocation.Latitude, &rg.LabelLocation.Longitude); err != nil {
+			return regions, dbError("query regions", err)
+		}
+		regions = append(regions, rg)
+	}
+	if err := rows.Err(); err != nil {
+		return regions, dbError("query regions", err)
+	}
+	if len(regions) == 0 {
+		regions = DefaultRegions
+	}
+	return regions, nil
+}
+
+//StationRegions - the region of every saved Station, ordered by the region and the station name.
+func (dbc *DB) StationRegions() ([]StationRegion, error) {
+	stationRegions := []StationRegion{}
+	regions, err := dbc.Regions()
+	if err != nil {
+		return stationRegions, err
+	}
+
+	rows, err := dbc.Query("SELECT station_id, station_name, IFNULL(loc_latitude, 0), IFNULL(loc_longitude, 0) FROM stations")
+	if err != nil {
+		return stationRegions, dbError("query stations", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		st := Station{}
+		if err := rows.Scan(&st.StationID, &st.StationName, &st.Location.Latitude, &st.Location.Longitude); err != nil {
+			return stationRegions, dbError("query stations", err)
+		}
+		region, distance := NearestRegion(st.Location, regions)
+		stationRegions = append(stationRegions, StationRegion{StationID: st.StationID, StationName: st.StationName, Region: region, DistanceKm: distance})
+	}
+	if err := rows.Err(); err != nil {
+		return stationRegions, dbError("query stations", err)
+	}
+
+	sort.Slice(stationRegions, func(i, j int) bool {
+		if stationRegions[i].Region != stationRegions[j].Region {
+			return stationRegions[i].Region < stationRegions[j].Region
+		}
+		return stationRegions[i].StationName < stationRegions[j].StationName
+	})
+	return stationRegions, nil
+}
+
+//NearestRegion - the region with the nearest label location and the distance (km), the NationalRegion is skipped.
+func NearestRegion(loc Location, regions []RegionMetadata) (string, float64) {
+	nearest := ""
+	minDistance := math.Inf(1)
+	for _, rg := range regions {
+		if rg.Name == NationalRegion {
+			continue
+		}
+		if distance := haversineKm(loc, rg.LabelLocation); distance < minDistance {
+			nearest, minDistance = rg.Name, distance
+		}
+	}
+	return nearest, minDistance
+}
+
+//haversineKm - the great circle distance between the two locations in km.
+func haversineKm(a, b Location) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(b.Latitude - a.Latitude)
+	dLong := toRad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(a.Latitude))*math.Cos(toRad(b.Latitude))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,40 @@
+package SGAirTemp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckReadingType(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{"", DefaultReadingType, nil},
+		{"pm25_one_hourly", "pm25_one_hourly", nil},
+		{" o3_sub_index ", "o3_sub_index", nil},
+		{"psi", "", ErrUnknownMetric},
+		{"PM25_ONE_HOURLY", "", ErrUnknownMetric},
+	}
+	for _, tt := range tests {
+		got, err := CheckReadingType(tt.name)
+		if got != tt.want || errors.Is(err, tt.wantErr) == false {
+			t.Errorf("CheckReadingType(%q) = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+		var inputErr *InputError
+		if tt.wantErr != nil && errors.As(err, &inputErr) == false {
+			t.Errorf("CheckReadingType(%q) error = %T, want *InputError", tt.name, err)
+		}
+	}
+}
+
+func TestGetRegionStatisticsReadingType(t *testing.T) {
+	dbc := newTestDB(t)
+	if _, err := dbc.GetRegionStatistics(RegionStatisticQuery{ReadingType: "psi_hourly"}); errors.Is(err, ErrUnknownMetric) == false {
+		t.Errorf("unknown reading type error = %v, want ErrUnknownMetric", err)
+	}
+	if _, err := dbc.GetRegionStatistics(RegionStatisticQuery{}); err != nil {
+		t.Errorf("default reading type error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,237 @@
+package SGAirTemp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//The default of the APIClient.
+const (
+	DefaultAPIBaseURL    = "https://api.data.gov.sg/v1"
+	DefaultAPIV2BaseURL  = "https://api-open.data.gov.sg/v2"
+	DefaultAPITimeout    = 30 * time.Second
+	DefaultAPIMaxRetries = 4
+	DefaultAPIBaseDelay  = 500 * time.Millisecond
+	DefaultAPIMaxDelay   = 30 * time.Second
+)
+
+//APIClient struct - call the data.gov.sg API, retrying the temporary failure.
+//The network error, 429 (Too Many Requests) and 5xx are retried up to MaxRetries times, waiting with exponential backoff and jitter
+//starting from BaseDelay until MaxDelay, or the Retry-After returned by the API (even longer than MaxDelay).
+//BaseURL is the API version root, ie: DefaultAPIBaseURL or the URL of the MockServer, V2BaseURL is the root of the v2 API (ie: WBGT).
+type APIClient struct {
+	BaseURL    string
+	V2BaseURL  string
+	HTTPClient *http.Client
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	//Store - every response is saved to the Store (nil to disable), and the saved response of the past date is used instead of calling the API.
+	Store *ResponseStore
+	//Refresh - always call the API, even if the response is on the Store.
+	Refresh bool
+}
+
+//NewAPIClient - create the APIClient with the timeout for every request (0 for no timeout) and the total retries.
+func NewAPIClient(timeout time.Duration, maxRetries int) *APIClient {
+	return &APIClient{
+		BaseURL:    DefaultAPIBaseURL,
+		V2BaseURL:  DefaultAPIV2BaseURL,
+		HTTPClient: &http.Client{Timeout: timeout},
+		MaxRetries: maxRetries,
+		BaseDelay:  DefaultAPIBaseDelay,
+		MaxDelay:   DefaultAPIMaxDelay,
+	}
+}
+
+//DefaultAPIClient - used by APICallAndGetResponse and the DB without its own API client.
+var DefaultAPIClient = NewAPIClient(DefaultAPITimeout, DefaultAPIMaxRetries)
+
+//GetReadings - call the API of the metric for the Date (YYYY-MM-DD) and Time (HH:mm), empty Time for the FULL day.
+func (c *APIClient) GetReadings(ctx context.Context, metric Metric, ValDate, ValTime string) (response WeatherResponse, err error) {
+	err = c.getEndpoint(ctx, metric.Name, ValDate, ValTime, &response)
+	return response, err
+}
+
+//getEndpoint - call the API endpoint (environment/ENDPOINT) for the Date (YYYY-MM-DD) and Time (HH:mm), and parse the JSON to the response.
+func (c *APIClient) getEndpoint(ctx context.Context, endpoint, ValDate, ValTime string, response interface{}) error {
+	dateTimeCondition := ""
+	if len(ValDate) > 0 && len(ValTime) > 0 {
+		dateTimeCondition = fmt.Sprintf("date_time=%v", url.QueryEscape(ValDate+"T"+ValTime+":00"))
+	} else if len(ValDate) > 0 {
+		dateTimeCondition = fmt.Sprintf("date=%v", url.QueryEscape(ValDate))
+	} else {
+		return &InputError{Message: "please provide Date and Time", Err: ErrInvalidDateFormat}
+	}
+	//String API Call, we only need to get the data until the minute level.
+	query := "environment/" + endpoint + "?" + dateTimeCondition
+	strAPICall := c.baseURL() + "/" + query
+	body, statusCode, err := c.getStored(ctx, query, strAPICall, ValDate)
+	if err != nil {
+		return err
+	}
+
+	if isEmptyBody(body) {
+		return &APIError{URL: strAPICall, StatusCode: statusCode, Kind: ErrAPIEmptyBody}
+	}
+
+	//All fine, Unmarshal the response from the API Response Body - Parsing
+	if err := json.Unmarshal(body, response); err != nil {
+		return &APIError{URL: strAPICall, StatusCode: statusCode, Kind: ErrAPIUnavailable, Err: err}
+	}
+	return nil
+}
+
+//isEmptyBody - API couldn't provide data.
+//Sometimes the API don't have the data for that day, at least I found the date they can't provide data is: 2020-06-10 and 2020-06-11
+func isEmptyBody(body []byte) bool {
+	return len(body) <= 110
+}
+
+//getStored - the response of the past date from the Store, otherwise call the API and save the response of the past date to the Store.
+//The empty response is always called again, the API might have the data now.
+//The response of today (SGT) is never saved: the day is not complete yet, and it would be used as the complete day once the date is past.
+func (c *APIClient) getStored(ctx context.Context, query, strURL, ValDate string) ([]byte, int, error) {
+	if c.Store == nil {
+		return c.get(ctx, strURL)
+	}
+
+	isPastDate := ValDate < time.Now().In(SGTLocation).Format(strStandardFormat)
+	if c.Refresh == false && isPastDate {
+		stored, ok, err := c.Store.Get(query)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok == true && isEmptyBody(stored.Body) == false {
+			return stored.Body, http.StatusOK, nil
+		}
+	}
+
+	body, statusCode, err := c.get(ctx, strURL)
+	if err != nil || isPastDate == false || json.Valid(body) == false {
+		return body, statusCode, err
+	}
+	if err := c.Store.Put(query, body); err != nil {
+		return nil, statusCode, fmt.Errorf("save the raw response of %v: %w", strURL, err)
+	}
+	return body, statusCode, nil
+}
+
+//baseURL - the BaseURL without the trailing slash, DefaultAPIBaseURL if it is empty.
+func (c *APIClient) baseURL() string {
+	if len(c.BaseURL) == 0 {
+		return DefaultAPIBaseURL
+	}
+	return strings.TrimRight(c.BaseURL, "/")
+}
+
+//v2BaseURL - the V2BaseURL without the trailing slash, DefaultAPIV2BaseURL if it is empty.
+func (c *APIClient) v2BaseURL() string {
+	if len(c.V2BaseURL) == 0 {
+		return DefaultAPIV2BaseURL
+	}
+	return strings.TrimRight(c.V2BaseURL, "/")
+}
+
+//get - the body of the 200 response, retrying the temporary failure until MaxRetries or the ctx is done.
+func (c *APIClient) get(ctx context.Context, strURL string) ([]byte, int, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	for attempt := 1; ; attempt++ {
+		body, statusCode, retryAfter, err := c.getOnce(ctx, httpClient, strURL)
+		if err == nil && statusCode == http.StatusOK {
+			return body, statusCode, nil
+		}
+
+		apiErr := &APIError{URL: strURL, StatusCode: statusCode, Kind: ErrAPIUnavailable, Err: err, Attempts: attempt}
+		if ctx.Err() != nil || isRetryable(statusCode, err) == false || attempt > c.MaxRetries {
+			return nil, statusCode, apiErr
+		}
+
+		//The backoff is capped by the MaxDelay, the Retry-After is waited as given (until the ctx is done).
+		delay := c.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			apiErr.Err = ctx.Err()
+			return nil, statusCode, apiErr
+		}
+	}
+}
+
+//getOnce - one request, the body is only read for 200 status code, otherwise the Retry-After is returned (if any).
+func (c *APIClient) getOnce(ctx context.Context, httpClient *http.Client, strURL string) (body []byte, statusCode int, retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strURL, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, res.StatusCode, parseRetryAfter(res.Header.Get("Retry-After")), nil
+	}
+
+	//Read the response body
+	body, err = ioutil.ReadAll(res.Body)
+	return body, res.StatusCode, 0, err
+}
+
+//isRetryable - the network error (including the request timeout), 429 and 5xx might be fine on the next try.
+func isRetryable(statusCode int, err error) bool {
+	if err != nil {
+		return true
+	}
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+//backoff - the exponential delay of the attempt (BaseDelay, 2x BaseDelay, 4x BaseDelay, ...) with jitter between half and the full delay.
+func (c *APIClient) backoff(attempt int) time.Duration {
+	delay := c.BaseDelay
+	for i := 1; i < attempt && (c.MaxDelay <= 0 || delay < c.MaxDelay); i++ {
+		delay *= 2
+	}
+	if c.MaxDelay > 0 && delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+//parseRetryAfter - the Retry-After header, in seconds or HTTP date, 0 if it is not provided or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if retryTime, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(retryTime); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
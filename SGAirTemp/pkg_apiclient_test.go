@@ -0,0 +1,166 @@
+package SGAirTemp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//newMockClient - the APIClient calling the MockHandler on a local server (closed when the test ends), retrying without waiting long.
+func newMockClient(t *testing.T, opts MockOptions) (*APIClient, *MockHandler) {
+	t.Helper()
+	handler := NewMockHandler(opts)
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", http.StripPrefix("/v1", handler))
+	mux.Handle("/v2/", http.StripPrefix("/v2", handler))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := NewAPIClient(5*time.Second, 2)
+	client.BaseURL = server.URL + "/v1"
+	client.V2BaseURL = server.URL + "/v2"
+	client.BaseDelay = time.Millisecond
+	client.MaxDelay = 2 * time.Second
+	return client, handler
+}
+
+func TestAPIClientGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     MockOptions
+		requests int
+		status   int
+	}{
+		{"give up after MaxRetries on 5xx", MockOptions{ErrorDates: []string{"2024-05-01"}}, 3, http.StatusInternalServerError},
+		{"retry 503", MockOptions{ErrorDates: []string{"2024-05-01"}, ErrorStatus: http.StatusServiceUnavailable}, 3, http.StatusServiceUnavailable},
+		{"no retry on 4xx", MockOptions{ErrorDates: []string{"2024-05-01"}, ErrorStatus: http.StatusNotFound}, 1, http.StatusNotFound},
+		{"give up after MaxRetries on 429", MockOptions{RateLimitEvery: 1}, 3, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, handler := newMockClient(t, tt.opts)
+			_, err := client.GetReadings(context.Background(), MetricAirTemperature, "2024-05-01", "12:00")
+
+			var apiErr *APIError
+			if errors.As(err, &apiErr) == false || errors.Is(err, ErrAPIUnavailable) == false {
+				t.Fatalf("error = %v, want the APIError of ErrAPIUnavailable", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Attempts != tt.requests {
+				t.Errorf("StatusCode/Attempts = %v/%v, want %v/%v", apiErr.StatusCode, apiErr.Attempts, tt.status, tt.requests)
+			}
+			if handler.Requests() != tt.requests {
+				t.Errorf("%v requests served, want %v", handler.Requests(), tt.requests)
+			}
+		})
+	}
+}
+
+func TestAPIClientRetryAfter(t *testing.T) {
+	//Every 2nd request is answered with 429 and Retry-After: 1, longer than the MaxDelay.
+	client, handler := newMockClient(t, MockOptions{RateLimitEvery: 2})
+	client.MaxDelay = 10 * time.Millisecond
+	if _, err := client.GetReadings(context.Background(), MetricAirTemperature, "2024-05-01", "12:00"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	response, err := client.GetReadings(context.Background(), MetricAirTemperature, "2024-05-01", "12:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//The backoff of the BaseDelay is only 1ms, the Retry-After is waited instead, not capped by the MaxDelay.
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the Retry-After of 1s", elapsed)
+	}
+	if handler.Requests() != 3 || len(response.Items) != 1 {
+		t.Errorf("%v requests served and %v items, want 3 and 1", handler.Requests(), len(response.Items))
+	}
+}
+
+func TestAPIClientCancelDuringRetryAfter(t *testing.T) {
+	client, handler := newMockClient(t, MockOptions{RateLimitEvery: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetReadings(ctx, MetricAirTemperature, "2024-05-01", "12:00")
+	if errors.Is(err, context.DeadlineExceeded) == false {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	//The Retry-After of 1s is only bounded by the ctx.
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("returned after %v, want right after the ctx is done", elapsed)
+	}
+	if handler.Requests() != 1 {
+		t.Errorf("%v requests served, want 1", handler.Requests())
+	}
+}
+
+func TestAPIClientCancelDuringBackoff(t *testing.T) {
+	client, handler := newMockClient(t, MockOptions{ErrorDates: []string{"2024-05-01"}})
+	client.BaseDelay = time.Minute
+	client.MaxDelay = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetReadings(ctx, MetricAirTemperature, "2024-05-01", "12:00")
+	if errors.Is(err, context.DeadlineExceeded) == false || errors.Is(err, ErrAPIUnavailable) == false {
+		t.Errorf("error = %v, want ErrAPIUnavailable of context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %v, want right after the ctx is done", elapsed)
+	}
+	if handler.Requests() != 1 {
+		t.Errorf("%v requests served, want 1", handler.Requests())
+	}
+}
+
+func TestAPIClientBackoff(t *testing.T) {
+	client := &APIClient{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{60, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		//The jitter is random, try a few times.
+		for i := 0; i < 20; i++ {
+			if delay := client.backoff(tt.attempt); delay < tt.min || delay > tt.max {
+				t.Errorf("backoff(%v) = %v, want between %v and %v", tt.attempt, delay, tt.min, tt.max)
+				break
+			}
+		}
+	}
+	if delay := (&APIClient{}).backoff(3); delay != 0 {
+		t.Errorf("backoff without BaseDelay = %v, want 0", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"0", 0, 0},
+		{"-1", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 59 * time.Minute, time.Hour},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want between %v and %v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package SGAirTemp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+//JobStatus - the status of a backfill day on the backfill_jobs ledger.
+type JobStatus string
+
+const (
+	//JobPending - the day is not fetched yet, or the fetch was interrupted.
+	JobPending JobStatus = "pending"
+	//JobDone - the readings of the day are saved.
+	JobDone JobStatus = "done"
+	//JobFailed - the API or the response failed, it will be retried until BackfillOptions.MaxAttempts.
+	JobFailed JobStatus = "failed"
+	//JobEmpty - the API has no data for the day, ie: 2020-06-10 and 2020-06-11. Not retried unless BackfillOptions.RetryEmpty.
+	JobEmpty JobStatus = "empty"
+)
+
+//BackfillJob struct - one day of the Metric on the backfill_jobs ledger.
+type BackfillJob struct {
+	Metric        string
+	Date          string
+	Status        JobStatus
+	Attempts      int
+	TotalReadings int
+	LastError     string
+	UpdatedAt     string
+}
+
+//BackfillOptions struct - the date range (YYYY-MM-DD) and the retry behaviour of the backfill.
+type BackfillOptions struct {
+	DateFrom string
+	DateTo   string
+	//RetryEmpty - fetch again the days known as empty.
+	RetryEmpty bool
+	//MaxAttempts - the failed day is not retried anymore after this total attempts, 0 for no limit.
+	MaxAttempts int
+	//Progress - called after every day is fetched, can be nil.
+	Progress func(job BackfillJob)
+}
+
+//BackfillSummary struct - the result of the backfill run.
+type BackfillSummary struct {
+	Done       int
+	Skipped    int
+	EmptyDays  []string
+	FailedDays []string
+}
+
+//Backfill - fetch the FULL day of readings (of the DB Metric) for every day in the range which is not done yet, recording the status of each day on the backfill_jobs ledger.
+//The days are fetched concurrently by the DB Scheduler.
+//The status is saved after every day, so the interrupted (or ctx cancelled) backfill continue from the days not done when it is run again.
+func (dbc *DB) Backfill(ctx context.Context, opts BackfillOptions) (summary BackfillSummary, err error) {
+	if opts.DateFrom, err = CheckInputDate(opts.DateFrom); err != nil {
+		return summary, err
+	}
+	if opts.DateTo, err = CheckInputDate(opts.DateTo); err != nil {
+		return summary, err
+	}
+	if ValidateInputDateMaxYesterday(opts.DateTo) == false {
+		return summary, errNotLaterThanYesterday(opts.DateTo)
+	}
+	if opts.DateFrom > opts.DateTo {
+		return summary, &InputError{Value: opts.DateFrom, Message: "must be not later than " + opts.DateTo, Err: ErrInvalidDate}
+	}
+
+	metric := dbc.metric()
+	if IsDerivedMetric(metric.Name) {
+		return summary, errDerivedMetric(metric)
+	}
+	if err := dbc.addPendingBackfillJobs(metric.Name, opts.DateFrom, opts.DateTo); err != nil {
+		return summary, err
+	}
+
+	jobs, err := dbc.BackfillJobs(metric.Name, opts.DateFrom, opts.DateTo)
+	if err != nil {
+		return summary, err
+	}
+
+	//The days to fetch, the FULL day for each.
+	requests := []FetchRequest{}
+	jobByDate := map[string]BackfillJob{}
+	for _, job := range jobs {
+		switch {
+		case job.Status == JobDone:
+			summary.Skipped++
+			continue
+		case job.Status == JobEmpty && opts.RetryEmpty == false:
+			summary.EmptyDays = append(summary.EmptyDays, job.Date)
+			continue
+		case job.Status == JobFailed && opts.MaxAttempts > 0 && job.Attempts >= opts.MaxAttempts:
+			summary.FailedDays = append(summary.FailedDays, job.Date)
+			continue
+		}
+		requests = append(requests, FetchRequest{Metric: metric, Date: job.Date})
+		jobByDate[job.Date] = job
+	}
+
+	scheduler := dbc.Scheduler
+	if scheduler == nil {
+		scheduler = NewFetchScheduler(DefaultFetchWorkers, DefaultRequestsPerSecond)
+	}
+	//The ledger is updated by the Scheduler writer right after the readings of the day are saved, in its own statement (not the transaction of the readings).
+	//If the run stops in between, the day stays pending and is fetched again on the next run, the readings are upserted so nothing is duplicated.
+	err = scheduler.Run(ctx, dbc, requests, func(result FetchResult) error {
+		job := backfillJobResult(jobByDate[result.Request.Date], result)
+		if err := dbc.saveBackfillJob(job); err != nil {
+			return err
+		}
+		switch job.Status {
+		case JobDone:
+			summary.Done++
+		case JobEmpty:
+			summary.EmptyDays = append(summary.EmptyDays, job.Date)
+		case JobFailed:
+			summary.FailedDays = append(summary.FailedDays, job.Date)
+		}
+		if opts.Progress != nil {
+			opts.Progress(job)
+		}
+		return nil
+	})
+	sort.Strings(summary.EmptyDays)
+	sort.Strings(summary.FailedDays)
+	return summary, err
+}
+
+//backfillJobResult - the job status from the result of its FULL day fetch.
+func backfillJobResult(job BackfillJob, result FetchResult) BackfillJob {
+	job.Attempts++
+	job.TotalReadings = result.TotalSaved
+	job.LastError = ""
+
+	switch {
+	case result.Err == nil:
+		job.Status = JobDone
+	case errors.Is(result.Err, ErrAPIEmptyBody):
+		job.Status = JobEmpty
+		job.LastError = result.Err.Error()
+	default:
+		job.Status = JobFailed
+		job.LastError = result.Err.Error()
+	}
+	return job
+}
+
+//addPendingBackfillJobs - add every day of the range not on the ledger of the metric yet as pending.
+func (dbc *DB) addPendingBackfillJobs(metricName, DateFrom, DateTo string) error {
+	tx, err := dbc.Begin()
+	if err != nil {
+		return dbError("begin backfill jobs", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO backfill_jobs (metric, job_date, status, updated_at) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return dbError("prepare backfill jobs", err)
+	}
+	defer stmt.Close()
+
+	updatedAt := time.Now().UTC().Format(time.RFC3339)
+	jobDate, _ := time.Parse(strStandardFormat, DateFrom)
+	for strJobDate := DateFrom; strJobDate <= DateTo; strJobDate = jobDate.Format(strStandardFormat) {
+		if _, err := stmt.Exec(metricName, strJobDate, JobPending, updatedAt); err != nil {
+			return dbError("add backfill job", err)
+		}
+		jobDate = jobDate.AddDate(0, 0, 1)
+	}
+	return dbError("commit backfill jobs", tx.Commit())
+}
+
+//saveBackfillJob - record the status of the job on the ledger.
+func (dbc *DB) saveBackfillJob(job BackfillJob) error {
+	job.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	_, err := dbc.Exec("INSERT INTO backfill_jobs (metric, job_date, status, attempts, total_readings, last_error, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?) "+
+		"ON CONFLICT (metric, job_date) DO UPDATE SET status = excluded.status, attempts = excluded.attempts, total_readings = excluded.total_readings, last_error = excluded.last_error, updated_at = excluded.updated_at",
+		job.Metric, job.Date, job.Status, job.Attempts, job.TotalReadings, job.LastError, job.UpdatedAt)
+	return dbError("save backfill job", err)
+}
+
+//BackfillJobs - the days of the metric on the ledger within the range (YYYY-MM-DD), empty date for no limit, ordered by the date.
+func (dbc *DB) BackfillJobs(metricName, DateFrom, DateTo string) ([]BackfillJob, error) {
+	jobs := []BackfillJob{}
+	if len(DateFrom) == 0 {
+		DateFrom = "0000-00-00"
+	}
+	if len(DateTo) == 0 {
+		DateTo = "9999-99-99"
+	}
+
+	rows, err := dbc.Query("SELECT metric, job_date, status, attempts, total_readings, last_error, updated_at FROM backfill_jobs WHERE metric = ? AND job_date BETWEEN ? AND ? ORDER BY job_date", metricName, DateFrom, DateTo)
+	if err != nil {
+		return jobs, dbError("query backfill jobs", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		job := BackfillJob{}
+		if err := rows.Scan(&job.Metric, &job.Date, &job.Status, &job.Attempts, &job.TotalReadings, &job.LastError, &job.UpdatedAt); err != nil {
+			return jobs, dbError("query backfill jobs", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, dbError("query backfill jobs", rows.Err())
+}
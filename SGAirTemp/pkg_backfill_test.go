@@ -0,0 +1,167 @@
+package SGAirTemp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+//newBackfillDB - the test DB calling the MockHandler of the options with 2 workers, 2 stations per day.
+func newBackfillDB(t *testing.T, dbc *DB, opts MockOptions) *MockHandler {
+	t.Helper()
+	opts.Stations = 2
+	client, handler := newMockClient(t, opts)
+	dbc.API = client
+	dbc.Scheduler = NewFetchScheduler(2, 0)
+	return handler
+}
+
+//jobStatuses - the status and attempts of every day on the ledger of the air temperature.
+func jobStatuses(t *testing.T, dbc *DB) map[string]BackfillJob {
+	t.Helper()
+	jobs, err := dbc.BackfillJobs(MetricAirTemperature.Name, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]BackfillJob{}
+	for _, job := range jobs {
+		statuses[job.Date] = job
+	}
+	return statuses
+}
+
+func TestBackfillResumeAfterFailure(t *testing.T) {
+	dbc := newTestDB(t)
+	opts := BackfillOptions{DateFrom: "2020-06-08", DateTo: "2020-06-12"}
+
+	//2020-06-09 fails after the retries, 2020-06-10 and 2020-06-11 are empty like the real API.
+	handler := newBackfillDB(t, dbc, MockOptions{EmptyDates: []string{"2020-06-10", "2020-06-11"}, ErrorDates: []string{"2020-06-09"}, ErrorStatus: 502})
+	summary, err := dbc.Backfill(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BackfillSummary{Done: 2, EmptyDays: []string{"2020-06-10", "2020-06-11"}, FailedDays: []string{"2020-06-09"}}
+	if reflect.DeepEqual(summary, want) == false {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+	statuses := jobStatuses(t, dbc)
+	if job := statuses["2020-06-09"]; job.Status != JobFailed || job.Attempts != 1 || len(job.LastError) == 0 {
+		t.Errorf("job of the failed day = %+v, want failed after 1 attempt with its error", job)
+	}
+	if job := statuses["2020-06-08"]; job.Status != JobDone || job.Attempts != 1 || job.TotalReadings != 2*24*60 {
+		t.Errorf("job of the done day = %+v, want done with %v readings", job, 2*24*60)
+	}
+	if served := handler.Requests(); served != 5+2 {
+		t.Errorf("%v requests served, want the 5 days and the 2 retries of the failed day", served)
+	}
+
+	//The API is fine again: only the failed day is fetched, the done and empty days are skipped.
+	handler = newBackfillDB(t, dbc, MockOptions{EmptyDates: []string{"2020-06-10", "2020-06-11"}})
+	summary, err = dbc.Backfill(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = BackfillSummary{Done: 1, Skipped: 2, EmptyDays: []string{"2020-06-10", "2020-06-11"}}
+	if reflect.DeepEqual(summary, want) == false {
+		t.Errorf("summary of the second run = %+v, want %+v", summary, want)
+	}
+	if handler.Requests() != 1 {
+		t.Errorf("%v requests served on the second run, want only the failed day", handler.Requests())
+	}
+	if job := jobStatuses(t, dbc)["2020-06-09"]; job.Status != JobDone || job.Attempts != 2 || len(job.LastError) > 0 {
+		t.Errorf("job of the failed day after the second run = %+v, want done after 2 attempts", job)
+	}
+	if total, err := dbc.CountReadings(Filter{DateFrom: "2020-06-08", DateTo: "2020-06-12"}); err != nil || total != 3*2*24*60 {
+		t.Errorf("readings saved = %v, %v, want the 3 days with data", total, err)
+	}
+
+	//RetryEmpty fetches the empty days again.
+	handler = newBackfillDB(t, dbc, MockOptions{})
+	opts.RetryEmpty = true
+	summary, err = dbc.Backfill(context.Background(), opts)
+	if err != nil || summary.Done != 2 || summary.Skipped != 3 || len(summary.EmptyDays) != 0 || handler.Requests() != 2 {
+		t.Errorf("summary of RetryEmpty = %+v, %v after %v requests, want the 2 empty days done", summary, err, handler.Requests())
+	}
+}
+
+func TestBackfillMaxAttempts(t *testing.T) {
+	dbc := newTestDB(t)
+	opts := BackfillOptions{DateFrom: "2021-03-01", DateTo: "2021-03-02", MaxAttempts: 2}
+	for run := 1; run <= 3; run++ {
+		handler := newBackfillDB(t, dbc, MockOptions{ErrorDates: []string{"2021-03-02"}})
+		summary, err := dbc.Backfill(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reflect.DeepEqual(summary.FailedDays, []string{"2021-03-02"}) == false {
+			t.Errorf("run %v: failed days = %v, want 2021-03-02", run, summary.FailedDays)
+		}
+		//The third run doesn't call the API for the day failed MaxAttempts times.
+		wantRequests := 3
+		if run == 1 {
+			wantRequests = 1 + 3
+		} else if run == 3 {
+			wantRequests = 0
+		}
+		if handler.Requests() != wantRequests {
+			t.Errorf("run %v: %v requests served, want %v", run, handler.Requests(), wantRequests)
+		}
+	}
+	if job := jobStatuses(t, dbc)["2021-03-02"]; job.Status != JobFailed || job.Attempts != 2 {
+		t.Errorf("job of the failed day = %+v, want failed after 2 attempts", job)
+	}
+}
+
+func TestBackfillInterrupted(t *testing.T) {
+	dbc := newTestDB(t)
+	newBackfillDB(t, dbc, MockOptions{})
+	opts := BackfillOptions{DateFrom: "2022-01-01", DateTo: "2022-01-20"}
+
+	//Ctrl+C after the first day is saved.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts.Progress = func(job BackfillJob) { cancel() }
+	summary, err := dbc.Backfill(ctx, opts)
+	if errors.Is(err, context.Canceled) == false {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	pending := 0
+	for _, job := range jobStatuses(t, dbc) {
+		if job.Status == JobPending {
+			pending++
+		}
+	}
+	if summary.Done == 0 || summary.Done+pending != 20 {
+		t.Errorf("%v days done and %v pending, want the other days of the 20 pending", summary.Done, pending)
+	}
+
+	//The next run only fetches the pending days.
+	handler := newBackfillDB(t, dbc, MockOptions{})
+	opts.Progress = nil
+	done := summary.Done
+	summary, err = dbc.Backfill(context.Background(), opts)
+	if err != nil || summary.Done != pending || summary.Skipped != done || handler.Requests() != pending {
+		t.Errorf("summary of the next run = %+v, %v after %v requests, want %v done and %v skipped", summary, err, handler.Requests(), pending, done)
+	}
+}
+
+func TestBackfillInvalid(t *testing.T) {
+	dbc := newTestDB(t)
+	tests := []struct {
+		name    string
+		opts    BackfillOptions
+		wantErr error
+	}{
+		{"from after to", BackfillOptions{DateFrom: "2021-03-02", DateTo: "2021-03-01"}, ErrInvalidDate},
+		{"today", BackfillOptions{DateFrom: "2021-03-01", DateTo: ""}, ErrFutureDate},
+	}
+	for _, tt := range tests {
+		if _, err := dbc.Backfill(context.Background(), tt.opts); errors.Is(err, tt.wantErr) == false {
+			t.Errorf("%v: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if jobs, err := dbc.BackfillJobs(MetricAirTemperature.Name, "", ""); err != nil || len(jobs) != 0 {
+		t.Errorf("jobs = %+v, %v, want none added", jobs, err)
+	}
+}
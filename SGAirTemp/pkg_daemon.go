@@ -0,0 +1,376 @@
+package SGAirTemp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+//The default of the Daemon.
+const (
+	//DefaultPollInterval - the realtime API has a new air temperature reading every minute.
+	DefaultPollInterval = time.Minute
+	//DefaultGapThreshold - longer than the 5 minutes between the rainfall readings, and a few failed polls.
+	DefaultGapThreshold = 15 * time.Minute
+	//DefaultMaxGapDays - the gap is filled up to a week back, the older days are left to the backfill command.
+	DefaultMaxGapDays = 7
+)
+
+//Daemon struct - poll the latest readings of the Metrics from the API every Interval and save them, every API call is observed by the Exporter.
+//When the newest saved reading of the Metric is older than GapThreshold (ie: after a downtime), the FULL days since that reading are fetched instead,
+//up to MaxGapDays, and the past days are recorded as done on the backfill_jobs ledger.
+type Daemon struct {
+	DB           *DB
+	Metrics      []Metric
+	Interval     time.Duration
+	GapThreshold time.Duration
+	MaxGapDays   int
+	Exporter     *Exporter
+	//HeartbeatFile - the Heartbeat is written to the file after every poll, empty to disable.
+	HeartbeatFile string
+	//Log - the result of every poll is printed to Log, nil to discard.
+	Log io.Writer
+
+	heartbeat Heartbeat
+}
+
+//Heartbeat struct - the state of the Daemon written to the HeartbeatFile, to monitor the freshness of the readings.
+type Heartbeat struct {
+	PID       int       `json:"pid"`
+	Status    string    `json:"status"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	//ConsecutiveFailures - the polls in a row with a failed Metric, 0 after a successful poll.
+	ConsecutiveFailures int               `json:"consecutive_failures"`
+	Metrics             []MetricFreshness `json:"metrics"`
+}
+
+//MetricFreshness struct - the newest saved reading of the Metric and its last poll.
+type MetricFreshness struct {
+	Metric        string    `json:"metric"`
+	NewestReading time.Time `json:"newest_reading"`
+	LagSeconds    float64   `json:"lag_seconds"`
+	LastSuccess   time.Time `json:"last_success"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+//The Status of the Heartbeat.
+const (
+	HeartbeatRunning = "running"
+	HeartbeatStopped = "stopped"
+)
+
+//NewDaemon - create the Daemon of the Metrics with its Exporter, the derived Metric polls the air temperature and the relative humidity.
+func NewDaemon(dbc *DB, metrics ...Metric) *Daemon {
+	polled := []Metric{}
+	seen := map[string]bool{}
+	for _, metric := range metrics {
+		for _, m := range sourceMetrics(metric) {
+			if seen[m.Name] == false {
+				seen[m.Name] = true
+				polled = append(polled, m)
+			}
+		}
+	}
+	if len(polled) == 0 {
+		polled = []Metric{dbc.metric()}
+	}
+	return &Daemon{
+		DB:           dbc,
+		Metrics:      polled,
+		Interval:     DefaultPollInterval,
+		GapThreshold: DefaultGapThreshold,
+		MaxGapDays:   DefaultMaxGapDays,
+		Exporter:     NewExporter(dbc, polled...),
+	}
+}
+
+//Run - poll immediately and then every Interval until the ctx is done (ie: SIGTERM), the Heartbeat is written as stopped on the way out.
+//The failed poll is logged and polled again on the next Interval, so the Daemon keeps running when the API (or the database) is unavailable for a while.
+//The response being saved when the ctx is done is saved in full or not at all, the next run fills the gap.
+func (d *Daemon) Run(ctx context.Context) error {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	d.heartbeat = Heartbeat{PID: os.Getpid(), Status: HeartbeatRunning, StartedAt: time.Now().UTC()}
+	for _, metric := range d.Metrics {
+		d.heartbeat.Metrics = append(d.heartbeat.Metrics, MetricFreshness{Metric: metric.Name})
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.Poll(ctx); err != nil && ctx.Err() == nil {
+			d.heartbeat.ConsecutiveFailures++
+		} else if ctx.Err() == nil {
+			d.heartbeat.ConsecutiveFailures = 0
+		}
+		if ctx.Err() != nil {
+			d.heartbeat.Status = HeartbeatStopped
+		}
+		if err := d.writeHeartbeat(); err != nil {
+			d.logf("Heartbeat failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			if d.heartbeat.Status != HeartbeatStopped {
+				d.heartbeat.Status = HeartbeatStopped
+				if err := d.writeHeartbeat(); err != nil {
+					d.logf("Heartbeat failed: %v\n", err)
+				}
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//Poll - save the latest readings of every Metric once, return the total readings saved and the first error.
+//The Metric without a reading since GapThreshold has its gap filled by the FULL days, otherwise only the current minute is called.
+func (d *Daemon) Poll(ctx context.Context) (totalSaved int, firstErr error) {
+	now := time.Now().In(SGTLocation)
+	for i, metric := range d.Metrics {
+		if ctx.Err() != nil {
+			return totalSaved, firstErr
+		}
+		newest, err := d.DB.newestReading(metric)
+		var saved int
+		if err == nil {
+			if newest.IsZero() || now.Sub(newest) > d.gapThreshold() {
+				saved, err = d.fillGap(ctx, metric, newest, now)
+			} else {
+				saved, err = d.pollMinute(ctx, metric, now)
+			}
+		}
+		if ctx.Err() != nil {
+			//Stopped while calling, not a failure of the API.
+			return totalSaved, firstErr
+		}
+		totalSaved += saved
+		if i < len(d.heartbeat.Metrics) {
+			d.updateFreshness(&d.heartbeat.Metrics[i], metric, now, err)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return totalSaved, firstErr
+}
+
+//pollMinute - save the readings of the Metric for the current minute.
+func (d *Daemon) pollMinute(ctx context.Context, metric Metric, now time.Time) (int, error) {
+	saved, response, err := d.fetch(ctx, metric, now.Format(strStandardFormat), now.Format("15:04"))
+	if err != nil {
+		d.logf("%v | %-17v | failed: %v\n", now.Format("2006-01-02 15:04"), metric.Name, err)
+		return 0, err
+	}
+	latest := ""
+	if len(response.Items) > 0 {
+		latest = response.Items[len(response.Items)-1].Timestamp
+	}
+	d.logf("%v | %-17v | %4v readings of %v\n", now.Format("2006-01-02 15:04"), metric.Name, saved, latest)
+	return saved, nil
+}
+
+//fillGap - save the FULL days of the Metric from the newest saved reading (today if there is none) until today, at most MaxGapDays.
+//The past day is recorded on the backfill_jobs ledger, today is not complete yet so it is not recorded.
+func (d *Daemon) fillGap(ctx context.Context, metric Metric, newest, now time.Time) (totalSaved int, firstErr error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, SGTLocation)
+	gapFrom := today
+	if newest.IsZero() == false {
+		newest = newest.In(SGTLocation)
+		gapFrom = time.Date(newest.Year(), newest.Month(), newest.Day(), 0, 0, 0, 0, SGTLocation)
+	}
+	maxGapDays := d.MaxGapDays
+	if maxGapDays < 1 {
+		maxGapDays = 1
+	}
+	if earliest := today.AddDate(0, 0, 1-maxGapDays); gapFrom.Before(earliest) {
+		d.logf("%v | %-17v | the gap before %v is older than the max gap days, run the backfill command for it\n", now.Format("2006-01-02 15:04"), metric.Name, earliest.Format(strStandardFormat))
+		gapFrom = earliest
+	}
+	if newest.IsZero() {
+		d.logf("%v | %-17v | no reading saved yet, fetching today\n", now.Format("2006-01-02 15:04"), metric.Name)
+	} else {
+		d.logf("%v | %-17v | no reading since %v, fetching the days since %v\n", now.Format("2006-01-02 15:04"), metric.Name, newest.Format("2006-01-02 15:04"), gapFrom.Format(strStandardFormat))
+	}
+
+	yesterday := today.AddDate(0, 0, -1).Format(strStandardFormat)
+	jobByDate := map[string]BackfillJob{}
+	if gapFrom.Before(today) {
+		if err := d.DB.addPendingBackfillJobs(metric.Name, gapFrom.Format(strStandardFormat), yesterday); err != nil {
+			return 0, err
+		}
+		jobs, err := d.DB.BackfillJobs(metric.Name, gapFrom.Format(strStandardFormat), yesterday)
+		if err != nil {
+			return 0, err
+		}
+		for _, job := range jobs {
+			jobByDate[job.Date] = job
+		}
+	}
+
+	for day := gapFrom; day.After(today) == false; day = day.AddDate(0, 0, 1) {
+		dateVal := day.Format(strStandardFormat)
+		saved, _, err := d.fetch(ctx, metric, dateVal, "")
+		if ctx.Err() != nil {
+			return totalSaved, firstErr
+		}
+		if job, ok := jobByDate[dateVal]; ok {
+			if errJob := d.DB.saveBackfillJob(backfillJobResult(job, FetchResult{TotalSaved: saved, Err: err})); errJob != nil {
+				return totalSaved, errJob
+			}
+		}
+		if err != nil {
+			d.logf("%v | %-17v | %v failed: %v\n", now.Format("2006-01-02 15:04"), metric.Name, dateVal, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		d.logf("%v | %-17v | %v %6v readings\n", now.Format("2006-01-02 15:04"), metric.Name, dateVal, saved)
+		totalSaved += saved
+	}
+	return totalSaved, firstErr
+}
+
+//fetch - call the API of the Metric and save the response, observed by the Exporter.
+//The minute poll is not saved to the Store of the API client, only the FULL day is: a file per minute is never read again and the Store would grow forever.
+func (d *Daemon) fetch(ctx context.Context, metric Metric, dateVal, timeVal string) (saved int, response WeatherResponse, err error) {
+	client := d.DB.apiClient()
+	if len(timeVal) > 0 && client.Store != nil {
+		realtime := *client
+		realtime.Store = nil
+		client = &realtime
+	}
+	startTime := time.Now()
+	response, err = client.GetReadings(ctx, metric, dateVal, timeVal)
+	if err == nil {
+		saved, err = d.DB.SaveWeatherResponse(metric, response)
+	}
+	if ctx.Err() == nil {
+		d.Exporter.ObserveFetch(metric, time.Since(startTime), err)
+	}
+	return saved, response, err
+}
+
+//updateFreshness - the freshness of the Metric after its poll.
+func (d *Daemon) updateFreshness(freshness *MetricFreshness, metric Metric, now time.Time, err error) {
+	if err != nil {
+		freshness.LastError = err.Error()
+	} else {
+		freshness.LastSuccess = now.UTC()
+		freshness.LastError = ""
+	}
+	if newest, errNewest := d.DB.newestReading(metric); errNewest == nil && newest.IsZero() == false {
+		freshness.NewestReading = newest.UTC()
+		freshness.LagSeconds = now.Sub(newest).Seconds()
+	}
+}
+
+//gapThreshold - the GapThreshold, DefaultGapThreshold if it is not set.
+func (d *Daemon) gapThreshold() time.Duration {
+	if d.GapThreshold > 0 {
+		return d.GapThreshold
+	}
+	return DefaultGapThreshold
+}
+
+//newestReading - the time of the newest saved reading of the Metric, zero time if there is none.
+func (dbc *DB) newestReading(metric Metric) (time.Time, error) {
+	var ts sql.NullInt64
+	if err := dbc.QueryRow("SELECT MAX(ts) FROM readings WHERE metric = ?", metric.Name).Scan(&ts); err != nil {
+		return time.Time{}, dbError("query newest reading", err)
+	}
+	if ts.Valid == false {
+		return time.Time{}, nil
+	}
+	return time.Unix(ts.Int64, 0), nil
+}
+
+//writeHeartbeat - write the Heartbeat to the HeartbeatFile (if any) through a temporary file, so the reader never sees a partial file.
+func (d *Daemon) writeHeartbeat() error {
+	if len(d.HeartbeatFile) == 0 {
+		return nil
+	}
+	d.heartbeat.UpdatedAt = time.Now().UTC()
+	body, err := json.MarshalIndent(d.heartbeat, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(d.HeartbeatFile), filepath.Base(d.HeartbeatFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(append(body, '\n')); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), d.HeartbeatFile)
+}
+
+//ReadHeartbeat - the Heartbeat written by the Daemon to the file.
+func ReadHeartbeat(path string) (heartbeat Heartbeat, err error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return heartbeat, err
+	}
+	err = json.Unmarshal(body, &heartbeat)
+	return heartbeat, err
+}
+
+//CheckHeartbeat - the Heartbeat is fresh: the Daemon is running, updated and its readings are not older than maxAge (at now).
+func CheckHeartbeat(heartbeat Heartbeat, now time.Time, maxAge time.Duration) error {
+	if heartbeat.Status != HeartbeatRunning {
+		return fmt.Errorf("the daemon (pid %v) is %v since %v", heartbeat.PID, heartbeat.Status, heartbeat.UpdatedAt.In(SGTLocation).Format("2006-01-02 15:04:05"))
+	}
+	if age := now.Sub(heartbeat.UpdatedAt); age > maxAge {
+		return fmt.Errorf("the daemon (pid %v) has not polled for %v", heartbeat.PID, age.Round(time.Second))
+	}
+	for _, freshness := range heartbeat.Metrics {
+		if freshness.NewestReading.IsZero() {
+			return fmt.Errorf("no %v reading saved yet", freshness.Metric)
+		}
+		if age := now.Sub(freshness.NewestReading); age > maxAge {
+			return fmt.Errorf("the newest %v reading is %v old", freshness.Metric, age.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+//PrintHeartbeat - print the Heartbeat to the console.
+func PrintHeartbeat(heartbeat Heartbeat, now time.Time) {
+	fmt.Printf("Daemon (pid %v) %v, started %v, updated %v ago\n", heartbeat.PID, heartbeat.Status,
+		heartbeat.StartedAt.In(SGTLocation).Format("2006-01-02 15:04:05"), now.Sub(heartbeat.UpdatedAt).Round(time.Second))
+	if heartbeat.ConsecutiveFailures > 0 {
+		fmt.Printf("%v failed polls in a row\n", heartbeat.ConsecutiveFailures)
+	}
+	fmt.Printf("\n%-17v | %-19v | %10v | %v\n", "Metric", "Newest Reading", "Age", "Last Error")
+	fmt.Printf("%v\n", "==================+=====================+============+===========")
+	for _, freshness := range heartbeat.Metrics {
+		newest, age := "-", "-"
+		if freshness.NewestReading.IsZero() == false {
+			newest = freshness.NewestReading.In(SGTLocation).Format("2006-01-02 15:04:05")
+			age = now.Sub(freshness.NewestReading).Round(time.Second).String()
+		}
+		fmt.Printf("%-17v | %-19v | %10v | %v\n", freshness.Metric, newest, age, freshness.LastError)
+	}
+}
+
+//logf - print to the Log, if any.
+func (d *Daemon) logf(format string, a ...interface{}) {
+	if d.Log != nil {
+		fmt.Fprintf(d.Log, format, a...)
+	}
+}
@@ -0,0 +1,58 @@
+package SGAirTemp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//storedFiles - the total responses saved on the ResponseStore directory.
+func storedFiles(t *testing.T, dir string) int {
+	t.Helper()
+	total := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(path, ".json.gz") {
+			total++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
+
+func TestDaemonPollMinuteNotStored(t *testing.T) {
+	dbc := newTestDB(t)
+	client, handler := newMockClient(t, MockOptions{Stations: 2})
+	storeDir := t.TempDir()
+	client.Store = NewResponseStore(storeDir)
+	dbc.API = client
+
+	//The newest reading is fresh, so only the current minute is polled.
+	lastMinute := time.Now().In(SGTLocation).Add(-time.Minute)
+	saveTestReading(t, dbc, MetricAirTemperature, "S109", lastMinute.Format("2006-01-02 15:04"), 30)
+	daemon := NewDaemon(dbc, MetricAirTemperature)
+	for i := 0; i < 3; i++ {
+		if _, err := daemon.Poll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if handler.Requests() != 3 {
+		t.Errorf("%v requests served, want 3 polls", handler.Requests())
+	}
+	if total := storedFiles(t, storeDir); total != 0 {
+		t.Errorf("%v responses saved to the store by the minute polls, want none", total)
+	}
+
+	//The FULL day of the gap is still saved.
+	if _, _, err := daemon.fetch(context.Background(), MetricAirTemperature, "2024-05-01", ""); err != nil {
+		t.Fatal(err)
+	}
+	if total := storedFiles(t, storeDir); total != 1 {
+		t.Errorf("%v responses saved to the store for the FULL day, want 1", total)
+	}
+}
@@ -3,160 +3,213 @@ package SGAirTemp
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //InitDBConn function to initiate the connection and store it to the global DB connection.
 func InitDBConn(host, port string) (*DB, error) {
 	db, err := sql.Open(host, port)
 	if err != nil {
-		return nil, err
+		return nil, dbError("open database", err)
 	}
-	return &DB{db}, nil
+	return &DB{DB: db, Scheduler: NewFetchScheduler(DefaultFetchWorkers, DefaultRequestsPerSecond)}, nil
 }
 
 //PrepareDBTable function to prepare the database if not exists
-func (dbc *DB) PrepareDBTable() {
-	//Make sure the table is exists, especially for the new environment.
-	//Create table for stations
-	statement, _ := dbc.Prepare("CREATE TABLE IF NOT EXISTS stations (station_id TEXT PRIMARY KEY, station_name TEXT, loc_latitude TEXT, loc_longitude TEXT)")
-	statement.Exec()
-	//Create table for readings
-	statement, _ = dbc.Prepare("CREATE TABLE IF NOT EXISTS readings (station_id TEXT, yr TEXT, mo TEXT, dt TEXT, hr TEXT, mi TEXT, value REAL)")
-	statement.Exec()
-
+//All the schema migrations (see the migrations folder) not applied yet are applied, so the database created by the older version is converted to the current schema.
+func (dbc *DB) PrepareDBTable() error {
+	_, err := dbc.MigrateUp(0)
+	return err
 }
 
-//InsertTemperatureReading - a function to check if the wheather reading grabbed from API exists on the Database and save it if we can't find it.
-func (dbc *DB) InsertTemperatureReading(stationID, timeStamp string, Value float64) {
-
-	//Parse the timestamp. Ugly but more straight-forward
-	//Start - TODO: research better method?
-	yr := string([]rune(timeStamp)[0:4])
-	mo := string([]rune(timeStamp)[5:7])
-	dt := string([]rune(timeStamp)[8:10])
-	hr := string([]rune(timeStamp)[11:13])
-	mi := string([]rune(timeStamp)[14:16])
-	//End - TODO: research better method?
-	totalRow, _ := strconv.Atoi(dbc.GetScalar(fmt.Sprintf("SELECT COUNT(station_id) scalarRes FROM readings WHERE station_id ='%v' AND yr ='%v' AND mo ='%v' AND dt ='%v' AND hr ='%v' AND mi ='%v'", stationID, yr, mo, dt, hr, mi)))
-
-	//No Data found for this Temperature ID, add it.
-	if totalRow <= 0 {
-		statement, err := dbc.Prepare("INSERT INTO readings(station_id, yr, mo, dt, hr, mi, value) VALUES(?, ?, ?, ?, ?, ?, ?)")
-		statement.Exec(stationID, yr, mo, dt, hr, mi, strconv.FormatFloat(Value, 'f', 5, 64))
-		if err != nil {
-			fmt.Printf("\nError During Insert:%v", err)
+//tableColumns - the column name and its declared type of the table, empty if the table is not exists.
+func (dbc *DB) tableColumns(tableName string) (map[string]string, error) {
+	var cid, notNull, pk int
+	var name, colType string
+	var defaultValue interface{}
+	columns := map[string]string{}
+
+	rows, err := dbc.Query(fmt.Sprintf("PRAGMA table_info(%v)", tableName))
+	if err != nil {
+		return columns, dbError("read table info", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return columns, dbError("read table info", err)
 		}
+		columns[name] = strings.ToUpper(colType)
 	}
+	return columns, dbError("read table info", rows.Err())
 }
 
-//InsertStation - a function to check if the station grabbed from API is exist on the Datbase and save it if we can't find it.
-func (dbc *DB) InsertStation(stationID, StationName, LocLatitude, LocLongitude string) {
-	totalRow, _ := strconv.Atoi(dbc.GetScalar(fmt.Sprintf("SELECT COUNT(station_id) scalarRes FROM stations WHERE station_id ='%v'", stationID)))
-
-	//No Data found for this station ID, add it.
-	if totalRow <= 0 {
-		statement, err := dbc.Prepare("INSERT INTO stations(station_id, station_name, loc_latitude, loc_longitude) VALUES(?, ?, ?, ?)")
-		statement.Exec(stationID, StationName, LocLatitude, LocLongitude)
-		if err != nil {
-			fmt.Printf("\nError During Insert:%v", err)
-		}
+//InsertTemperatureReading - a function to save the wheather reading grabbed from API (air-temperature), the existing reading of the station and time is kept.
+//timeStamp is the RFC3339 timestamp returned by the API, ie: 2020-06-01T15:00:00+08:00
+//Every call is its own transaction, use SaveWeatherResponse to save the whole API response.
+func (dbc *DB) InsertTemperatureReading(stationID, timeStamp string, Value float64) error {
+	ts, err := time.Parse(time.RFC3339, timeStamp)
+	if err != nil {
+		return &InputError{Value: timeStamp, Message: "reading timestamp is not RFC3339", Err: ErrInvalidDateFormat}
 	}
+
+	_, err = dbc.Exec("INSERT OR IGNORE INTO readings(metric, station_id, ts, ts_sgt, value) VALUES(?, ?, ?, ?, ?)", MetricAirTemperature.Name, stationID, ts.Unix(), ts.In(SGTLocation).Format(time.RFC3339), Value)
+	return dbError("insert reading", err)
+}
+
+//InsertStation - a function to save the station grabbed from API if we can't find it on the Database.
+func (dbc *DB) InsertStation(stationID, StationName string, LocLatitude, LocLongitude float64) error {
+	_, err := dbc.Exec("INSERT OR IGNORE INTO stations(station_id, station_name, loc_latitude, loc_longitude) VALUES(?, ?, ?, ?)", stationID, StationName, LocLatitude, LocLongitude)
+	return dbError("insert station", err)
 }
 
 //GetScalar - a function to return the scalar value of a query with the scalarRes as the return name.
 //Ideal for SELECT COUNT(1) scalarRes
-func (dbc *DB) GetScalar(strSQL string) (scalarRes string) {
-	rows, err := dbc.Query(strSQL)
+func (dbc *DB) GetScalar(strSQL string, args ...interface{}) (scalarRes string, err error) {
+	var nullRes sql.NullString
+	rows, err := dbc.Query(strSQL, args...)
 	if err != nil {
-		log.Fatal(err)
+		return "", dbError("query scalar", err)
 	}
+	defer rows.Close()
 	for rows.Next() {
-		rows.Scan(&scalarRes)
+		if err := rows.Scan(&nullRes); err != nil {
+			return "", dbError("query scalar", err)
+		}
+	}
+	return nullRes.String, dbError("query scalar", rows.Err())
+}
+
+//maxStationNameLen - the maximum length of the all the Station's name, used for printing.
+func (dbc *DB) maxStationNameLen() (string, error) {
+	MaxStationNameLen, err := dbc.GetScalar("SELECT LENGTH(station_name) scalarRes FROM stations ORDER BY LENGTH(station_name) DESC LIMIT 1")
+	if err != nil {
+		return "", err
+	}
+	//Header "StationName" is the minimum.
+	if MaxStationNameLenInt, _ := strconv.Atoi(MaxStationNameLen); MaxStationNameLenInt < len("StationName") {
+		MaxStationNameLen = strconv.Itoa(len("StationName"))
 	}
-	return scalarRes
+	return MaxStationNameLen, nil
+}
+
+//Stations - the saved Stations on the page, ordered by the station name, and the total saved Stations.
+func (dbc *DB) Stations(page Page) ([]Station, int, error) {
+	stations := []Station{}
+	strTotalRow, err := dbc.GetScalar("SELECT COUNT(station_id) scalarRes FROM stations")
+	if err != nil {
+		return stations, 0, err
+	}
+	totalRow, _ := strconv.Atoi(strTotalRow)
+
+	rows, err := dbc.Query("SELECT station_id, station_name, IFNULL(loc_latitude, 0), IFNULL(loc_longitude, 0) FROM stations ORDER BY station_name, station_id" + page.sql())
+	if err != nil {
+		return stations, totalRow, dbError("query stations", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		st := Station{}
+		if err := rows.Scan(&st.StationID, &st.StationName, &st.Location.Latitude, &st.Location.Longitude); err != nil {
+			return stations, totalRow, dbError("query stations", err)
+		}
+		stations = append(stations, st)
+	}
+	return stations, totalRow, dbError("query stations", rows.Err())
 }
 
 //PrintStations - function to print the Stations to the console
-func (dbc *DB) PrintStations() {
-	MaxStationNameLen := dbc.GetScalar("SELECT LENGTH(station_name) scalarRes FROM stations ORDER BY LENGTH(station_name) DESC LIMIT 1")
+func (dbc *DB) PrintStations() error {
+	MaxStationNameLen, err := dbc.maxStationNameLen()
+	if err != nil {
+		return err
+	}
 
-	totalRow, _ := strconv.Atoi(dbc.GetScalar("SELECT COUNT(station_id) scalarRes FROM stations"))
+	strTotalRow, err := dbc.GetScalar("SELECT COUNT(station_id) scalarRes FROM stations")
+	if err != nil {
+		return err
+	}
+	totalRow, _ := strconv.Atoi(strTotalRow)
 
 	if totalRow > 0 {
-		rows, _ := dbc.Query("SELECT station_id, station_name, loc_latitude, loc_longitude FROM stations")
+		rows, err := dbc.Query("SELECT station_id, station_name, loc_latitude, loc_longitude FROM stations")
+		if err != nil {
+			return dbError("query stations", err)
+		}
+		defer rows.Close()
 		var StationID string
 		var stationName string
-		var locLatitude string
-		var locLongitude string
+		var locLatitude float64
+		var locLongitude float64
 		fmt.Printf("%9s"+" | "+"%"+MaxStationNameLen+"s"+" | %9s | %9s\n", "StationID", "StationName", "Latitude", "Longitude")
 		MaxStationNameLenInt, _ := strconv.Atoi(MaxStationNameLen)
 		fmt.Printf("%s\n", strings.Repeat("=", MaxStationNameLenInt+36))
 
 		for rows.Next() {
 			rows.Scan(&StationID, &stationName, &locLatitude, &locLongitude)
-			fmt.Printf("%9s"+" | "+"%"+MaxStationNameLen+"s"+" | %9s | %9s\n", StationID, stationName, locLatitude, locLongitude)
+			fmt.Printf("%9s"+" | "+"%"+MaxStationNameLen+"s"+" | %9.5f | %9.5f\n", StationID, stationName, locLatitude, locLongitude)
 		}
-	} else {
-		fmt.Printf("No Station being found, please retrive it fromt the API")
+		return dbError("query stations", rows.Err())
 	}
-
+	fmt.Printf("No Station being found, please retrive it fromt the API")
+	return nil
 }
 
-//PrintTemperatureReading - function to print the Temperature Reading to the console
-func (dbc *DB) PrintTemperatureReading(dateVal, timeVal string) {
-	whereCondSQL := ""
-	//Flexible array, by using splices
-	WhereCondition := []string{}
+//PrintTemperatureReading - function to print the Reading of the DB Metric to the console
+func (dbc *DB) PrintTemperatureReading(dateVal, timeVal string) error {
+	var err error
+	filter := Filter{}
 	//Make sure only
 	if len(dateVal) > 0 {
-		dateVal = CheckInputDate(dateVal)
-		arrDate := strings.Split(dateVal, "-")
-		yrCond := arrDate[0]
-		moCond := arrDate[1]
-		dtCond := arrDate[2]
-		WhereCondition = append(WhereCondition, fmt.Sprintf("yr ='%v'", yrCond))
-		WhereCondition = append(WhereCondition, fmt.Sprintf("mo ='%v'", moCond))
-		WhereCondition = append(WhereCondition, fmt.Sprintf("dt ='%v'", dtCond))
+		dateVal, err = CheckInputDate(dateVal)
+		if err != nil {
+			return err
+		}
+		filter.DateFrom = dateVal
+		filter.DateTo = dateVal
 	}
 
-	if len(timeVal) == 5 {
-		timeVal = CheckInputTime(timeVal)
-		arrTime := strings.Split(timeVal, ":")
-		hrCond := arrTime[0]
-		miCond := arrTime[1]
-		WhereCondition = append(WhereCondition, fmt.Sprintf("hr ='%v'", hrCond))
-		WhereCondition = append(WhereCondition, fmt.Sprintf("mi ='%v'", miCond))
+	if len(timeVal) > 0 {
+		timeVal, err = CheckInputTime(timeVal)
+		if err != nil {
+			return err
+		}
+		hrCond, _ := strconv.Atoi(timeVal[0:2])
+		miCond, _ := strconv.Atoi(timeVal[3:5])
+		filter.Hours = []int{hrCond}
+		filter.Minutes = []int{miCond}
 	}
 
-	if len(WhereCondition) > 0 {
-		whereCondSQL = fmt.Sprintf(" WHERE %v ", strings.Join(WhereCondition[:], " AND "))
+	filter = dbc.dbFilter(filter)
+	whereCondSQL, args, err := filter.whereClause()
+	if err != nil {
+		return err
 	}
 
-	MaxStationNameLen := dbc.GetScalar("SELECT LENGTH(station_name) scalarRes FROM stations ORDER BY LENGTH(station_name) DESC LIMIT 1")
-	totalRow, _ := strconv.Atoi(dbc.GetScalar(fmt.Sprintf("SELECT COUNT(station_id) scalarRes FROM readings %v", whereCondSQL)))
+	MaxStationNameLen, err := dbc.maxStationNameLen()
+	if err != nil {
+		return err
+	}
+	strTotalRow, err := dbc.GetScalar(fmt.Sprintf("SELECT COUNT(r.station_id) scalarRes FROM %v %v", filter.readingsFrom(), whereCondSQL), args...)
+	if err != nil {
+		return err
+	}
+	totalRow, _ := strconv.Atoi(strTotalRow)
 
 	if totalRow > 0 {
-		rows, _ := dbc.Query(fmt.Sprintf("SELECT s.station_name, yr, mo, dt, hr, mi, value FROM readings r INNER JOIN stations s ON s.station_id = r.station_id %v ORDER BY s.station_name, yr, mo, dt, hr, mi", whereCondSQL))
-		var StationName string
-		var yr string
-		var mo string
-		var dt string
-		var hr string
-		var mi string
-		var value string
-
 		fmt.Printf("\n%"+MaxStationNameLen+"s"+" | %16s | %s\n", "StationName", "Date/Time", "Value")
 		MaxStationNameLenInt, _ := strconv.Atoi(MaxStationNameLen)
 		fmt.Printf("%s\n", strings.Repeat("=", MaxStationNameLenInt+27))
-		for rows.Next() {
-			rows.Scan(&StationName, &yr, &mo, &dt, &hr, &mi, &value)
-			fmt.Printf("%"+MaxStationNameLen+"s"+" | %v-%v-%v %v:%v | %v\n", StationName, yr, mo, dt, hr, mi, value)
-		}
-	} else {
-		fmt.Printf("No Reading being found, please retrive it from the API")
+		return dbc.queryReadings(filter, "s.station_name, r.ts", Page{}, func(StationID, StationName string, ts int64, value float64) error {
+			fmt.Printf("%"+MaxStationNameLen+"s"+" | %v | %v\n", StationName, formatSGT(ts), value)
+			return nil
+		})
 	}
+	fmt.Printf("No Reading being found, please retrive it from the API")
+	return nil
+}
 
+//formatSGT - the Unix time as "YYYY-MM-DD HH:mm" in SGT.
+func formatSGT(ts int64) string {
+	return time.Unix(ts, 0).In(SGTLocation).Format("2006-01-02 15:04")
 }
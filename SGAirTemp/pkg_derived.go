@@ -0,0 +1,107 @@
+package SGAirTemp
+
+import (
+	"fmt"
+	"math"
+)
+
+//The derived metrics, computed at query time from the air temperature and the relative humidity of the same station and timestamp.
+//They are not saved and not fetched from the API, fetching them fetches the air temperature and the relative humidity.
+var (
+	MetricHeatIndex = Metric{Name: "heat-index", Label: "Heat Index", Unit: "deg C"}
+	MetricDewPoint  = Metric{Name: "dew-point", Label: "Dew Point", Unit: "deg C"}
+	MetricHumidex   = Metric{Name: "humidex", Label: "Humidex", Unit: "deg C"}
+)
+
+//DerivedMetrics - all the derived metrics.
+func DerivedMetrics() []Metric {
+	return []Metric{MetricHeatIndex, MetricDewPoint, MetricHumidex}
+}
+
+//IsDerivedMetric - the metric name is one of the DerivedMetrics.
+func IsDerivedMetric(name string) bool {
+	for _, m := range DerivedMetrics() {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+//sourceMetrics - the Metrics fetched from the API for the Metric, the air temperature and the relative humidity for the derived Metric.
+func sourceMetrics(metric Metric) []Metric {
+	if IsDerivedMetric(metric.Name) {
+		return []Metric{MetricAirTemperature, MetricRelativeHumidity}
+	}
+	return []Metric{metric}
+}
+
+//derivedCondition - the SQL condition on the relative humidity (rh) of the rows the derived metric can be computed from, empty if there is none.
+//The rows skipped by derivedValue are excluded by the query, so the LIMIT/OFFSET of a page and the count match the returned rows.
+func derivedCondition(name string) string {
+	if name == MetricDewPoint.Name || name == MetricHumidex.Name {
+		//The dew point (and the humidex from it) is NaN for 0% humidity.
+		return "rh.value > 0"
+	}
+	return ""
+}
+
+//derivedValue - the value of the derived metric from the air temperature (deg C) and the relative humidity (%),
+//false if it can't be computed (ie: 0% humidity for the dew point). The value is rounded to 0.1 like the readings of the API.
+func derivedValue(name string, tempC, humidity float64) (float64, bool) {
+	var value float64
+	switch name {
+	case MetricHeatIndex.Name:
+		value = HeatIndex(tempC, humidity)
+	case MetricDewPoint.Name:
+		value = DewPoint(tempC, humidity)
+	case MetricHumidex.Name:
+		value = Humidex(tempC, humidity)
+	default:
+		return 0, false
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return math.Round(value*10) / 10, true
+}
+
+//HeatIndex - the apparent temperature (deg C) by the US National Weather Service formula:
+//the simple Steadman formula, or the Rothfusz regression (with its adjustments) when the heat index is 80 F and above.
+func HeatIndex(tempC, humidity float64) float64 {
+	T := tempC*9/5 + 32
+	RH := humidity
+	HI := 0.5 * (T + 61 + (T-68)*1.2 + RH*0.094)
+	if (HI+T)/2 >= 80 {
+		HI = -42.379 + 2.04901523*T + 10.14333127*RH - 0.22475541*T*RH - 0.00683783*T*T - 0.05481717*RH*RH +
+			0.00122874*T*T*RH + 0.00085282*T*RH*RH - 0.00000199*T*T*RH*RH
+		if RH < 13 && T >= 80 && T <= 112 {
+			HI -= (13 - RH) / 4 * math.Sqrt((17-math.Abs(T-95))/17)
+		} else if RH > 85 && T >= 80 && T <= 87 {
+			HI += (RH - 85) / 10 * (87 - T) / 5
+		}
+	}
+	return (HI - 32) * 5 / 9
+}
+
+//DewPoint - the dew point (deg C) by the Magnus formula, NaN for 0% humidity.
+func DewPoint(tempC, humidity float64) float64 {
+	const b, c = 17.625, 243.04
+	if humidity <= 0 {
+		return math.NaN()
+	}
+	gamma := math.Log(humidity/100) + b*tempC/(c+tempC)
+	return c * gamma / (b - gamma)
+}
+
+//Humidex - the Canadian humidex (deg C) from the temperature and its dew point, NaN for 0% humidity.
+func Humidex(tempC, humidity float64) float64 {
+	dewPointK := DewPoint(tempC, humidity) + 273.15
+	vapourPressure := 6.11 * math.Exp(5417.7530*(1/273.16-1/dewPointK))
+	return tempC + 0.5555*(vapourPressure-10)
+}
+
+//errDerivedMetric - the derived Metric can not be fetched (or backfilled) by itself.
+func errDerivedMetric(metric Metric) error {
+	return &InputError{Value: metric.Name, Message: fmt.Sprintf("it is computed from %v and %v, please use them instead", MetricAirTemperature.Name, MetricRelativeHumidity.Name), Err: ErrDerivedMetric}
+}
@@ -0,0 +1,66 @@
+package SGAirTemp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDerivedFormulas(t *testing.T) {
+	tests := []struct {
+		name                         string
+		tempC, humidity              float64
+		heatIndex, dewPoint, humidex float64
+	}{
+		//The reference values of the NWS heat index and the Environment Canada humidex.
+		{"hot and humid", 30, 70, 35.0, 23.9, 41.2},
+		//The heat index below 80 F is the simple Steadman formula, the Rothfusz regression would give 25.2.
+		{"low temperature", 20, 50, 19.4, 9.3, 20.9},
+		{"mild", 25, 40, 24.6, 10.5, 26.5},
+		//The adjustment of the Rothfusz regression for the humidity below 13%, 32.3 without it.
+		{"dry", 35, 10, 31.9, -1.2, 32.6},
+		//The adjustment of the Rothfusz regression for the humidity above 85%, 33.7 without it.
+		{"very humid", 28, 90, 34.0, 26.2, 41.7},
+		//The dew point of the saturated air is the temperature.
+		{"saturated", 30, 100, 44.4, 30, 48.6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value := HeatIndex(tt.tempC, tt.humidity); math.Abs(value-tt.heatIndex) > 0.05 {
+				t.Errorf("HeatIndex(%v, %v) = %v, want %v", tt.tempC, tt.humidity, value, tt.heatIndex)
+			}
+			if value := DewPoint(tt.tempC, tt.humidity); math.Abs(value-tt.dewPoint) > 0.05 {
+				t.Errorf("DewPoint(%v, %v) = %v, want %v", tt.tempC, tt.humidity, value, tt.dewPoint)
+			}
+			if value := Humidex(tt.tempC, tt.humidity); math.Abs(value-tt.humidex) > 0.05 {
+				t.Errorf("Humidex(%v, %v) = %v, want %v", tt.tempC, tt.humidity, value, tt.humidex)
+			}
+		})
+	}
+
+	if math.IsNaN(DewPoint(30, 0)) == false || math.IsNaN(Humidex(30, 0)) == false {
+		t.Errorf("DewPoint and Humidex of 0%% humidity = %v, %v, want NaN", DewPoint(30, 0), Humidex(30, 0))
+	}
+}
+
+func TestDerivedValue(t *testing.T) {
+	tests := []struct {
+		name            string
+		tempC, humidity float64
+		want            float64
+		wantOk          bool
+	}{
+		//Rounded to 0.1 like the readings of the API.
+		{MetricHeatIndex.Name, 30, 70, 35, true},
+		{MetricDewPoint.Name, 30, 70, 23.9, true},
+		{MetricHumidex.Name, 30, 70, 41.2, true},
+		{MetricHeatIndex.Name, 30, 0, 27.2, true},
+		{MetricDewPoint.Name, 30, 0, 0, false},
+		{MetricHumidex.Name, 30, 0, 0, false},
+		{MetricAirTemperature.Name, 30, 70, 0, false},
+	}
+	for _, tt := range tests {
+		if value, ok := derivedValue(tt.name, tt.tempC, tt.humidity); value != tt.want || ok != tt.wantOk {
+			t.Errorf("derivedValue(%v, %v, %v) = %v, %v, want %v, %v", tt.name, tt.tempC, tt.humidity, value, ok, tt.want, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package SGAirTemp
+
+import (
+	"errors"
+	"fmt"
+)
+
+//The kinds of error returned by the package, check them with errors.Is
+var (
+	//ErrInvalidDateFormat - the date is not match with YYYY-MM-DD (or YYYY-MM for month) format.
+	ErrInvalidDateFormat = errors.New("invalid date format")
+	//ErrInvalidDate - the date is in the right format but not a valid date, ie: 2020-02-30.
+	ErrInvalidDate = errors.New("invalid date")
+	//ErrInvalidTime - the time is not a valid HH:mm time.
+	ErrInvalidTime = errors.New("invalid time")
+	//ErrDateBeforeEarliest - the date is before EarliestDataAvail.
+	ErrDateBeforeEarliest = errors.New("date before the earliest data available")
+	//ErrFutureDate - the date/time is later than the allowed date/time (now or yesterday).
+	ErrFutureDate = errors.New("future date")
+	//ErrAPIUnavailable - the API couldn't be called or returned non 200 status code.
+	ErrAPIUnavailable = errors.New("API unavailable")
+	//ErrAPIEmptyBody - the API returned no data for the requested date/time.
+	ErrAPIEmptyBody = errors.New("API returned empty body")
+	//ErrUnknownMetric - the metric is not one of the supported Metrics.
+	ErrUnknownMetric = errors.New("unknown metric")
+	//ErrDerivedMetric - the derived Metric is computed from the other Metrics, it can not be fetched from the API by itself.
+	ErrDerivedMetric = errors.New("derived metric")
+	//ErrInvalidParameter - the query parameter of the Server is not valid, ie: a negative offset.
+	ErrInvalidParameter = errors.New("invalid parameter")
+	//ErrNoData - there is no saved reading for the requested statistic, even after calling the API.
+	ErrNoData = errors.New("no data reading")
+	//ErrParquetNotBuilt - the binary is built without the Parquet writer (the parquet build tag).
+	ErrParquetNotBuilt = errors.New("parquet export not built in, rebuild with: go build -tags parquet")
+	//ErrDB - the database query or statement failed.
+	ErrDB = errors.New("database failure")
+)
+
+//errNoData - the statistic of the date/month has no reading.
+func errNoData(value string) error {
+	return fmt.Errorf("%w for '%v'", ErrNoData, value)
+}
+
+//InputError struct - the user input is not valid, Err is one of the ErrInvalid*, ErrDateBeforeEarliest or ErrFutureDate.
+type InputError struct {
+	Value   string
+	Message string
+	Err     error
+}
+
+func (e *InputError) Error() string {
+	if len(e.Message) > 0 {
+		return fmt.Sprintf("%v '%v': %v", e.Err, e.Value, e.Message)
+	}
+	return fmt.Sprintf("%v '%v'", e.Err, e.Value)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+//APIError struct - calling the API failed, Kind is ErrAPIUnavailable or ErrAPIEmptyBody.
+//Attempts is the total calls made, more than 1 if the failure was retried.
+type APIError struct {
+	URL        string
+	StatusCode int
+	Kind       error
+	Err        error
+	Attempts   int
+}
+
+func (e *APIError) Error() string {
+	strError := fmt.Sprintf("%v during calling-> %v", e.Kind, e.URL)
+	if e.StatusCode > 0 {
+		strError = fmt.Sprintf("%v (Error Code: %v)", strError, e.StatusCode)
+	}
+	if e.Attempts > 1 {
+		strError = fmt.Sprintf("%v after %v attempts", strError, e.Attempts)
+	}
+	if e.Err != nil {
+		strError = fmt.Sprintf("%v: %v", strError, e.Err)
+	}
+	return strError
+}
+
+//Is - the APIError is its Kind.
+func (e *APIError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+//DBError struct - the database Op (operation) failed.
+type DBError struct {
+	Op  string
+	Err error
+}
+
+func (e *DBError) Error() string {
+	return fmt.Sprintf("%v during %v: %v", ErrDB, e.Op, e.Err)
+}
+
+//Is - every DBError is ErrDB.
+func (e *DBError) Is(target error) bool {
+	return target == ErrDB
+}
+
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+//dbError - wrap the err as DBError, nil if there is no error.
+func dbError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &DBError{Op: op, Err: err}
+}
@@ -0,0 +1,313 @@
+package SGAirTemp
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ExportFormat - the file format of the export.
+type ExportFormat string
+
+//The formats of the export, every row is written as soon as it is read, so the export never holds all the rows in memory.
+const (
+	//ExportCSV - comma separated values with the header row.
+	ExportCSV ExportFormat = "csv"
+	//ExportJSON - one JSON array of the row objects.
+	ExportJSON ExportFormat = "json"
+	//ExportNDJSON - one JSON object per line (newline delimited JSON).
+	ExportNDJSON ExportFormat = "ndjson"
+	//ExportParquet - the Parquet files of the readings partitioned by the year and month, written to a directory by ExportParquet.
+	ExportParquet ExportFormat = "parquet"
+	//ExportGeoJSON - the FeatureCollection of the stations with their latest and daily readings, written by WriteGeoJSON.
+	ExportGeoJSON ExportFormat = "geojson"
+)
+
+//ParquetPartition struct - one file written by ExportParquet.
+type ParquetPartition struct {
+	Path string
+	Rows int
+}
+
+//ExportFormats - all the formats of the export.
+func ExportFormats() []ExportFormat {
+	return []ExportFormat{ExportCSV, ExportJSON, ExportNDJSON, ExportParquet, ExportGeoJSON}
+}
+
+//errNotStreamed - the format is not a list of rows: parquet is written to a directory, geojson is the features of the stations.
+func errNotStreamed(format ExportFormat) error {
+	if format == ExportGeoJSON {
+		return &InputError{Value: string(format), Message: "it is the features of the stations written by WriteGeoJSON, only the stations can be exported as geojson", Err: ErrInvalidParameter}
+	}
+	return &InputError{Value: string(format), Message: "it is written to a directory by ExportParquet, only the readings can be exported as parquet", Err: ErrInvalidParameter}
+}
+
+//ExportFormatByName - the ExportFormat with the name, csv for the empty name.
+func ExportFormatByName(name string) (ExportFormat, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 {
+		return ExportCSV, nil
+	}
+	names := []string{}
+	for _, format := range ExportFormats() {
+		if string(format) == name {
+			return format, nil
+		}
+		names = append(names, string(format))
+	}
+	return "", &InputError{Value: name, Message: "please choose " + strings.Join(names, ", "), Err: ErrInvalidParameter}
+}
+
+//exportWriter struct - write the rows of the columns in the ExportFormat, Close must be called to complete the output.
+type exportWriter struct {
+	format  ExportFormat
+	out     *bufio.Writer
+	csv     *csv.Writer
+	columns []string
+	rows    int
+}
+
+//newExportWriter - create the exportWriter of the columns, the CSV header (or the JSON array) is written on the first row.
+func newExportWriter(w io.Writer, format ExportFormat, columns []string) *exportWriter {
+	ew := &exportWriter{format: format, out: bufio.NewWriter(w), columns: columns}
+	if format == ExportCSV {
+		ew.csv = csv.NewWriter(ew.out)
+	}
+	return ew
+}
+
+//Write - write one row, the values are in the order of the columns.
+func (ew *exportWriter) Write(values ...interface{}) error {
+	if ew.format == ExportCSV {
+		if ew.rows == 0 {
+			if err := ew.csv.Write(ew.columns); err != nil {
+				return err
+			}
+		}
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = csvValue(value)
+		}
+		ew.rows++
+		return ew.csv.Write(record)
+	}
+
+	//The object is built by hand to keep the keys in the order of the columns.
+	var object strings.Builder
+	object.WriteString("{")
+	for i, value := range values {
+		key, _ := json.Marshal(ew.columns[i])
+		jsonValue, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			object.WriteString(",")
+		}
+		object.Write(key)
+		object.WriteString(":")
+		object.Write(jsonValue)
+	}
+	object.WriteString("}")
+
+	prefix := "\n"
+	if ew.format == ExportJSON {
+		prefix = ",\n"
+		if ew.rows == 0 {
+			prefix = "[\n"
+		}
+	} else if ew.rows == 0 {
+		prefix = ""
+	}
+	ew.rows++
+	_, err := ew.out.WriteString(prefix + object.String())
+	return err
+}
+
+//Close - complete the output (the header of the empty CSV, the end of the JSON array) and flush it.
+func (ew *exportWriter) Close() error {
+	switch ew.format {
+	case ExportCSV:
+		if ew.rows == 0 {
+			ew.csv.Write(ew.columns)
+		}
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return err
+		}
+	case ExportJSON:
+		if ew.rows == 0 {
+			ew.out.WriteString("[")
+		}
+		ew.out.WriteString("\n]\n")
+	case ExportNDJSON:
+		if ew.rows > 0 {
+			ew.out.WriteString("\n")
+		}
+	}
+	return ew.out.Flush()
+}
+
+//csvValue - the CSV field of the value, the float in its shortest representation and the time in RFC3339.
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+//ExportReadings - write every reading matching the filter (of the Metric of the DB if the filter has none) ordered by the time and the station,
+//streamed from the query to w. Return the total readings written, the export stopped by the ctx (ie: Ctrl+C) returns the ctx error.
+func (dbc *DB) ExportReadings(ctx context.Context, w io.Writer, format ExportFormat, filter Filter) (int, error) {
+	if format == ExportParquet || format == ExportGeoJSON {
+		return 0, errNotStreamed(format)
+	}
+	filter = dbc.dbFilter(filter)
+	metric := describeMetric(filter.Metric)
+	ew := newExportWriter(w, format, []string{"metric", "unit", "station_id", "station_name", "timestamp", "value"})
+	err := dbc.queryReadings(filter, "r.ts, s.station_name", Page{}, func(StationID, StationName string, ts int64, value float64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return ew.Write(metric.Name, metric.Unit, StationID, StationName, time.Unix(ts, 0).In(SGTLocation), value)
+	})
+	if err != nil {
+		return ew.rows, err
+	}
+	return ew.rows, ew.Close()
+}
+
+//Period - the period of the exported statistic.
+type Period string
+
+//The periods of the exported statistic.
+const (
+	PeriodDay   Period = "day"
+	PeriodMonth Period = "month"
+)
+
+//ExportStatisticsQuery struct - the statistic of every Period from DateFrom until DateTo, empty date for the first (or last) saved reading.
+//StationIDs empty for one row of all the stations per period, otherwise one row per station per period.
+type ExportStatisticsQuery struct {
+	DateFrom    string
+	DateTo      string
+	StationIDs  []string
+	Metric      string
+	Period      Period
+	Granularity Granularity
+}
+
+//ExportStatistics - write the statistic of every period (and station) with readings, one row at a time to w. Return the total rows written,
+//the export stopped by the ctx (ie: Ctrl+C) returns the ctx error.
+func (dbc *DB) ExportStatistics(ctx context.Context, w io.Writer, format ExportFormat, query ExportStatisticsQuery) (int, error) {
+	if len(query.Metric) == 0 {
+		query.Metric = dbc.metric().Name
+	}
+	if format == ExportParquet || format == ExportGeoJSON {
+		return 0, errNotStreamed(format)
+	}
+	if query.Period != PeriodDay && query.Period != PeriodMonth {
+		return 0, &InputError{Value: string(query.Period), Message: "please choose day or month", Err: ErrInvalidParameter}
+	}
+	metric := describeMetric(query.Metric)
+	ew := newExportWriter(w, format, []string{"metric", "unit", "period", "station_id", "count", "mean", "median", "min", "min_at", "max", "max_at"})
+
+	dateFrom, dateTo, err := dbc.exportDateRange(query)
+	if err != nil || dateFrom.IsZero() {
+		if err == nil {
+			err = ew.Close()
+		}
+		return ew.rows, err
+	}
+	stationIDs := query.StationIDs
+	if len(stationIDs) == 0 {
+		stationIDs = []string{""}
+	}
+
+	//The period is within the range, the first and last month might be partial.
+	for periodStart := dateFrom; periodStart.After(dateTo) == false; {
+		periodEnd, strPeriod := periodStart, periodStart.Format(strStandardFormat)
+		if query.Period == PeriodMonth {
+			periodEnd, strPeriod = time.Date(periodStart.Year(), periodStart.Month()+1, 0, 0, 0, 0, 0, SGTLocation), periodStart.Format("2006-01")
+			if periodEnd.After(dateTo) {
+				periodEnd = dateTo
+			}
+		}
+		for _, StationID := range stationIDs {
+			if err := ctx.Err(); err != nil {
+				return ew.rows, err
+			}
+			statQuery := StatisticQuery{DateFrom: periodStart.Format(strStandardFormat), DateTo: periodEnd.Format(strStandardFormat), StationID: StationID, Granularity: query.Granularity, Metric: query.Metric}
+			stats, err := dbc.Aggregate(statQuery.Filter())
+			if err != nil {
+				return ew.rows, err
+			}
+			if stats.Count == 0 {
+				continue
+			}
+			err = ew.Write(metric.Name, metric.Unit, strPeriod, StationID, stats.Count, stats.Mean, stats.Median,
+				stats.Min, firstOccurrence(stats.MinOccurrences), stats.Max, firstOccurrence(stats.MaxOccurrences))
+			if err != nil {
+				return ew.rows, err
+			}
+		}
+		periodStart = periodEnd.AddDate(0, 0, 1)
+	}
+	return ew.rows, ew.Close()
+}
+
+//firstOccurrence - the time of the first occurrence, nil if there is none.
+func firstOccurrence(occurrences []Occurrence) *time.Time {
+	if len(occurrences) == 0 {
+		return nil
+	}
+	return &occurrences[0].Timestamp
+}
+
+//exportDateRange - the first and last date (SGT) of the query, the empty date is the date of the first (or last) saved reading.
+//Zero time if there is no saved reading.
+func (dbc *DB) exportDateRange(query ExportStatisticsQuery) (dateFrom, dateTo time.Time, err error) {
+	if len(query.DateFrom) == 0 || len(query.DateTo) == 0 {
+		metricName := query.Metric
+		if IsDerivedMetric(metricName) {
+			metricName = MetricAirTemperature.Name
+		}
+		var minTs, maxTs sql.NullInt64
+		if err := dbc.QueryRow("SELECT MIN(ts), MAX(ts) FROM readings WHERE metric = ?", metricName).Scan(&minTs, &maxTs); err != nil {
+			return dateFrom, dateTo, dbError("query readings range", err)
+		}
+		if minTs.Valid == false {
+			return dateFrom, dateTo, nil
+		}
+		if len(query.DateFrom) == 0 {
+			query.DateFrom = time.Unix(minTs.Int64, 0).In(SGTLocation).Format(strStandardFormat)
+		}
+		if len(query.DateTo) == 0 {
+			query.DateTo = time.Unix(maxTs.Int64, 0).In(SGTLocation).Format(strStandardFormat)
+		}
+	}
+	if dateFrom, err = sgtDayStart(query.DateFrom); err != nil {
+		return dateFrom, dateTo, err
+	}
+	if dateTo, err = sgtDayStart(query.DateTo); err != nil {
+		return dateFrom, dateTo, err
+	}
+	return dateFrom, dateTo, nil
+}
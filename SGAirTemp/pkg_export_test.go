@@ -0,0 +1,149 @@
+package SGAirTemp
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExportReadings(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S2", "2024-05-01 14:00", 30)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:00", 31.5)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-02 09:00", 27)
+	saveTestReading(t, dbc, MetricRelativeHumidity, "S1", "2024-05-01 14:00", 80)
+
+	row1 := `{"metric":"air-temperature","unit":"deg C","station_id":"S1","station_name":"Station S1","timestamp":"2024-05-01T14:00:00+08:00","value":31.5}`
+	row2 := `{"metric":"air-temperature","unit":"deg C","station_id":"S2","station_name":"Station S2","timestamp":"2024-05-01T14:00:00+08:00","value":30}`
+	tests := []struct {
+		format ExportFormat
+		want   string
+	}{
+		{ExportCSV, "metric,unit,station_id,station_name,timestamp,value\n" +
+			"air-temperature,deg C,S1,Station S1,2024-05-01T14:00:00+08:00,31.5\n" +
+			"air-temperature,deg C,S2,Station S2,2024-05-01T14:00:00+08:00,30\n"},
+		{ExportJSON, "[\n" + row1 + ",\n" + row2 + "\n]\n"},
+		{ExportNDJSON, row1 + "\n" + row2 + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			total, err := dbc.ExportReadings(context.Background(), &buf, tt.format, Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01"})
+			if err != nil || total != 2 {
+				t.Fatalf("ExportReadings = %v, %v, want 2 rows", total, err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output:\n%v\nwant:\n%v", buf.String(), tt.want)
+			}
+			if tt.format == ExportJSON {
+				var rows []map[string]interface{}
+				if err := json.Unmarshal(buf.Bytes(), &rows); err != nil || len(rows) != 2 {
+					t.Errorf("JSON array = %v, %v, want 2 objects", rows, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExportReadingsEmpty(t *testing.T) {
+	dbc := newTestDB(t)
+	tests := []struct {
+		format ExportFormat
+		want   string
+	}{
+		//The header is still written, the JSON array is still valid.
+		{ExportCSV, "metric,unit,station_id,station_name,timestamp,value\n"},
+		{ExportJSON, "[\n]\n"},
+		{ExportNDJSON, ""},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if total, err := dbc.ExportReadings(context.Background(), &buf, tt.format, Filter{}); err != nil || total != 0 || buf.String() != tt.want {
+			t.Errorf("%v of no reading = %q, %v, %v, want %q", tt.format, buf.String(), total, err, tt.want)
+		}
+	}
+}
+
+func TestExportStatistics(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-04-30 14:00", 32)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:00", 30)
+	saveTestReading(t, dbc, MetricAirTemperature, "S2", "2024-05-01 15:00", 26)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-03 14:00", 28)
+
+	tests := []struct {
+		name  string
+		query ExportStatisticsQuery
+		//want - the period, station_id, count, mean, min and max of every row.
+		want []string
+	}{
+		{"day", ExportStatisticsQuery{Period: PeriodDay, Granularity: GranularityMinute},
+			[]string{"2024-04-30,,1,32,32,32", "2024-05-01,,2,28,26,30", "2024-05-03,,1,28,28,28"}},
+		{"month", ExportStatisticsQuery{Period: PeriodMonth, Granularity: GranularityMinute},
+			[]string{"2024-04,,1,32,32,32", "2024-05,,3,28,26,30"}},
+		{"stations", ExportStatisticsQuery{DateFrom: "2024-05-01", DateTo: "2024-05-31", StationIDs: []string{"S1", "S2"}, Period: PeriodMonth, Granularity: GranularityMinute},
+			[]string{"2024-05,S1,2,29,28,30", "2024-05,S2,1,26,26,26"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			total, err := dbc.ExportStatistics(context.Background(), &buf, ExportCSV, tt.query)
+			if err != nil || total != len(tt.want) {
+				t.Fatalf("ExportStatistics = %v, %v, want %v rows", total, err, len(tt.want))
+			}
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil || len(records) != len(tt.want)+1 {
+				t.Fatalf("CSV = %v, %v, want the header and %v rows", records, err, len(tt.want))
+			}
+			if header := strings.Join(records[0], ","); header != "metric,unit,period,station_id,count,mean,median,min,min_at,max,max_at" {
+				t.Errorf("header = %v", header)
+			}
+			for i, record := range records[1:] {
+				if got := strings.Join([]string{record[2], record[3], record[4], record[5], record[7], record[9]}, ","); got != tt.want[i] {
+					t.Errorf("row %v = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := dbc.ExportStatistics(context.Background(), &bytes.Buffer{}, ExportCSV, ExportStatisticsQuery{Period: "week"}); errors.Is(err, ErrInvalidParameter) == false {
+		t.Errorf("error of the week period = %v, want ErrInvalidParameter", err)
+	}
+}
+
+func TestExportNotStreamed(t *testing.T) {
+	dbc := newTestDB(t)
+	for _, format := range []ExportFormat{ExportParquet, ExportGeoJSON} {
+		if _, err := dbc.ExportReadings(context.Background(), &bytes.Buffer{}, format, Filter{}); errors.Is(err, ErrInvalidParameter) == false {
+			t.Errorf("ExportReadings as %v = %v, want ErrInvalidParameter", format, err)
+		}
+		if _, err := dbc.ExportStatistics(context.Background(), &bytes.Buffer{}, format, ExportStatisticsQuery{Period: PeriodDay}); errors.Is(err, ErrInvalidParameter) == false {
+			t.Errorf("ExportStatistics as %v = %v, want ErrInvalidParameter", format, err)
+		}
+	}
+	if _, err := ExportFormatByName("xml"); errors.Is(err, ErrInvalidParameter) == false {
+		t.Errorf("ExportFormatByName(xml) = %v, want ErrInvalidParameter", err)
+	}
+	if format, err := ExportFormatByName(" NDJSON "); err != nil || format != ExportNDJSON {
+		t.Errorf("ExportFormatByName(NDJSON) = %v, %v, want ndjson", format, err)
+	}
+}
+
+func TestExportCancel(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:00", 30)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-02 14:00", 31)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if total, err := dbc.ExportReadings(ctx, &bytes.Buffer{}, ExportNDJSON, Filter{}); errors.Is(err, context.Canceled) == false || total != 0 {
+		t.Errorf("ExportReadings after the cancel = %v, %v, want context.Canceled", total, err)
+	}
+	if total, err := dbc.ExportStatistics(ctx, &bytes.Buffer{}, ExportNDJSON, ExportStatisticsQuery{Period: PeriodDay}); errors.Is(err, context.Canceled) == false || total != 0 {
+		t.Errorf("ExportStatistics after the cancel = %v, %v, want context.Canceled", total, err)
+	}
+}
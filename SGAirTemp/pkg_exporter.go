@@ -0,0 +1,246 @@
+package SGAirTemp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//fetchDurationBuckets - the upper bounds (seconds) of the fetch duration histogram, the API call is retried up to 30 seconds apart.
+var fetchDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+//Exporter struct - the Prometheus metrics (text exposition format) of the latest saved reading of every station, and of the API calls observed by ObserveFetch.
+//The latest readings are read from the DB on every scrape, so they are the same as the readings saved by any command.
+type Exporter struct {
+	dbc *DB
+	//Metrics - the Metrics of the latest readings, all the Metrics with saved readings if it is empty.
+	Metrics []Metric
+
+	mu      sync.Mutex
+	fetches map[string]*fetchStats
+}
+
+//fetchStats struct - the API calls of one Metric.
+type fetchStats struct {
+	total       int
+	errors      map[string]int
+	buckets     []int
+	durationSum float64
+	lastSuccess time.Time
+}
+
+//NewExporter - create the Exporter of the DB for the Metrics, all the Metrics if none is given.
+func NewExporter(dbc *DB, metrics ...Metric) *Exporter {
+	return &Exporter{dbc: dbc, Metrics: metrics, fetches: map[string]*fetchStats{}}
+}
+
+//ObserveFetch - record one API call of the Metric, with its duration and error (nil for success).
+func (e *Exporter) ObserveFetch(metric Metric, elapsed time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats, ok := e.fetches[metric.Name]
+	if ok == false {
+		stats = &fetchStats{errors: map[string]int{}, buckets: make([]int, len(fetchDurationBuckets))}
+		e.fetches[metric.Name] = stats
+	}
+	stats.total++
+	stats.durationSum += elapsed.Seconds()
+	for i, le := range fetchDurationBuckets {
+		if elapsed.Seconds() <= le {
+			stats.buckets[i]++
+		}
+	}
+	if err != nil {
+		stats.errors[fetchErrorKind(err)]++
+		return
+	}
+	stats.lastSuccess = time.Now()
+}
+
+//fetchErrorKind - the kind label of the API error: empty, unavailable or other (ie: the database failure).
+func fetchErrorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrAPIEmptyBody):
+		return "empty"
+	case errors.Is(err, ErrAPIUnavailable):
+		return "unavailable"
+	}
+	return "other"
+}
+
+//latestReading struct - the latest saved reading of the station.
+type latestReading struct {
+	Station   Station
+	Timestamp int64
+	Value     float64
+}
+
+//latestReadings - the latest saved reading of every station for the Metric, ordered by the station name.
+//The latest time of every station is grouped once (on the primary key), then joined back to its reading, it is called on every scrape.
+//CROSS JOIN keeps the grouped stations as the outer loop in SQLite, so only one reading per station is looked up.
+func (dbc *DB) latestReadings(metric Metric) ([]latestReading, error) {
+	readings := []latestReading{}
+	rows, err := dbc.Query("SELECT r.station_id, s.station_name, IFNULL(s.loc_latitude, 0), IFNULL(s.loc_longitude, 0), r.ts, r.value "+
+		"FROM (SELECT station_id, MAX(ts) ts FROM readings WHERE metric = ? GROUP BY station_id) latest "+
+		"CROSS JOIN readings r ON r.metric = ? AND r.station_id = latest.station_id AND r.ts = latest.ts "+
+		"INNER JOIN stations s ON s.station_id = r.station_id "+
+		"ORDER BY s.station_name, r.station_id", metric.Name, metric.Name)
+	if err != nil {
+		return readings, dbError("query latest readings", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		reading := latestReading{}
+		if err := rows.Scan(&reading.Station.StationID, &reading.Station.StationName, &reading.Station.Location.Latitude, &reading.Station.Location.Longitude, &reading.Timestamp, &reading.Value); err != nil {
+			return readings, dbError("query latest readings", err)
+		}
+		readings = append(readings, reading)
+	}
+	return readings, dbError("query latest readings", rows.Err())
+}
+
+//metrics - the Metrics of the Exporter, all the Metrics (and MetricWBGT) if none is given.
+func (e *Exporter) metrics() []Metric {
+	if len(e.Metrics) > 0 {
+		return e.Metrics
+	}
+	return append(Metrics(), MetricWBGT)
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body bytes.Buffer
+	if err := e.WriteMetrics(&body, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(body.Bytes())
+}
+
+//WriteMetrics - write all the metrics in the Prometheus text exposition format, the ingest lag is counted until now.
+func (e *Exporter) WriteMetrics(buf *bytes.Buffer, now time.Time) error {
+	type metricReadings struct {
+		metric   Metric
+		readings []latestReading
+	}
+	latest := []metricReadings{}
+	for _, metric := range e.metrics() {
+		readings, err := e.dbc.latestReadings(metric)
+		if err != nil {
+			return err
+		}
+		if len(readings) > 0 {
+			latest = append(latest, metricReadings{metric: metric, readings: readings})
+		}
+	}
+
+	writeMetricHeader(buf, "sgairtemp_station_reading", "gauge", "The latest saved reading of the station, in the unit of the metric.")
+	for _, m := range latest {
+		for _, reading := range m.readings {
+			writeSample(buf, "sgairtemp_station_reading", stationLabels(m.metric, reading.Station), reading.Value)
+		}
+	}
+	writeMetricHeader(buf, "sgairtemp_station_reading_timestamp_seconds", "gauge", "The Unix time of the latest saved reading of the station.")
+	for _, m := range latest {
+		for _, reading := range m.readings {
+			writeSample(buf, "sgairtemp_station_reading_timestamp_seconds", stationLabels(m.metric, reading.Station), float64(reading.Timestamp))
+		}
+	}
+	writeMetricHeader(buf, "sgairtemp_ingest_lag_seconds", "gauge", "The seconds since the newest saved reading of the metric.")
+	for _, m := range latest {
+		newest := int64(0)
+		for _, reading := range m.readings {
+			if reading.Timestamp > newest {
+				newest = reading.Timestamp
+			}
+		}
+		writeSample(buf, "sgairtemp_ingest_lag_seconds", []string{"metric", m.metric.Name}, now.Sub(time.Unix(newest, 0)).Seconds())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	metricNames := []string{}
+	for name := range e.fetches {
+		metricNames = append(metricNames, name)
+	}
+	sort.Strings(metricNames)
+
+	writeMetricHeader(buf, "sgairtemp_api_requests_total", "counter", "The API calls made by the polling, including the failed calls.")
+	for _, name := range metricNames {
+		writeSample(buf, "sgairtemp_api_requests_total", []string{"metric", name}, float64(e.fetches[name].total))
+	}
+	writeMetricHeader(buf, "sgairtemp_api_errors_total", "counter", "The failed API calls by the kind of error: empty, unavailable or other.")
+	for _, name := range metricNames {
+		kinds := []string{}
+		for kind := range e.fetches[name].errors {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			writeSample(buf, "sgairtemp_api_errors_total", []string{"metric", name, "kind", kind}, float64(e.fetches[name].errors[kind]))
+		}
+	}
+	writeMetricHeader(buf, "sgairtemp_fetch_duration_seconds", "histogram", "The duration of the API call and saving its readings, including the retries.")
+	for _, name := range metricNames {
+		stats := e.fetches[name]
+		for i, le := range fetchDurationBuckets {
+			writeSample(buf, "sgairtemp_fetch_duration_seconds_bucket", []string{"metric", name, "le", formatFloat(le)}, float64(stats.buckets[i]))
+		}
+		writeSample(buf, "sgairtemp_fetch_duration_seconds_bucket", []string{"metric", name, "le", "+Inf"}, float64(stats.total))
+		writeSample(buf, "sgairtemp_fetch_duration_seconds_sum", []string{"metric", name}, stats.durationSum)
+		writeSample(buf, "sgairtemp_fetch_duration_seconds_count", []string{"metric", name}, float64(stats.total))
+	}
+	writeMetricHeader(buf, "sgairtemp_last_success_timestamp_seconds", "gauge", "The Unix time of the last successful API call of the metric.")
+	for _, name := range metricNames {
+		if lastSuccess := e.fetches[name].lastSuccess; lastSuccess.IsZero() == false {
+			writeSample(buf, "sgairtemp_last_success_timestamp_seconds", []string{"metric", name}, float64(lastSuccess.Unix()))
+		}
+	}
+	return nil
+}
+
+//stationLabels - the labels of the station reading.
+func stationLabels(metric Metric, station Station) []string {
+	return []string{
+		"metric", metric.Name,
+		"unit", metric.Unit,
+		"station_id", station.StationID,
+		"station_name", station.StationName,
+		"latitude", formatFloat(station.Location.Latitude),
+		"longitude", formatFloat(station.Location.Longitude),
+	}
+}
+
+//writeMetricHeader - the HELP and TYPE lines of the metric.
+func writeMetricHeader(buf *bytes.Buffer, name, metricType, help string) {
+	fmt.Fprintf(buf, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, metricType)
+}
+
+//writeSample - one sample line, labels are the pairs of the label name and value.
+func writeSample(buf *bytes.Buffer, name string, labels []string, value float64) {
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		pairs := []string{}
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+`="`+escapeLabelValue(labels[i+1])+`"`)
+		}
+		buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	buf.WriteString(" " + formatFloat(value) + "\n")
+}
+
+//escapeLabelValue - the backslash, double quote and new line escaped for the label value.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+//formatFloat - the shortest representation of the value.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
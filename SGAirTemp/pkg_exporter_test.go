@@ -0,0 +1,142 @@
+package SGAirTemp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatestReadings(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:00", 30)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:01", 30.5)
+	saveTestReading(t, dbc, MetricAirTemperature, "S2", "2024-05-01 13:00", 29)
+	//The newer reading of the other metric is not the latest.
+	saveTestReading(t, dbc, MetricRelativeHumidity, "S2", "2024-05-01 15:00", 80)
+
+	readings, err := dbc.latestReadings(MetricAirTemperature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []latestReading{
+		{Station: Station{StationID: "S1", StationName: "Station S1", Location: Location{Latitude: 1.35, Longitude: 103.8}}, Timestamp: sgtTime(t, "2024-05-01 14:01").Unix(), Value: 30.5},
+		{Station: Station{StationID: "S2", StationName: "Station S2", Location: Location{Latitude: 1.35, Longitude: 103.8}}, Timestamp: sgtTime(t, "2024-05-01 13:00").Unix(), Value: 29},
+	}
+	if len(readings) != len(want) {
+		t.Fatalf("latest readings = %+v, want %+v", readings, want)
+	}
+	for i := range want {
+		if readings[i] != want[i] {
+			t.Errorf("latest reading %v = %+v, want %+v", i, readings[i], want[i])
+		}
+	}
+	if readings, err := dbc.latestReadings(MetricRainfall); err != nil || len(readings) != 0 {
+		t.Errorf("latest readings without any saved = %+v, %v, want none", readings, err)
+	}
+}
+
+func TestExporterWriteMetrics(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:00", 30)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:01", 30.5)
+	saveTestReading(t, dbc, MetricAirTemperature, "S2", "2024-05-01 14:00", 29)
+	//The label value is escaped.
+	if _, err := dbc.Exec(sqlUpsertStation, "S1", `Station "One"`, 1.35, 103.8); err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := NewExporter(dbc, MetricAirTemperature, MetricRainfall)
+	exporter.ObserveFetch(MetricAirTemperature, 500*time.Millisecond, nil)
+	exporter.ObserveFetch(MetricAirTemperature, 2*time.Second, &APIError{URL: "air-temperature", Kind: ErrAPIUnavailable})
+	exporter.ObserveFetch(MetricRainfall, 50*time.Millisecond, &APIError{URL: "rainfall", Kind: ErrAPIEmptyBody})
+
+	var buf bytes.Buffer
+	if err := exporter.WriteMetrics(&buf, sgtTime(t, "2024-05-01 14:03")); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	s1Labels := `{metric="air-temperature",unit="deg C",station_id="S1",station_name="Station \"One\"",latitude="1.35",longitude="103.8"}`
+	s2Labels := `{metric="air-temperature",unit="deg C",station_id="S2",station_name="Station S2",latitude="1.35",longitude="103.8"}`
+	for _, line := range []string{
+		"# HELP sgairtemp_station_reading The latest saved reading of the station, in the unit of the metric.",
+		"# TYPE sgairtemp_station_reading gauge",
+		"sgairtemp_station_reading" + s1Labels + " 30.5",
+		"sgairtemp_station_reading" + s2Labels + " 29",
+		"# TYPE sgairtemp_station_reading_timestamp_seconds gauge",
+		"sgairtemp_station_reading_timestamp_seconds" + s1Labels + " 1714543260",
+		"sgairtemp_station_reading_timestamp_seconds" + s2Labels + " 1714543200",
+		"# TYPE sgairtemp_ingest_lag_seconds gauge",
+		`sgairtemp_ingest_lag_seconds{metric="air-temperature"} 120`,
+		"# TYPE sgairtemp_api_requests_total counter",
+		`sgairtemp_api_requests_total{metric="air-temperature"} 2`,
+		`sgairtemp_api_requests_total{metric="rainfall"} 1`,
+		"# TYPE sgairtemp_api_errors_total counter",
+		`sgairtemp_api_errors_total{metric="air-temperature",kind="unavailable"} 1`,
+		`sgairtemp_api_errors_total{metric="rainfall",kind="empty"} 1`,
+		"# TYPE sgairtemp_fetch_duration_seconds histogram",
+		`sgairtemp_fetch_duration_seconds_bucket{metric="air-temperature",le="0.25"} 0`,
+		`sgairtemp_fetch_duration_seconds_bucket{metric="air-temperature",le="0.5"} 1`,
+		`sgairtemp_fetch_duration_seconds_bucket{metric="air-temperature",le="2.5"} 2`,
+		`sgairtemp_fetch_duration_seconds_bucket{metric="air-temperature",le="+Inf"} 2`,
+		`sgairtemp_fetch_duration_seconds_sum{metric="air-temperature"} 2.5`,
+		`sgairtemp_fetch_duration_seconds_count{metric="air-temperature"} 2`,
+		"# TYPE sgairtemp_last_success_timestamp_seconds gauge",
+	} {
+		if strings.Contains(output, line+"\n") == false {
+			t.Errorf("output has no line %v", line)
+		}
+	}
+	//The rainfall has no saved reading and no successful call.
+	for _, notWanted := range []string{`sgairtemp_station_reading{metric="rainfall"`, `sgairtemp_ingest_lag_seconds{metric="rainfall"}`, `sgairtemp_last_success_timestamp_seconds{metric="rainfall"}`} {
+		if strings.Contains(output, notWanted) {
+			t.Errorf("output has %v", notWanted)
+		}
+	}
+	if strings.Contains(output, `sgairtemp_last_success_timestamp_seconds{metric="air-temperature"} `) == false {
+		t.Error("output has no last success of air-temperature")
+	}
+
+	//Every sample follows the HELP and TYPE lines of its metric.
+	declared := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		fields := strings.Fields(line)
+		if strings.HasPrefix(line, "# TYPE ") {
+			declared[fields[2]] = true
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		name := strings.SplitN(fields[0], "{", 2)[0]
+		family := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_bucket"), "_sum"), "_count")
+		if declared[name] == false && declared[family] == false {
+			t.Errorf("sample %v before the TYPE of its metric", line)
+		}
+	}
+}
+
+func TestExporterServeHTTP(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:00", 30)
+	recorder := httptest.NewRecorder()
+	NewExporter(dbc).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("status %v of %v, want 200 of the text exposition format", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	if strings.Contains(recorder.Body.String(), `sgairtemp_station_reading{metric="air-temperature"`) == false {
+		t.Errorf("body = %v, want the reading of S1", recorder.Body.String())
+	}
+
+	//The database error is a 500.
+	if _, err := dbc.Exec("DROP TABLE readings"); err != nil {
+		t.Fatal(err)
+	}
+	recorder = httptest.NewRecorder()
+	NewExporter(dbc).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("status without the readings table = %v, want 500", recorder.Code)
+	}
+}
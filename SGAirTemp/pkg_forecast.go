@@ -0,0 +1,426 @@
+package SGAirTemp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//ForecastSource struct - a weather forecast API endpoint (environment/NAME), Label is also accepted as the name.
+type ForecastSource struct {
+	Name  string
+	Label string
+}
+
+//The weather forecast APIs.
+var (
+	Forecast2Hour  = ForecastSource{Name: "2-hour-weather-forecast", Label: "2-hour"}
+	Forecast24Hour = ForecastSource{Name: "24-hour-weather-forecast", Label: "24-hour"}
+	Forecast4Day   = ForecastSource{Name: "4-day-weather-forecast", Label: "4-day"}
+)
+
+//ForecastSources - all the supported weather forecast APIs.
+func ForecastSources() []ForecastSource {
+	return []ForecastSource{Forecast2Hour, Forecast24Hour, Forecast4Day}
+}
+
+//ForecastSourceByName - the supported weather forecast API with the name or label.
+func ForecastSourceByName(name string) (ForecastSource, error) {
+	names := []string{}
+	for _, src := range ForecastSources() {
+		if src.Name == strings.TrimSpace(name) || src.Label == strings.TrimSpace(name) {
+			return src, nil
+		}
+		names = append(names, src.Label)
+	}
+	return ForecastSource{}, &InputError{Value: name, Message: "please choose " + strings.Join(names, ", "), Err: ErrUnknownMetric}
+}
+
+//NationalArea - the area of the forecast for the whole Singapore (the 24-hour general and the 4-day forecasts).
+const NationalArea = "national"
+
+//ForecastRange struct - the low and high of the forecasted value.
+type ForecastRange struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+//ForecastWind struct - the forecasted wind speed (km/h) and direction.
+type ForecastWind struct {
+	Speed     *ForecastRange `json:"speed,omitempty"`
+	Direction string         `json:"direction"`
+}
+
+//ValidPeriod struct - the start and end (RFC3339) of the forecast.
+type ValidPeriod struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+//ForecastEntry struct - one forecast of the 24-hour general, the 2-hour area (Area) or the 4-day (Date, Timestamp), the unused fields are empty.
+type ForecastEntry struct {
+	Area             string         `json:"area,omitempty"`
+	Date             string         `json:"date,omitempty"`
+	Timestamp        string         `json:"timestamp,omitempty"`
+	Forecast         string         `json:"forecast"`
+	RelativeHumidity *ForecastRange `json:"relative_humidity,omitempty"`
+	Temperature      *ForecastRange `json:"temperature,omitempty"`
+	Wind             *ForecastWind  `json:"wind,omitempty"`
+}
+
+//ForecastPeriod struct - the forecast of every region for the period of the 24-hour forecast.
+type ForecastPeriod struct {
+	Time    ValidPeriod       `json:"time"`
+	Regions map[string]string `json:"regions"`
+}
+
+//ForecastData struct - one forecast issuance (Timestamp), in the shape of any forecast API:
+//2-hour uses ValidPeriod and Forecasts (per area), 24-hour uses ValidPeriod, General and Periods, 4-day uses Forecasts (per date).
+type ForecastData struct {
+	Timestamp       string           `json:"timestamp"`
+	UpdateTimestamp string           `json:"update_timestamp"`
+	ValidPeriod     *ValidPeriod     `json:"valid_period,omitempty"`
+	General         *ForecastEntry   `json:"general,omitempty"`
+	Periods         []ForecastPeriod `json:"periods,omitempty"`
+	Forecasts       []ForecastEntry  `json:"forecasts,omitempty"`
+}
+
+//ForecastResponse struct - response body of the weather forecast API, AreaMetadata is only returned by the 2-hour forecast.
+type ForecastResponse struct {
+	AreaMetadata []RegionMetadata `json:"area_metadata,omitempty"`
+	Items        []ForecastData   `json:"items"`
+}
+
+//GetForecasts - call the weather forecast API for the Date (YYYY-MM-DD) and Time (HH:mm), empty Time for every issuance of the day.
+func (c *APIClient) GetForecasts(ctx context.Context, source ForecastSource, ValDate, ValTime string) (response ForecastResponse, err error) {
+	err = c.getEndpoint(ctx, source.Name, ValDate, ValTime, &response)
+	return response, err
+}
+
+//CallForecastAPIAndSave - call the weather forecast API and save every issuance to the Database, return the total forecasts saved.
+func (dbc *DB) CallForecastAPIAndSave(ctx context.Context, source ForecastSource, ValDate, ValTime string) (int, error) {
+	response, err := dbc.apiClient().GetForecasts(ctx, source, ValDate, ValTime)
+	if err != nil {
+		return 0, err
+	}
+	return dbc.SaveForecastResponse(source, response)
+}
+
+//SaveForecastResponse - save the areas and every forecast issuance of the response in a single transaction, return the total forecasts saved.
+func (dbc *DB) SaveForecastResponse(source ForecastSource, response ForecastResponse) (int, error) {
+	tx, err := dbc.Begin()
+	if err != nil {
+		return 0, dbError("begin ingestion", err)
+	}
+	defer tx.Rollback()
+
+	totalSaved, err := saveForecastResponse(tx, source, response)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, dbError("commit ingestion", err)
+	}
+	return totalSaved, nil
+}
+
+//forecastRow - one row of the forecasts table.
+type forecastRow struct {
+	area      string
+	validFrom time.Time
+	validTo   time.Time
+	entry     ForecastEntry
+}
+
+//forecastRows - the rows of the forecast issuance, in the shape of the source.
+func forecastRows(source ForecastSource, item ForecastData) ([]forecastRow, error) {
+	rows := []forecastRow{}
+	validPeriod := func(period ValidPeriod) (time.Time, time.Time, error) {
+		validFrom, err := time.Parse(time.RFC3339, period.Start)
+		if err != nil {
+			return validFrom, validFrom, &InputError{Value: period.Start, Message: "forecast valid period is not RFC3339", Err: ErrInvalidDateFormat}
+		}
+		validTo, err := time.Parse(time.RFC3339, period.End)
+		if err != nil {
+			return validFrom, validTo, &InputError{Value: period.End, Message: "forecast valid period is not RFC3339", Err: ErrInvalidDateFormat}
+		}
+		return validFrom, validTo, nil
+	}
+
+	switch source.Name {
+	case Forecast4Day.Name:
+		for _, entry := range item.Forecasts {
+			validFrom, err := time.ParseInLocation(strStandardFormat, entry.Date, SGTLocation)
+			if err != nil {
+				return rows, &InputError{Value: entry.Date, Message: "forecast date is not YYYY-MM-DD", Err: ErrInvalidDateFormat}
+			}
+			rows = append(rows, forecastRow{area: NationalArea, validFrom: validFrom, validTo: validFrom.AddDate(0, 0, 1), entry: entry})
+		}
+	default:
+		if item.ValidPeriod == nil {
+			return rows, &InputError{Value: item.Timestamp, Message: "forecast has no valid period", Err: ErrInvalidDateFormat}
+		}
+		validFrom, validTo, err := validPeriod(*item.ValidPeriod)
+		if err != nil {
+			return rows, err
+		}
+		if item.General != nil {
+			rows = append(rows, forecastRow{area: NationalArea, validFrom: validFrom, validTo: validTo, entry: *item.General})
+		}
+		for _, entry := range item.Forecasts {
+			rows = append(rows, forecastRow{area: entry.Area, validFrom: validFrom, validTo: validTo, entry: entry})
+		}
+		for _, period := range item.Periods {
+			periodFrom, periodTo, err := validPeriod(period.Time)
+			if err != nil {
+				return rows, err
+			}
+			for region, forecast := range period.Regions {
+				rows = append(rows, forecastRow{area: region, validFrom: periodFrom, validTo: periodTo, entry: ForecastEntry{Forecast: forecast}})
+			}
+		}
+	}
+	return rows, nil
+}
+
+//saveForecastResponse - upsert the areas and the forecasts of the response on the transaction, the issuance updated later replaces the saved one.
+func saveForecastResponse(tx *sql.Tx, source ForecastSource, response ForecastResponse) (totalSaved int, err error) {
+	for _, area := range response.AreaMetadata {
+		if _, err := tx.Exec("INSERT INTO forecast_areas (area, loc_latitude, loc_longitude) VALUES (?, ?, ?) "+
+			"ON CONFLICT (area) DO UPDATE SET loc_latitude = excluded.loc_latitude, loc_longitude = excluded.loc_longitude",
+			area.Name, area.LabelLocation.Latitude, area.LabelLocation.Longitude); err != nil {
+			return 0, dbError("upsert forecast area", err)
+		}
+	}
+
+	stmtForecast, err := tx.Prepare("INSERT INTO forecasts (source, issued_ts, issued_sgt, area, valid_from, valid_to, forecast, " +
+		"temp_low, temp_high, humidity_low, humidity_high, wind_speed_low, wind_speed_high, wind_direction) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) " +
+		"ON CONFLICT (source, issued_ts, area, valid_from) DO UPDATE SET valid_to = excluded.valid_to, forecast = excluded.forecast, " +
+		"temp_low = excluded.temp_low, temp_high = excluded.temp_high, humidity_low = excluded.humidity_low, humidity_high = excluded.humidity_high, " +
+		"wind_speed_low = excluded.wind_speed_low, wind_speed_high = excluded.wind_speed_high, wind_direction = excluded.wind_direction")
+	if err != nil {
+		return 0, dbError("prepare forecast upsert", err)
+	}
+	defer stmtForecast.Close()
+
+	for _, item := range response.Items {
+		issued, err := time.Parse(time.RFC3339, item.Timestamp)
+		if err != nil {
+			return 0, &InputError{Value: item.Timestamp, Message: "forecast timestamp is not RFC3339", Err: ErrInvalidDateFormat}
+		}
+		rows, err := forecastRows(source, item)
+		if err != nil {
+			return 0, err
+		}
+		for _, row := range rows {
+			tempLow, tempHigh := nullRange(row.entry.Temperature)
+			humidityLow, humidityHigh := nullRange(row.entry.RelativeHumidity)
+			var windLow, windHigh interface{}
+			var windDirection interface{}
+			if row.entry.Wind != nil {
+				windLow, windHigh = nullRange(row.entry.Wind.Speed)
+				windDirection = row.entry.Wind.Direction
+			}
+			if _, err := stmtForecast.Exec(source.Name, issued.Unix(), issued.In(SGTLocation).Format(time.RFC3339), row.area, row.validFrom.Unix(), row.validTo.Unix(), row.entry.Forecast,
+				tempLow, tempHigh, humidityLow, humidityHigh, windLow, windHigh, windDirection); err != nil {
+				return 0, dbError("upsert forecast", err)
+			}
+			totalSaved++
+		}
+	}
+	return totalSaved, nil
+}
+
+//nullRange - the low and high of the range, NULL if the range is not given.
+func nullRange(r *ForecastRange) (interface{}, interface{}) {
+	if r == nil {
+		return nil, nil
+	}
+	return r.Low, r.High
+}
+
+//ForecastVerificationQuery struct - the forecasts verified against the observed temperature.
+type ForecastVerificationQuery struct {
+	//Source - the forecast API name or label, empty for all the forecasts with the temperature range (24-hour and 4-day).
+	Source string
+	//DateFrom - first date (YYYY-MM-DD) of the forecast valid period, empty for no bound.
+	DateFrom string
+	//DateTo - last date (YYYY-MM-DD) of the forecast valid period, empty for no bound.
+	DateTo string
+	//MinReadings - the forecast is only verified when the valid period has at least this total observed readings, default is 1.
+	MinReadings int
+}
+
+//ForecastVerification struct - the accuracy of the forecasted temperature range of the source for the lead time,
+//the time between the issuance and the start of the valid period, in buckets of 24 hours (LeadHours is the bucket start).
+//The forecast is a hit when the observed lowest and highest temperature of all stations over the valid period are inside the forecasted range.
+//The error and bias are the forecast minus the observed, the positive bias means the forecast is warmer than observed.
+type ForecastVerification struct {
+	Source    string
+	LeadHours int
+	Forecasts int
+	Hits      int
+	HitRate   float64
+	MAELow    float64
+	MAEHigh   float64
+	BiasLow   float64
+	BiasHigh  float64
+	//Unverified - the forecasts skipped since the valid period has not ended yet or has too few observed readings.
+	Unverified int
+}
+
+//forecastLeadBucket - the lead time bucket of the forecast, in hours.
+const forecastLeadBucket = 24
+
+//VerifyForecasts - compare the forecasted temperature range with the observed air temperature readings,
+//grouped by the source and the lead time. Only the national forecasts with the temperature range can be verified.
+func (dbc *DB) VerifyForecasts(query ForecastVerificationQuery) ([]ForecastVerification, error) {
+	type forecastTemperature struct {
+		source            string
+		issuedTs          int64
+		validFrom         int64
+		validTo           int64
+		tempLow, tempHigh float64
+	}
+	verifications := []ForecastVerification{}
+	if query.MinReadings < 1 {
+		query.MinReadings = 1
+	}
+
+	WhereCondition := []string{"area = ?", "temp_low IS NOT NULL", "temp_high IS NOT NULL"}
+	args := []interface{}{NationalArea}
+	if len(query.Source) > 0 {
+		source, err := ForecastSourceByName(query.Source)
+		if err != nil {
+			return verifications, err
+		}
+		WhereCondition = append(WhereCondition, "source = ?")
+		args = append(args, source.Name)
+	}
+	if len(query.DateFrom) > 0 {
+		DateFrom, err := sgtDayStart(query.DateFrom)
+		if err != nil {
+			return verifications, err
+		}
+		WhereCondition = append(WhereCondition, "valid_from >= ?")
+		args = append(args, DateFrom.Unix())
+	}
+	if len(query.DateTo) > 0 {
+		DateTo, err := sgtDayStart(query.DateTo)
+		if err != nil {
+			return verifications, err
+		}
+		WhereCondition = append(WhereCondition, "valid_from < ?")
+		args = append(args, DateTo.AddDate(0, 0, 1).Unix())
+	}
+
+	rows, err := dbc.Query(fmt.Sprintf("SELECT source, issued_ts, valid_from, valid_to, temp_low, temp_high FROM forecasts WHERE %v ORDER BY source, issued_ts, valid_from", strings.Join(WhereCondition, " AND ")), args...)
+	if err != nil {
+		return verifications, dbError("query forecasts", err)
+	}
+	forecasts := []forecastTemperature{}
+	for rows.Next() {
+		f := forecastTemperature{}
+		if err := rows.Scan(&f.source, &f.issuedTs, &f.validFrom, &f.validTo, &f.tempLow, &f.tempHigh); err != nil {
+			rows.Close()
+			return verifications, dbError("query forecasts", err)
+		}
+		forecasts = append(forecasts, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return verifications, dbError("query forecasts", err)
+	}
+
+	stmtObserved, err := dbc.Prepare("SELECT IFNULL(MIN(value), 0), IFNULL(MAX(value), 0), COUNT(value) FROM readings WHERE metric = ? AND ts >= ? AND ts < ?")
+	if err != nil {
+		return verifications, dbError("prepare observed temperature", err)
+	}
+	defer stmtObserved.Close()
+
+	byKey := map[string]*ForecastVerification{}
+	now := time.Now().Unix()
+	for _, f := range forecasts {
+		leadHours := int((f.validFrom-f.issuedTs)/3600) / forecastLeadBucket * forecastLeadBucket
+		if f.validFrom < f.issuedTs {
+			leadHours = 0
+		}
+		key := fmt.Sprintf("%v|%06d", f.source, leadHours)
+		v, ok := byKey[key]
+		if ok == false {
+			v = &ForecastVerification{Source: f.source, LeadHours: leadHours}
+			byKey[key] = v
+		}
+
+		var observedLow, observedHigh float64
+		var totalObserved int
+		if f.validTo <= now {
+			if err := stmtObserved.QueryRow(MetricAirTemperature.Name, f.validFrom, f.validTo).Scan(&observedLow, &observedHigh, &totalObserved); err != nil {
+				return verifications, dbError("query observed temperature", err)
+			}
+		}
+		if totalObserved < query.MinReadings {
+			v.Unverified++
+			continue
+		}
+
+		v.Forecasts++
+		if observedLow >= f.tempLow && observedHigh <= f.tempHigh {
+			v.Hits++
+		}
+		v.MAELow += abs(f.tempLow - observedLow)
+		v.MAEHigh += abs(f.tempHigh - observedHigh)
+		v.BiasLow += f.tempLow - observedLow
+		v.BiasHigh += f.tempHigh - observedHigh
+	}
+
+	keys := []string{}
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := *byKey[key]
+		if v.Forecasts > 0 {
+			total := float64(v.Forecasts)
+			v.HitRate = float64(v.Hits) / total
+			v.MAELow, v.MAEHigh, v.BiasLow, v.BiasHigh = v.MAELow/total, v.MAEHigh/total, v.BiasLow/total, v.BiasHigh/total
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications, nil
+}
+
+//abs - the absolute value.
+func abs(value float64) float64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+//PrintForecastVerification - print the forecast verification report, one line per source and lead time.
+func PrintForecastVerification(verifications []ForecastVerification) {
+	if len(verifications) == 0 {
+		fmt.Println("No forecast with the temperature range saved yet, please fetch the 24-hour or 4-day forecast first.")
+		return
+	}
+	fmt.Printf("%-8s | %-8s | %9s | %8s | %7s | %8s | %8s | %9s | %10s\n", "Forecast", "Lead", "Forecasts", "Hit Rate", "MAE Low", "MAE High", "Bias Low", "Bias High", "Unverified")
+	fmt.Printf("%s\n", strings.Repeat("=", 100))
+	for _, v := range verifications {
+		label := v.Source
+		if source, err := ForecastSourceByName(v.Source); err == nil {
+			label = source.Label
+		}
+		lead := fmt.Sprintf("%v-%vh", v.LeadHours, v.LeadHours+forecastLeadBucket)
+		if v.Forecasts == 0 {
+			fmt.Printf("%-8s | %-8s | %9v | %8s | %7s | %8s | %8s | %9s | %10v\n", label, lead, 0, "-", "-", "-", "-", "-", v.Unverified)
+			continue
+		}
+		fmt.Printf("%-8s | %-8s | %9v | %7.1f%% | %7.2f | %8.2f | %+8.2f | %+9.2f | %10v\n", label, lead, v.Forecasts, v.HitRate*100, v.MAELow, v.MAEHigh, v.BiasLow, v.BiasHigh, v.Unverified)
+	}
+	fmt.Printf("\nTemperature in deg C, the error and bias are the forecast minus the observed lowest/highest air temperature of all stations over the valid period.\n")
+}
@@ -0,0 +1,249 @@
+package SGAirTemp
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"testing"
+	"time"
+)
+
+//testForecast24Hour - the 24-hour issuance with the national temperature range and one period of the regions (without temperature).
+func testForecast24Hour(issued, validFrom time.Time, low, high float64) ForecastData {
+	validTo := validFrom.Add(24 * time.Hour)
+	return ForecastData{
+		Timestamp:   issued.Format(time.RFC3339),
+		ValidPeriod: &ValidPeriod{Start: validFrom.Format(time.RFC3339), End: validTo.Format(time.RFC3339)},
+		General:     &ForecastEntry{Forecast: "Partly Cloudy", Temperature: &ForecastRange{Low: low, High: high}},
+		Periods: []ForecastPeriod{{Time: ValidPeriod{Start: validFrom.Format(time.RFC3339), End: validTo.Format(time.RFC3339)},
+			Regions: map[string]string{"north": "Thundery Showers", "south": "Partly Cloudy"}}},
+	}
+}
+
+func TestForecastRows(t *testing.T) {
+	issued := sgtTime(t, "2024-05-01 05:30")
+	tests := []struct {
+		name   string
+		source ForecastSource
+		item   ForecastData
+		//want - area: valid from and to, in SGT.
+		want map[string][2]string
+	}{
+		{
+			name:   "2-hour areas",
+			source: Forecast2Hour,
+			item: ForecastData{Timestamp: issued.Format(time.RFC3339),
+				ValidPeriod: &ValidPeriod{Start: "2024-05-01T05:30:00+08:00", End: "2024-05-01T07:30:00+08:00"},
+				Forecasts:   []ForecastEntry{{Area: "Ang Mo Kio", Forecast: "Cloudy"}, {Area: "Bedok", Forecast: "Fair"}}},
+			want: map[string][2]string{"Ang Mo Kio": {"2024-05-01 05:30", "2024-05-01 07:30"}, "Bedok": {"2024-05-01 05:30", "2024-05-01 07:30"}},
+		},
+		{
+			name:   "24-hour general and periods",
+			source: Forecast24Hour,
+			item: ForecastData{Timestamp: issued.Format(time.RFC3339),
+				ValidPeriod: &ValidPeriod{Start: "2024-05-01T06:00:00+08:00", End: "2024-05-02T06:00:00+08:00"},
+				General:     &ForecastEntry{Forecast: "Partly Cloudy", Temperature: &ForecastRange{Low: 25, High: 33}},
+				Periods: []ForecastPeriod{
+					{Time: ValidPeriod{Start: "2024-05-01T06:00:00+08:00", End: "2024-05-01T12:00:00+08:00"}, Regions: map[string]string{"west": "Cloudy"}},
+					{Time: ValidPeriod{Start: "2024-05-01T12:00:00+08:00", End: "2024-05-01T18:00:00+08:00"}, Regions: map[string]string{"east": "Fair"}},
+				}},
+			want: map[string][2]string{NationalArea: {"2024-05-01 06:00", "2024-05-02 06:00"}, "west": {"2024-05-01 06:00", "2024-05-01 12:00"}, "east": {"2024-05-01 12:00", "2024-05-01 18:00"}},
+		},
+		{
+			name:   "4-day dates",
+			source: Forecast4Day,
+			item: ForecastData{Timestamp: issued.Format(time.RFC3339),
+				Forecasts: []ForecastEntry{{Date: "2024-05-02", Forecast: "Cloudy"}, {Date: "2024-05-05", Forecast: "Fair"}}},
+			want: map[string][2]string{NationalArea + " 2024-05-02": {"2024-05-02 00:00", "2024-05-03 00:00"}, NationalArea + " 2024-05-05": {"2024-05-05 00:00", "2024-05-06 00:00"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := forecastRows(tt.source, tt.item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.want) {
+				t.Fatalf("%v rows, want %v", len(rows), len(tt.want))
+			}
+			for _, row := range rows {
+				key := row.area
+				if len(row.entry.Date) > 0 {
+					key += " " + row.entry.Date
+				}
+				want, found := tt.want[key]
+				if found == false {
+					t.Errorf("row of %v, want one of %v", key, tt.want)
+					continue
+				}
+				if row.validFrom.Equal(sgtTime(t, want[0])) == false || row.validTo.Equal(sgtTime(t, want[1])) == false {
+					t.Errorf("%v valid from %v to %v, want from %v to %v SGT", key, row.validFrom, row.validTo, want[0], want[1])
+				}
+			}
+		})
+	}
+}
+
+func TestForecastRowsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		source ForecastSource
+		item   ForecastData
+	}{
+		{"no valid period", Forecast2Hour, ForecastData{Forecasts: []ForecastEntry{{Area: "Bedok"}}}},
+		{"valid period not RFC3339", Forecast24Hour, ForecastData{ValidPeriod: &ValidPeriod{Start: "2024-05-01 06:00", End: "2024-05-02T06:00:00+08:00"}}},
+		{"period not RFC3339", Forecast24Hour, ForecastData{ValidPeriod: &ValidPeriod{Start: "2024-05-01T06:00:00+08:00", End: "2024-05-02T06:00:00+08:00"},
+			Periods: []ForecastPeriod{{Time: ValidPeriod{Start: "2024-05-01T06:00:00+08:00", End: "tomorrow"}}}}},
+		{"4-day date not YYYY-MM-DD", Forecast4Day, ForecastData{Forecasts: []ForecastEntry{{Date: "02/05/2024"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := forecastRows(tt.source, tt.item); errors.Is(err, ErrInvalidDateFormat) == false {
+			t.Errorf("%v: error = %v, want ErrInvalidDateFormat", tt.name, err)
+		}
+	}
+}
+
+//seedForecastVerification - the observed readings and the forecasts of the TestVerifyForecasts.
+func seedForecastVerification(t *testing.T, dbc *DB) {
+	t.Helper()
+	//The lowest and highest of all stations: 27 and 31 on 2024-05-01, 25 and 33 on 2024-05-03.
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 10:00", 27)
+	saveTestReading(t, dbc, MetricAirTemperature, "S2", "2024-05-01 14:00", 31)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-03 10:00", 25)
+	saveTestReading(t, dbc, MetricAirTemperature, "S2", "2024-05-03 14:00", 33)
+	//Not the air temperature, never observed.
+	saveTestReading(t, dbc, MetricRelativeHumidity, "S1", "2024-05-01 12:00", 99)
+
+	now := time.Now().In(SGTLocation).Truncate(time.Minute)
+	response24Hour := ForecastResponse{Items: []ForecastData{
+		//Hit, the errors are -1 and +1.
+		testForecast24Hour(sgtTime(t, "2024-05-01 05:30"), sgtTime(t, "2024-05-01 06:00"), 26, 32),
+		//Miss, the errors are +2 and +1. Updated after the valid period started, the lead time is 0, not -24 hours.
+		testForecast24Hour(sgtTime(t, "2024-05-04 01:00"), sgtTime(t, "2024-05-03 00:00"), 27, 34),
+		//The valid period has not ended yet.
+		testForecast24Hour(now.Add(-time.Hour), now, 26, 32),
+	}}
+	if _, err := dbc.SaveForecastResponse(Forecast24Hour, response24Hour); err != nil {
+		t.Fatal(err)
+	}
+	response4Day := ForecastResponse{Items: []ForecastData{{
+		Timestamp: sgtTime(t, "2024-04-29 05:30").Format(time.RFC3339),
+		Forecasts: []ForecastEntry{
+			//Lead 42.5 hours, miss, the errors are +1 and 0.
+			{Date: "2024-05-01", Forecast: "Fair", Temperature: &ForecastRange{Low: 28, High: 31}},
+			//Lead 90.5 hours, hit, the errors are -1 and +1.
+			{Date: "2024-05-03", Forecast: "Fair", Temperature: &ForecastRange{Low: 24, High: 34}},
+			//No temperature range, not verified.
+			{Date: "2024-05-02", Forecast: "Fair"},
+		},
+	}}}
+	if _, err := dbc.SaveForecastResponse(Forecast4Day, response4Day); err != nil {
+		t.Fatal(err)
+	}
+	//The 2-hour forecast has no temperature range.
+	response2Hour := ForecastResponse{Items: []ForecastData{{
+		Timestamp:   sgtTime(t, "2024-05-01 10:00").Format(time.RFC3339),
+		ValidPeriod: &ValidPeriod{Start: "2024-05-01T10:00:00+08:00", End: "2024-05-01T12:00:00+08:00"},
+		Forecasts:   []ForecastEntry{{Area: "Bedok", Forecast: "Fair"}},
+	}}}
+	if _, err := dbc.SaveForecastResponse(Forecast2Hour, response2Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyForecasts(t *testing.T) {
+	dbc := newTestDB(t)
+	seedForecastVerification(t, dbc)
+
+	tests := []struct {
+		name  string
+		query ForecastVerificationQuery
+		want  []ForecastVerification
+	}{
+		{
+			name:  "all sources",
+			query: ForecastVerificationQuery{},
+			want: []ForecastVerification{
+				{Source: Forecast24Hour.Name, LeadHours: 0, Forecasts: 2, Hits: 1, HitRate: 0.5, MAELow: 1.5, MAEHigh: 1, BiasLow: 0.5, BiasHigh: 1, Unverified: 1},
+				{Source: Forecast4Day.Name, LeadHours: 24, Forecasts: 1, Hits: 0, HitRate: 0, MAELow: 1, MAEHigh: 0, BiasLow: 1, BiasHigh: 0},
+				{Source: Forecast4Day.Name, LeadHours: 72, Forecasts: 1, Hits: 1, HitRate: 1, MAELow: 1, MAEHigh: 1, BiasLow: -1, BiasHigh: 1},
+			},
+		},
+		{
+			name:  "source label and dates",
+			query: ForecastVerificationQuery{Source: "24-hour", DateFrom: "2024-05-02", DateTo: "2024-05-03"},
+			want: []ForecastVerification{
+				{Source: Forecast24Hour.Name, LeadHours: 0, Forecasts: 1, Hits: 0, HitRate: 0, MAELow: 2, MAEHigh: 1, BiasLow: 2, BiasHigh: 1},
+			},
+		},
+		{
+			//Every past valid period has 2 readings.
+			name:  "too few readings",
+			query: ForecastVerificationQuery{MinReadings: 3},
+			want: []ForecastVerification{
+				{Source: Forecast24Hour.Name, LeadHours: 0, Unverified: 3},
+				{Source: Forecast4Day.Name, LeadHours: 24, Unverified: 1},
+				{Source: Forecast4Day.Name, LeadHours: 72, Unverified: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifications, err := dbc.VerifyForecasts(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(verifications) != len(tt.want) {
+				t.Fatalf("verifications = %+v, want %+v", verifications, tt.want)
+			}
+			for i, got := range verifications {
+				want := tt.want[i]
+				if got.Source != want.Source || got.LeadHours != want.LeadHours || got.Forecasts != want.Forecasts || got.Hits != want.Hits || got.Unverified != want.Unverified {
+					t.Errorf("verification %v = %+v, want %+v", i, got, want)
+					continue
+				}
+				for _, value := range [][3]interface{}{
+					{"HitRate", got.HitRate, want.HitRate}, {"MAELow", got.MAELow, want.MAELow}, {"MAEHigh", got.MAEHigh, want.MAEHigh},
+					{"BiasLow", got.BiasLow, want.BiasLow}, {"BiasHigh", got.BiasHigh, want.BiasHigh},
+				} {
+					if math.Abs(value[1].(float64)-value[2].(float64)) > 1e-9 {
+						t.Errorf("%v %vh %v = %v, want %v", got.Source, got.LeadHours, value[0], value[1], value[2])
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := dbc.VerifyForecasts(ForecastVerificationQuery{Source: "7-day"}); errors.Is(err, ErrUnknownMetric) == false {
+		t.Errorf("error of the unknown source = %v, want ErrUnknownMetric", err)
+	}
+}
+
+func TestVerifyForecastsLeadBuckets(t *testing.T) {
+	dbc := newTestDB(t)
+	issued := sgtTime(t, "2024-05-01 05:30")
+	//Lead times of 0.5, 23.5, 24.5, 47.5 and 95.5 hours.
+	response := ForecastResponse{}
+	for _, hours := range []int{0, 23, 24, 47, 95} {
+		validFrom := issued.Add(time.Duration(hours)*time.Hour + 30*time.Minute)
+		response.Items = append(response.Items, testForecast24Hour(issued, validFrom, 20, 40))
+		saveTestReading(t, dbc, MetricAirTemperature, "S1", validFrom.Add(time.Hour).Format("2006-01-02 15:04"), 30)
+	}
+	if _, err := dbc.SaveForecastResponse(Forecast24Hour, response); err != nil {
+		t.Fatal(err)
+	}
+
+	verifications, err := dbc.VerifyForecasts(ForecastVerificationQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]int{}
+	leads := []int{}
+	for _, v := range verifications {
+		got[v.LeadHours] = v.Forecasts
+		leads = append(leads, v.LeadHours)
+	}
+	if sort.IntsAreSorted(leads) == false || len(got) != 3 || got[0] != 2 || got[24] != 2 || got[72] != 1 {
+		t.Errorf("forecasts by lead = %v, want 2 at 0h, 2 at 24h and 1 at 72h, in order", got)
+	}
+}
@@ -0,0 +1,197 @@
+package SGAirTemp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+//GeoJSONQuery struct - the stations of the GeoJSON, with their latest reading and the statistic of the Date.
+type GeoJSONQuery struct {
+	//Date - the date (YYYY-MM-DD) of the daily statistic, empty for the date of the newest saved reading.
+	Date string
+	//StationIDs - the stations of the features, empty for ALL Stations.
+	StationIDs []string
+	//Metric - the Metric of the readings, the Metric of the DB if it is not set.
+	Metric string
+}
+
+//GeoJSONFeatureCollection struct - the GeoJSON (RFC 7946) of the stations, one Point feature per station.
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+//GeoJSONFeature struct - the station feature, the Geometry is null when the station has no location.
+type GeoJSONFeature struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Geometry   *GeoJSONPoint     `json:"geometry"`
+	Properties StationProperties `json:"properties"`
+}
+
+//GeoJSONPoint struct - the Point geometry, the Coordinates are the longitude and latitude (in that order).
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+//StationProperties struct - the properties of the station feature, the value is null when the station has no reading.
+//The properties are flat (no nested object), so they are the attribute table columns in QGIS.
+type StationProperties struct {
+	StationID   string     `json:"station_id"`
+	StationName string     `json:"station_name"`
+	Metric      string     `json:"metric"`
+	Unit        string     `json:"unit"`
+	LatestValue *float64   `json:"latest_value"`
+	LatestAt    *time.Time `json:"latest_at"`
+	Date        string     `json:"date"`
+	Count       int        `json:"count"`
+	Mean        *float64   `json:"mean"`
+	Median      *float64   `json:"median"`
+	Min         *float64   `json:"min"`
+	MinAt       *time.Time `json:"min_at"`
+	Max         *float64   `json:"max"`
+	MaxAt       *time.Time `json:"max_at"`
+}
+
+//StationsGeoJSON - the FeatureCollection of the saved stations (ordered by the station name), every feature has the latest reading of the station
+//and the count, mean, median, min and max of its readings on the date.
+func (dbc *DB) StationsGeoJSON(query GeoJSONQuery) (collection GeoJSONFeatureCollection, err error) {
+	collection = GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	if len(query.Metric) == 0 {
+		query.Metric = dbc.metric().Name
+	}
+	metric := describeMetric(query.Metric)
+
+	latest, err := dbc.latestStationReadings(metric)
+	if err != nil {
+		return collection, err
+	}
+	if len(query.Date) == 0 {
+		//The date of the newest reading, today if there is none.
+		query.Date = time.Now().In(SGTLocation).Format(strStandardFormat)
+		var newest time.Time
+		for _, reading := range latest {
+			if reading.Timestamp.After(newest) {
+				newest = reading.Timestamp
+			}
+		}
+		if newest.IsZero() == false {
+			query.Date = newest.In(SGTLocation).Format(strStandardFormat)
+		}
+	}
+
+	aggregators := map[string]*Aggregator{}
+	err = dbc.queryReadings(Filter{DateFrom: query.Date, DateTo: query.Date, StationIDs: query.StationIDs, Metric: metric.Name}, "r.ts", Page{}, func(StationID, StationName string, ts int64, value float64) error {
+		if aggregators[StationID] == nil {
+			aggregators[StationID] = NewAggregator()
+		}
+		aggregators[StationID].Add(value, Occurrence{StationID: StationID, StationName: StationName, Timestamp: time.Unix(ts, 0).In(SGTLocation)})
+		return nil
+	})
+	if err != nil {
+		return collection, err
+	}
+
+	stations, err := dbc.geoStations(query.StationIDs)
+	if err != nil {
+		return collection, err
+	}
+	for _, st := range stations {
+		feature := GeoJSONFeature{
+			Type:       "Feature",
+			ID:         st.StationID,
+			Properties: StationProperties{StationID: st.StationID, StationName: st.StationName, Metric: metric.Name, Unit: metric.Unit, Date: query.Date},
+		}
+		if st.Location.Latitude.Valid && st.Location.Longitude.Valid {
+			feature.Geometry = &GeoJSONPoint{Type: "Point", Coordinates: [2]float64{st.Location.Longitude.Float64, st.Location.Latitude.Float64}}
+		}
+		if reading, found := latest[st.StationID]; found {
+			feature.Properties.LatestValue = &reading.Value
+			latestAt := reading.Timestamp.In(SGTLocation)
+			feature.Properties.LatestAt = &latestAt
+		}
+		if agg, found := aggregators[st.StationID]; found {
+			stats := agg.Result()
+			feature.Properties.Count = stats.Count
+			feature.Properties.Mean, feature.Properties.Median = &stats.Mean, &stats.Median
+			feature.Properties.Min, feature.Properties.MinAt = &stats.Min, firstOccurrence(stats.MinOccurrences)
+			feature.Properties.Max, feature.Properties.MaxAt = &stats.Max, firstOccurrence(stats.MaxOccurrences)
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+	return collection, nil
+}
+
+//WriteGeoJSON - write the StationsGeoJSON of the query to w.
+func (dbc *DB) WriteGeoJSON(w io.Writer, query GeoJSONQuery) (int, error) {
+	collection, err := dbc.StationsGeoJSON(query)
+	if err != nil {
+		return 0, err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return len(collection.Features), encoder.Encode(collection)
+}
+
+//latestStationReadings - the latest reading of every station for the Metric by the station ID.
+//The derived Metric is computed from the readings of the newest day with both the air temperature and the relative humidity.
+func (dbc *DB) latestStationReadings(metric Metric) (map[string]StationReading, error) {
+	latest := map[string]StationReading{}
+	if IsDerivedMetric(metric.Name) == false {
+		readings, err := dbc.latestReadings(metric)
+		for _, reading := range readings {
+			latest[reading.Station.StationID] = StationReading{StationID: reading.Station.StationID, StationName: reading.Station.StationName, Timestamp: time.Unix(reading.Timestamp, 0), Value: reading.Value}
+		}
+		return latest, err
+	}
+
+	newest, err := dbc.newestReadingTime(metric)
+	if err != nil || newest.IsZero() {
+		return latest, err
+	}
+	newestDate := newest.In(SGTLocation).Format(strStandardFormat)
+	//Ordered by the time, the last reading of the station is kept.
+	err = dbc.queryReadings(Filter{DateFrom: newestDate, DateTo: newestDate, Metric: metric.Name}, "r.ts", Page{}, func(StationID, StationName string, ts int64, value float64) error {
+		latest[StationID] = StationReading{StationID: StationID, StationName: StationName, Timestamp: time.Unix(ts, 0), Value: value}
+		return nil
+	})
+	return latest, err
+}
+
+//geoStation struct - the saved station with its nullable location.
+type geoStation struct {
+	StationID   string
+	StationName string
+	Location    struct {
+		Latitude  sql.NullFloat64
+		Longitude sql.NullFloat64
+	}
+}
+
+//geoStations - the saved stations (with the IDs, ALL Stations if empty) ordered by the station name, the missing location is kept as NULL.
+func (dbc *DB) geoStations(stationIDs []string) ([]geoStation, error) {
+	stations := []geoStation{}
+	where, args := "", []interface{}{}
+	if len(stationIDs) > 0 {
+		where = " WHERE station_id IN (" + placeholders(len(stationIDs)) + ")"
+		for _, id := range stationIDs {
+			args = append(args, id)
+		}
+	}
+	rows, err := dbc.Query("SELECT station_id, station_name, loc_latitude, loc_longitude FROM stations"+where+" ORDER BY station_name, station_id", args...)
+	if err != nil {
+		return stations, dbError("query stations", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		st := geoStation{}
+		if err := rows.Scan(&st.StationID, &st.StationName, &st.Location.Latitude, &st.Location.Longitude); err != nil {
+			return stations, dbError("query stations", err)
+		}
+		stations = append(stations, st)
+	}
+	return stations, dbError("query stations", rows.Err())
+}
@@ -0,0 +1,305 @@
+package SGAirTemp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//sqlImportStation - like sqlUpsertStation, but the missing latitude or longitude keeps the saved one (ie: the export has no coordinates).
+const sqlImportStation = "INSERT INTO stations (station_id, station_name, loc_latitude, loc_longitude) VALUES (?, ?, ?, ?) " +
+	"ON CONFLICT (station_id) DO UPDATE SET station_name = excluded.station_name, " +
+	"loc_latitude = IFNULL(excluded.loc_latitude, loc_latitude), loc_longitude = IFNULL(excluded.loc_longitude, loc_longitude)"
+
+//maxImportErrors - the invalid lines kept on the ImportSummary, all of them are written to the ErrorReport.
+const maxImportErrors = 10
+
+//importColumns - the accepted header names of every column, ie: the historical CSV of data.gov.sg (reading_value) or the export command (value).
+var importColumns = map[string][]string{
+	"metric":       {"metric"},
+	"station_id":   {"station_id", "device_id", "station"},
+	"station_name": {"station_name", "name"},
+	"latitude":     {"latitude", "location_latitude", "lat"},
+	"longitude":    {"longitude", "location_longitude", "lon", "lng"},
+	"timestamp":    {"timestamp", "date_time", "datetime", "ts"},
+	"value":        {"value", "reading_value", "reading"},
+}
+
+//importTimeLayouts - the accepted timestamps, the layout without the time zone is in SGT.
+var importTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"}
+
+//importValueRanges - the plausible values of the Metric, the value outside is an invalid line.
+var importValueRanges = map[string][2]float64{
+	MetricAirTemperature.Name:   {-10, 60},
+	MetricRelativeHumidity.Name: {0, 100},
+	MetricRainfall.Name:         {0, 500},
+	MetricWindSpeed.Name:        {0, 200},
+	MetricWindDirection.Name:    {0, 360},
+}
+
+//ImportOptions struct - how the CSV is imported.
+type ImportOptions struct {
+	//Metric - the Metric of the line without the metric column, the Metric of the DB if it is not set.
+	Metric Metric
+	//DryRun - validate and count the lines the same way, but nothing is saved.
+	DryRun bool
+	//ErrorReport - every invalid line is written to it as CSV (the line number, the error and the fields of the line), nil to skip.
+	ErrorReport io.Writer
+}
+
+//ImportLineError struct - the invalid line of the CSV.
+type ImportLineError struct {
+	Line   int
+	Err    string
+	Record []string
+}
+
+//ImportSummary struct - the result of the import, the Duplicates are the readings already saved (or repeated in the file), they are not changed.
+type ImportSummary struct {
+	Lines      int
+	Imported   int
+	Duplicates int
+	Invalid    int
+	//Errors - the first invalid lines.
+	Errors []ImportLineError
+	//First and Last - the time of the first and last reading imported.
+	First time.Time
+	Last  time.Time
+}
+
+//ImportReadings - save the readings of the CSV (with the header row) in one transaction, the CSV is read one line at a time.
+//The station_id, timestamp and value columns are needed, station_name (with latitude and longitude) adds the unknown station or updates it.
+//The invalid line (ie: not a number, out of the range of the Metric, unknown station) is skipped and reported, only the unreadable CSV or the database error fails the import,
+//in that case nothing is saved. The import stopped by the ctx (ie: Ctrl+C) is rolled back too, the ctx is checked before every line.
+func (dbc *DB) ImportReadings(ctx context.Context, r io.Reader, opts ImportOptions) (summary ImportSummary, err error) {
+	if len(opts.Metric.Name) == 0 {
+		opts.Metric = dbc.metric()
+	}
+	if IsDerivedMetric(opts.Metric.Name) {
+		return summary, errDerivedMetric(opts.Metric)
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return summary, &InputError{Value: "header", Message: fmt.Sprintf("the CSV header can't be read: %v", err), Err: ErrInvalidParameter}
+	}
+	columns, err := importColumnIndex(header)
+	if err != nil {
+		return summary, err
+	}
+
+	var errorReport *csv.Writer
+	if opts.ErrorReport != nil {
+		errorReport = csv.NewWriter(opts.ErrorReport)
+		errorReport.Write(append([]string{"line", "error"}, header...))
+		defer errorReport.Flush()
+	}
+
+	//knownStations - the saved stations, the station of the file is saved once unless its name or location changes.
+	knownStations := map[string]Station{}
+	stations, _, err := dbc.Stations(Page{})
+	if err != nil {
+		return summary, err
+	}
+	for _, st := range stations {
+		knownStations[st.StationID] = st
+	}
+
+	tx, err := dbc.Begin()
+	if err != nil {
+		return summary, dbError("begin import", err)
+	}
+	defer tx.Rollback()
+	stmtReading, err := tx.Prepare("INSERT OR IGNORE INTO readings (metric, station_id, ts, ts_sgt, value) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return summary, dbError("prepare reading import", err)
+	}
+	defer stmtReading.Close()
+	stmtStation, err := tx.Prepare(sqlImportStation)
+	if err != nil {
+		return summary, dbError("prepare station upsert", err)
+	}
+	defer stmtStation.Close()
+
+	for line := 2; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+			err = nil
+		}
+		if err != nil {
+			return summary, &InputError{Value: fmt.Sprintf("line %v", line), Message: err.Error(), Err: ErrInvalidParameter}
+		}
+		summary.Lines++
+
+		reading, station, errLine := parseImportRecord(record, columns, opts.Metric)
+		if errLine == nil && len(station.StationName) == 0 {
+			if _, found := knownStations[reading.StationID]; found == false {
+				errLine = fmt.Errorf("unknown station '%v', please add the station_name column", reading.StationID)
+			}
+		}
+		if errLine != nil {
+			summary.Invalid++
+			lineError := ImportLineError{Line: line, Err: errLine.Error(), Record: append([]string{}, record...)}
+			if len(summary.Errors) < maxImportErrors {
+				summary.Errors = append(summary.Errors, lineError)
+			}
+			if errorReport != nil {
+				errorReport.Write(append([]string{strconv.Itoa(line), lineError.Err}, lineError.Record...))
+			}
+			continue
+		}
+
+		if saved, found := knownStations[station.StationID]; len(station.StationName) > 0 && (found == false || sameImportStation(saved, station) == false) {
+			if _, err := stmtStation.Exec(station.StationID, station.StationName, nullCoordinate(station.Location.Latitude), nullCoordinate(station.Location.Longitude)); err != nil {
+				return summary, dbError("upsert station", err)
+			}
+			knownStations[station.StationID] = station
+		}
+		result, err := stmtReading.Exec(reading.Metric, reading.StationID, reading.Timestamp.Unix(), reading.Timestamp.In(SGTLocation).Format(time.RFC3339), reading.Value)
+		if err != nil {
+			return summary, dbError("import reading", err)
+		}
+		if inserted, _ := result.RowsAffected(); inserted == 0 {
+			summary.Duplicates++
+			continue
+		}
+		summary.Imported++
+		if summary.First.IsZero() || reading.Timestamp.Before(summary.First) {
+			summary.First = reading.Timestamp
+		}
+		if reading.Timestamp.After(summary.Last) {
+			summary.Last = reading.Timestamp
+		}
+	}
+
+	if opts.DryRun == true {
+		//The counts are the same as the real import, the transaction is rolled back.
+		return summary, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return summary, dbError("commit import", err)
+	}
+	return summary, nil
+}
+
+//importReading struct - the reading of a valid line.
+type importReading struct {
+	Metric    string
+	StationID string
+	Timestamp time.Time
+	Value     float64
+}
+
+//importColumnIndex - the index of every column found in the header, the station_id, timestamp and value columns are needed.
+func importColumnIndex(header []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for column, aliases := range importColumns {
+			for _, alias := range aliases {
+				if _, found := columns[column]; found == false && name == alias {
+					columns[column] = i
+				}
+			}
+		}
+	}
+	for _, column := range []string{"station_id", "timestamp", "value"} {
+		if _, found := columns[column]; found == false {
+			return columns, &InputError{Value: strings.Join(header, ","), Message: fmt.Sprintf("the CSV header has no %v column (%v)", column, strings.Join(importColumns[column], ", ")), Err: ErrInvalidParameter}
+		}
+	}
+	return columns, nil
+}
+
+//parseImportRecord - the reading and the station (StationName empty if the line has none) of the line.
+func parseImportRecord(record []string, columns map[string]int, defaultMetric Metric) (reading importReading, station Station, err error) {
+	field := func(column string) string {
+		if i, found := columns[column]; found && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	reading.Metric = defaultMetric.Name
+	if metricName := field("metric"); len(metricName) > 0 {
+		metric, err := MetricByName(metricName)
+		if err != nil {
+			return reading, station, err
+		}
+		if IsDerivedMetric(metric.Name) {
+			return reading, station, errDerivedMetric(metric)
+		}
+		reading.Metric = metric.Name
+	}
+
+	if reading.StationID = field("station_id"); len(reading.StationID) == 0 {
+		return reading, station, errors.New("empty station_id")
+	}
+	if reading.Timestamp, err = parseImportTime(field("timestamp")); err != nil {
+		return reading, station, err
+	}
+	strValue := field("value")
+	if reading.Value, err = strconv.ParseFloat(strValue, 64); err != nil || math.IsNaN(reading.Value) || math.IsInf(reading.Value, 0) {
+		return reading, station, fmt.Errorf("value '%v' is not a number", strValue)
+	}
+	if valueRange, found := importValueRanges[reading.Metric]; found && (reading.Value < valueRange[0] || reading.Value > valueRange[1]) {
+		return reading, station, fmt.Errorf("value %v is out of the range of %v (%v to %v)", reading.Value, reading.Metric, valueRange[0], valueRange[1])
+	}
+
+	station = Station{StationID: reading.StationID, StationName: field("station_name")}
+	if len(station.StationName) > 0 {
+		for column, coordinate := range map[string]*float64{"latitude": &station.Location.Latitude, "longitude": &station.Location.Longitude} {
+			strCoordinate := field(column)
+			if len(strCoordinate) == 0 {
+				*coordinate = math.NaN()
+				continue
+			}
+			if *coordinate, err = strconv.ParseFloat(strCoordinate, 64); err != nil {
+				return reading, station, fmt.Errorf("%v '%v' is not a number", column, strCoordinate)
+			}
+		}
+	}
+	return reading, station, nil
+}
+
+//parseImportTime - the timestamp in one of the importTimeLayouts, not in the future.
+func parseImportTime(strTime string) (time.Time, error) {
+	for _, layout := range importTimeLayouts {
+		if ts, err := time.ParseInLocation(layout, strTime, SGTLocation); err == nil {
+			if ts.After(time.Now().Add(time.Minute)) {
+				return ts, fmt.Errorf("timestamp '%v' is in the future", strTime)
+			}
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("timestamp '%v' is not RFC3339 or YYYY-MM-DD HH:mm[:ss] (SGT)", strTime)
+}
+
+//nullCoordinate - NULL for the missing (NaN) latitude or longitude.
+func nullCoordinate(coordinate float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: coordinate, Valid: math.IsNaN(coordinate) == false}
+}
+
+//sameImportStation - the station of the line has the same name and location (the missing coordinate is kept) as the saved one.
+func sameImportStation(saved, station Station) bool {
+	return saved.StationName == station.StationName &&
+		(math.IsNaN(station.Location.Latitude) || saved.Location.Latitude == station.Location.Latitude) &&
+		(math.IsNaN(station.Location.Longitude) || saved.Location.Longitude == station.Location.Longitude)
+}
@@ -0,0 +1,224 @@
+package SGAirTemp
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestImportReadings(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 00:00", 27)
+
+	//The historical CSV of data.gov.sg, S2 is added by its station_name.
+	input := "date_time,device_id,station_name,location_latitude,location_longitude,reading_value\n" +
+		"2024-05-01 00:00,S1,,,,27\n" +
+		"2024-05-01T00:01:00+08:00,S1,,,,27.5\n" +
+		"2024-05-01 00:01,S2,Station S2,1.3,103.7,28\n" +
+		"2024-05-01 00:01,S2,Station S2,1.3,103.7,28.1\n" +
+		"2024-05-01 00:02,S2,Station S2,,,28.2\n"
+	summary, err := dbc.ImportReadings(context.Background(), strings.NewReader(input), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//The saved reading and the line repeated in the file are duplicates.
+	if summary.Lines != 5 || summary.Imported != 3 || summary.Duplicates != 2 || summary.Invalid != 0 {
+		t.Errorf("summary = %+v, want 5 lines, 3 imported and 2 duplicates", summary)
+	}
+	if want := sgtTime(t, "2024-05-01 00:01"); summary.First.Equal(want) == false || summary.Last.Equal(sgtTime(t, "2024-05-01 00:02")) == false {
+		t.Errorf("readings from %v to %v, want from %v to 00:02", summary.First, summary.Last, want)
+	}
+	if total, err := dbc.CountReadings(Filter{}); err != nil || total != 4 {
+		t.Errorf("readings saved = %v, %v, want 4", total, err)
+	}
+
+	//The duplicate is not changed, the first value is kept.
+	value, err := dbc.GetScalar("SELECT value scalarRes FROM readings WHERE station_id = ? AND ts = ?", "S2", sgtTime(t, "2024-05-01 00:01").Unix())
+	if err != nil || value != "28" {
+		t.Errorf("value of the duplicate = %v, %v, want 28", value, err)
+	}
+	//The missing coordinates keep the saved ones.
+	stations, _, err := dbc.Stations(Page{})
+	if err != nil || len(stations) != 2 || stations[1].StationName != "Station S2" || stations[1].Location.Latitude != 1.3 || stations[1].Location.Longitude != 103.7 {
+		t.Errorf("stations = %+v, %v, want S2 at 1.3, 103.7", stations, err)
+	}
+
+	//Importing again only finds the duplicates.
+	summary, err = dbc.ImportReadings(context.Background(), strings.NewReader(input), ImportOptions{})
+	if err != nil || summary.Imported != 0 || summary.Duplicates != 5 {
+		t.Errorf("summary of the second import = %+v, %v, want 5 duplicates", summary, err)
+	}
+}
+
+func TestImportReadingsInvalidLines(t *testing.T) {
+	dbc := newTestDB(t)
+	input := "metric,station_id,station_name,timestamp,value\n" +
+		"air-temperature,S1,Station S1,2024-05-01 00:00,27\n" +
+		"air-temperature,S1,Station S1,2024-05-01 00:01,hot\n" +
+		"air-temperature,S1,Station S1,2024-05-01 00:02,99\n" +
+		"relative-humidity,S1,Station S1,2024-05-01 00:03,101\n" +
+		"air-temperature,S1,Station S1,2999-01-01 00:00,27\n" +
+		"air-temperature,S1,Station S1,01/05/2024,27\n" +
+		"air-temperature,S9,,2024-05-01 00:04,27\n" +
+		"heat-index,S1,Station S1,2024-05-01 00:05,27\n" +
+		"snowfall,S1,Station S1,2024-05-01 00:06,27\n" +
+		"air-temperature,,Station S1,2024-05-01 00:07,27\n" +
+		"relative-humidity,S1,Station S1,2024-05-01 00:08,80\n"
+	var report bytes.Buffer
+	summary, err := dbc.ImportReadings(context.Background(), strings.NewReader(input), ImportOptions{ErrorReport: &report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Lines != 11 || summary.Imported != 2 || summary.Invalid != 9 || len(summary.Errors) != 9 {
+		t.Fatalf("summary = %+v, want 11 lines, 2 imported and 9 invalid", summary)
+	}
+	wantErrors := []struct {
+		line int
+		err  string
+	}{
+		{3, "not a number"},
+		{4, "out of the range of air-temperature"},
+		{5, "out of the range of relative-humidity"},
+		{6, "in the future"},
+		{7, "is not RFC3339"},
+		{8, "unknown station 'S9'"},
+		{9, "heat-index"},
+		{10, "snowfall"},
+		{11, "empty station_id"},
+	}
+	for i, want := range wantErrors {
+		if lineError := summary.Errors[i]; lineError.Line != want.line || strings.Contains(lineError.Err, want.err) == false {
+			t.Errorf("error %v = line %v: %v, want line %v: ...%v...", i, lineError.Line, lineError.Err, want.line, want.err)
+		}
+	}
+
+	//The error report has the header and every invalid line with its fields.
+	records, err := csv.NewReader(&report).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 10 || strings.Join(records[0], ",") != "line,error,metric,station_id,station_name,timestamp,value" {
+		t.Fatalf("error report = %v, want the header and 9 lines", records)
+	}
+	if record := records[1]; record[0] != "3" || record[1] != summary.Errors[0].Err || strings.Join(record[2:], ",") != "air-temperature,S1,Station S1,2024-05-01 00:01,hot" {
+		t.Errorf("first line of the error report = %v, want the line 3 with its fields", record)
+	}
+}
+
+func TestImportReadingsMaxErrors(t *testing.T) {
+	dbc := newTestDB(t)
+	input := "station_id,timestamp,value\n" + strings.Repeat("S9,2024-05-01 00:00,27\n", maxImportErrors+5)
+	var report bytes.Buffer
+	summary, err := dbc.ImportReadings(context.Background(), strings.NewReader(input), ImportOptions{ErrorReport: &report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Only the first invalid lines are kept, all of them are on the report.
+	if summary.Invalid != maxImportErrors+5 || len(summary.Errors) != maxImportErrors {
+		t.Errorf("%v invalid and %v errors, want %v and %v", summary.Invalid, len(summary.Errors), maxImportErrors+5, maxImportErrors)
+	}
+	if lines := strings.Count(report.String(), "\n"); lines != 1+maxImportErrors+5 {
+		t.Errorf("%v lines on the error report, want %v", lines, 1+maxImportErrors+5)
+	}
+}
+
+func TestImportReadingsDryRun(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 00:00", 27)
+	input := "station_id,station_name,timestamp,value\n" +
+		"S1,,2024-05-01 00:00,27\n" +
+		"S1,,2024-05-01 00:01,27.5\n" +
+		"S2,Station S2,2024-05-01 00:01,28\n" +
+		"S2,Station S2,2024-05-01 00:02,hot\n"
+	summary, err := dbc.ImportReadings(context.Background(), strings.NewReader(input), ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//The counts are the ones of the real import.
+	if summary.Lines != 4 || summary.Imported != 2 || summary.Duplicates != 1 || summary.Invalid != 1 {
+		t.Errorf("summary = %+v, want 4 lines, 2 imported, 1 duplicate and 1 invalid", summary)
+	}
+	//Nothing is saved, not even the new station.
+	if total, err := dbc.CountReadings(Filter{}); err != nil || total != 1 {
+		t.Errorf("readings after the dry run = %v, %v, want the 1 reading saved before", total, err)
+	}
+	if stations, _, err := dbc.Stations(Page{}); err != nil || len(stations) != 1 {
+		t.Errorf("stations after the dry run = %+v, %v, want only S1", stations, err)
+	}
+}
+
+func TestImportReadingsFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{"no header", "", ErrInvalidParameter},
+		{"no value column", "station_id,timestamp\nS1,2024-05-01 00:00\n", ErrInvalidParameter},
+		{"unreadable CSV", "station_id,timestamp,value\nS1,2024-05-01 00:00,27\nS1,\"2024-05-01 00:01,27\n", ErrInvalidParameter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc := newTestDB(t)
+			saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-04-30 00:00", 27)
+			_, err := dbc.ImportReadings(context.Background(), strings.NewReader(tt.input), ImportOptions{})
+			var inputErr *InputError
+			if errors.As(err, &inputErr) == false || errors.Is(err, tt.wantErr) == false {
+				t.Fatalf("error = %v, want the InputError of %v", err, tt.wantErr)
+			}
+			//The valid lines before the failure are rolled back.
+			if total, err := dbc.CountReadings(Filter{}); err != nil || total != 1 {
+				t.Errorf("readings saved = %v, %v, want only the 1 saved before", total, err)
+			}
+		})
+	}
+
+	dbc := newTestDB(t)
+	if _, err := dbc.ImportReadings(context.Background(), strings.NewReader("station_id,timestamp,value\n"), ImportOptions{Metric: MetricHeatIndex}); errors.Is(err, ErrDerivedMetric) == false {
+		t.Errorf("import of the derived metric = %v, want ErrDerivedMetric", err)
+	}
+}
+
+//cancelReader - the reader calling cancel once the first n bytes are read, like Ctrl+C while the CSV is piped.
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (cr *cancelReader) Read(p []byte) (int, error) {
+	if cr.n <= 0 {
+		cr.cancel()
+	}
+	if len(p) > cr.n && cr.n > 0 {
+		p = p[:cr.n]
+	}
+	n, err := cr.r.Read(p)
+	cr.n -= n
+	return n, err
+}
+
+func TestImportReadingsCancel(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-04-30 00:00", 27)
+	header := "station_id,timestamp,value\n"
+	input := header + strings.Repeat("S1,2024-05-01 00:00,27\n", 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	summary, err := dbc.ImportReadings(ctx, &cancelReader{r: strings.NewReader(input), n: len(header) + 100, cancel: cancel}, ImportOptions{})
+	if errors.Is(err, context.Canceled) == false {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if summary.Lines == 0 || summary.Lines >= 1000 {
+		t.Errorf("%v lines read, want the import stopped after the cancel", summary.Lines)
+	}
+	//The lines imported before the cancel are rolled back.
+	if total, err := dbc.CountReadings(Filter{}); err != nil || total != 1 {
+		t.Errorf("readings saved = %v, %v, want only the 1 saved before", total, err)
+	}
+}
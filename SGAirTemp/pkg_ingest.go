@@ -0,0 +1,89 @@
+package SGAirTemp
+
+import (
+	"database/sql"
+	"time"
+)
+
+//The upsert statements of the ingestion, the newer response from the API replace the saved value.
+const (
+	sqlUpsertStation = "INSERT INTO stations (station_id, station_name, loc_latitude, loc_longitude) VALUES (?, ?, ?, ?) " +
+		"ON CONFLICT (station_id) DO UPDATE SET station_name = excluded.station_name, loc_latitude = excluded.loc_latitude, loc_longitude = excluded.loc_longitude"
+	sqlUpsertReading = "INSERT INTO readings (metric, station_id, ts, ts_sgt, value) VALUES (?, ?, ?, ?, ?) " +
+		"ON CONFLICT (metric, station_id, ts) DO UPDATE SET ts_sgt = excluded.ts_sgt, value = excluded.value"
+)
+
+//SaveWeatherResponse - save the stations and readings (of the metric) of the API response in a single transaction, return the total readings saved.
+//The statements are prepared once for the whole response, so a full day response (1440 timestamps) is saved in one go.
+func (dbc *DB) SaveWeatherResponse(metric Metric, response WeatherResponse) (totalSaved int, err error) {
+	tx, err := dbc.Begin()
+	if err != nil {
+		return 0, dbError("begin ingestion", err)
+	}
+	defer tx.Rollback()
+
+	rw, err := newResponseWriter(tx)
+	if err != nil {
+		return 0, err
+	}
+	defer rw.Close()
+
+	if totalSaved, err = rw.Save(metric, response); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, dbError("commit ingestion", err)
+	}
+	return totalSaved, nil
+}
+
+//responseWriter struct - the upsert statements prepared on the transaction, to save many API responses in the same transaction.
+type responseWriter struct {
+	stmtStation *sql.Stmt
+	stmtReading *sql.Stmt
+}
+
+//newResponseWriter - prepare the upsert statements on the transaction.
+func newResponseWriter(tx *sql.Tx) (*responseWriter, error) {
+	stmtStation, err := tx.Prepare(sqlUpsertStation)
+	if err != nil {
+		return nil, dbError("prepare station upsert", err)
+	}
+	stmtReading, err := tx.Prepare(sqlUpsertReading)
+	if err != nil {
+		stmtStation.Close()
+		return nil, dbError("prepare reading upsert", err)
+	}
+	return &responseWriter{stmtStation: stmtStation, stmtReading: stmtReading}, nil
+}
+
+//Save - save the stations and readings (of the metric) of the API response, return the total readings saved.
+func (rw *responseWriter) Save(metric Metric, response WeatherResponse) (totalSaved int, err error) {
+	//Save the Station object first, the readings refer to it.
+	for _, st := range response.Metadata.Station {
+		if _, err := rw.stmtStation.Exec(st.StationID, st.StationName, st.Location.Latitude, st.Location.Longitude); err != nil {
+			return 0, dbError("upsert station", err)
+		}
+	}
+
+	for _, WeatherData := range response.Items {
+		ts, err := time.Parse(time.RFC3339, WeatherData.Timestamp)
+		if err != nil {
+			return 0, &InputError{Value: WeatherData.Timestamp, Message: "reading timestamp is not RFC3339", Err: ErrInvalidDateFormat}
+		}
+		tsSGT := ts.In(SGTLocation).Format(time.RFC3339)
+		for _, rd := range WeatherData.Readings {
+			if _, err := rw.stmtReading.Exec(metric.Name, rd.StationID, ts.Unix(), tsSGT, rd.Value); err != nil {
+				return 0, dbError("upsert reading", err)
+			}
+			totalSaved++
+		}
+	}
+	return totalSaved, nil
+}
+
+//Close - close the prepared statements.
+func (rw *responseWriter) Close() {
+	rw.stmtStation.Close()
+	rw.stmtReading.Close()
+}
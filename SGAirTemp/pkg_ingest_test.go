@@ -0,0 +1,113 @@
+package SGAirTemp
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//The generated API response of the benchmarks: 15 stations with a reading every minute for 2 hours.
+const (
+	benchStations   = 15
+	benchTimestamps = 120
+)
+
+//benchResponse - the API response with every station having a reading on every minute from 2020-06-01 00:00 SGT.
+func benchResponse(totalStations, totalTimestamps int) WeatherResponse {
+	response := WeatherResponse{}
+	for i := 0; i < totalStations; i++ {
+		response.Metadata.Station = append(response.Metadata.Station, Station{
+			StationID:   fmt.Sprintf("S%03d", i+1),
+			StationName: fmt.Sprintf("Bench Station %v", i+1),
+			Location:    Location{Latitude: 1.3 + float64(i)*0.01, Longitude: 103.8 + float64(i)*0.01},
+		})
+	}
+
+	startTime := time.Date(2020, 6, 1, 0, 0, 0, 0, SGTLocation)
+	for t := 0; t < totalTimestamps; t++ {
+		WeatherData := WeatherData{Timestamp: startTime.Add(time.Duration(t) * time.Minute).Format(time.RFC3339)}
+		for i, st := range response.Metadata.Station {
+			WeatherData.Readings = append(WeatherData.Readings, Reading{StationID: st.StationID, Value: 25 + float64((t+i)%70)/10})
+		}
+		response.Items = append(response.Items, WeatherData)
+	}
+	return response
+}
+
+//savePerRow - the ingestion before SaveWeatherResponse: a SELECT COUNT then a statement prepared for every new row, each in its own transaction.
+func savePerRow(dbc *DB, metric Metric, response WeatherResponse) error {
+	for _, st := range response.Metadata.Station {
+		totalRow, err := dbc.GetScalar("SELECT COUNT(station_id) scalarRes FROM stations WHERE station_id = ?", st.StationID)
+		if err != nil {
+			return err
+		}
+		if cnt, _ := strconv.Atoi(totalRow); cnt > 0 {
+			continue
+		}
+		statement, err := dbc.Prepare("INSERT INTO stations(station_id, station_name, loc_latitude, loc_longitude) VALUES(?, ?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		_, err = statement.Exec(st.StationID, st.StationName, st.Location.Latitude, st.Location.Longitude)
+		statement.Close()
+		if err != nil {
+			return err
+		}
+	}
+	for _, WeatherData := range response.Items {
+		ts, err := time.Parse(time.RFC3339, WeatherData.Timestamp)
+		if err != nil {
+			return err
+		}
+		for _, rd := range WeatherData.Readings {
+			totalRow, err := dbc.GetScalar("SELECT COUNT(station_id) scalarRes FROM readings WHERE metric = ? AND station_id = ? AND ts = ?", metric.Name, rd.StationID, ts.Unix())
+			if err != nil {
+				return err
+			}
+			if cnt, _ := strconv.Atoi(totalRow); cnt > 0 {
+				continue
+			}
+			statement, err := dbc.Prepare("INSERT INTO readings(metric, station_id, ts, ts_sgt, value) VALUES(?, ?, ?, ?, ?)")
+			if err != nil {
+				return err
+			}
+			_, err = statement.Exec(metric.Name, rd.StationID, ts.Unix(), ts.In(SGTLocation).Format(time.RFC3339), rd.Value)
+			statement.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//benchmarkIngest - run the ingestion of the generated response on a new database every iteration, only the ingestion is timed.
+func benchmarkIngest(b *testing.B, ingest func(dbc *DB, response WeatherResponse) error) {
+	response := benchResponse(benchStations, benchTimestamps)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		dbc := newTestDB(b)
+		b.StartTimer()
+		if err := ingest(dbc, response); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(benchStations*benchTimestamps*b.N)/b.Elapsed().Seconds(), "readings/s")
+}
+
+func BenchmarkSaveWeatherResponse(b *testing.B) {
+	benchmarkIngest(b, func(dbc *DB, response WeatherResponse) error {
+		_, err := dbc.SaveWeatherResponse(MetricAirTemperature, response)
+		return err
+	})
+}
+
+func BenchmarkSavePerRow(b *testing.B) {
+	benchmarkIngest(b, func(dbc *DB, response WeatherResponse) error {
+		return savePerRow(dbc, MetricAirTemperature, response)
+	})
+}
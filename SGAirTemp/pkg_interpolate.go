@@ -0,0 +1,545 @@
+package SGAirTemp
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+//The defaults of the interpolation, the readings are every minute so the reading of every station is near the time.
+//The cell size is in degrees, 0.01 is about 1.1 km.
+const (
+	DefaultIDWPower        = 2.0
+	DefaultSampleTolerance = 5 * time.Minute
+	DefaultGridCellSize    = 0.01
+)
+
+//The limits of the interpolation.
+const (
+	maxGridCells             = 1000000
+	minKrigingSamples        = 3
+	krigingRangeSearchStepKm = 0.5
+	gridNoDataValue          = -9999
+)
+
+//SingaporeBoundingBox - the bounding box of the main island and the stations on the outlying islands.
+var SingaporeBoundingBox = BoundingBox{MinLat: 1.15, MinLon: 103.6, MaxLat: 1.48, MaxLon: 104.1}
+
+//InterpolationMethod - the method estimating the value between the stations.
+type InterpolationMethod string
+
+//The methods of the interpolation.
+const (
+	//InterpolationIDW - the inverse distance weighting, the weight of the station is 1 / distance^Power.
+	InterpolationIDW InterpolationMethod = "idw"
+	//InterpolationKriging - the ordinary kriging with the exponential variogram fitted on the readings of the time.
+	InterpolationKriging InterpolationMethod = "kriging"
+)
+
+//InterpolationOptions struct - which readings are interpolated and how.
+type InterpolationOptions struct {
+	//At - the time of the readings, the time of the newest saved reading if it is zero.
+	At time.Time
+	//Metric - the Metric of the readings, the Metric of the DB if it is not set.
+	Metric string
+	//Method - InterpolationIDW if it is not set.
+	Method InterpolationMethod
+	//Power - the power of the distance of the IDW, DefaultIDWPower if it is not set.
+	Power float64
+	//Tolerance - the reading of the station nearest to At within the Tolerance is used, DefaultSampleTolerance if it is not set.
+	Tolerance time.Duration
+}
+
+//InterpolationSample struct - the reading of the station used by the interpolation.
+type InterpolationSample struct {
+	StationID   string
+	StationName string
+	Location    Location
+	Timestamp   time.Time
+	Value       float64
+}
+
+//Interpolator struct - estimate the value at any latitude and longitude from the readings of the stations at one time.
+type Interpolator struct {
+	Metric  Metric
+	At      time.Time
+	Method  InterpolationMethod
+	Power   float64
+	Samples []InterpolationSample
+	//variogram - the fitted exponential variogram of the kriging.
+	variogram variogram
+}
+
+//SampleWeight struct - the station reading with its distance (km) and weight on the Estimate.
+type SampleWeight struct {
+	InterpolationSample
+	DistanceKm float64
+	Weight     float64
+}
+
+//Estimate struct - the interpolated value at the latitude and longitude, with the weight of every station.
+type Estimate struct {
+	Location Location
+	Value    float64
+	Weights  []SampleWeight
+}
+
+//BoundingBox struct - the area of the Grid in degrees.
+type BoundingBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+//Grid struct - the estimated value at the center of every cell of the bounding box.
+type Grid struct {
+	Metric      Metric
+	At          time.Time
+	Method      InterpolationMethod
+	BoundingBox BoundingBox
+	CellSize    float64
+	Rows        int
+	Cols        int
+	//Values - Values[row][col], the row 0 is the north edge like the ESRI ASCII raster.
+	Values [][]float64
+}
+
+//GridFormat - the file format of the Grid.
+type GridFormat string
+
+//The formats of the Grid.
+const (
+	//GridCSV - one row per cell: the latitude and longitude of the center and the value.
+	GridCSV GridFormat = "csv"
+	//GridGeoJSON - one Polygon feature per cell with its value.
+	GridGeoJSON GridFormat = "geojson"
+	//GridASCII - the ESRI ASCII raster (.asc), read by QGIS and GDAL.
+	GridASCII GridFormat = "asc"
+)
+
+//GridFormatByName - the GridFormat with the name, csv for the empty name.
+func GridFormatByName(name string) (GridFormat, error) {
+	switch GridFormat(strings.ToLower(strings.TrimSpace(name))) {
+	case "", GridCSV:
+		return GridCSV, nil
+	case GridGeoJSON:
+		return GridGeoJSON, nil
+	case GridASCII:
+		return GridASCII, nil
+	}
+	return "", &InputError{Value: name, Message: "please choose csv, geojson or asc", Err: ErrInvalidParameter}
+}
+
+//NewInterpolator - the Interpolator of the readings of the stations with a location at the time of the options.
+func (dbc *DB) NewInterpolator(opts InterpolationOptions) (*Interpolator, error) {
+	if len(opts.Metric) == 0 {
+		opts.Metric = dbc.metric().Name
+	}
+	if len(opts.Method) == 0 {
+		opts.Method = InterpolationIDW
+	}
+	if opts.Method != InterpolationIDW && opts.Method != InterpolationKriging {
+		return nil, &InputError{Value: string(opts.Method), Message: "please choose idw or kriging", Err: ErrInvalidParameter}
+	}
+	if opts.Power == 0 {
+		opts.Power = DefaultIDWPower
+	}
+	if opts.Power < 0 {
+		return nil, &InputError{Value: fmt.Sprint(opts.Power), Message: "the power must be more than 0", Err: ErrInvalidParameter}
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = DefaultSampleTolerance
+	}
+	metric := describeMetric(opts.Metric)
+	if opts.At.IsZero() {
+		newest, err := dbc.newestReadingTime(metric)
+		if err != nil {
+			return nil, err
+		}
+		if newest.IsZero() {
+			return nil, &InputError{Value: metric.Name, Message: "there is no saved reading to interpolate", Err: ErrInvalidParameter}
+		}
+		opts.At = newest
+	}
+
+	samples, err := dbc.interpolationSamples(metric, opts.At, opts.Tolerance)
+	if err != nil {
+		return nil, err
+	}
+	ip := &Interpolator{Metric: metric, At: opts.At.In(SGTLocation), Method: opts.Method, Power: opts.Power, Samples: samples}
+	minSamples := 1
+	if ip.Method == InterpolationKriging {
+		minSamples = minKrigingSamples
+	}
+	if len(samples) < minSamples {
+		return nil, &InputError{Value: ip.At.Format(time.RFC3339), Message: fmt.Sprintf("%v stations with a location have the reading within %v, %v needs %v", len(samples), opts.Tolerance, ip.Method, minSamples), Err: ErrInvalidParameter}
+	}
+	if ip.Method == InterpolationKriging {
+		ip.variogram = fitVariogram(samples)
+	}
+	return ip, nil
+}
+
+//newestReadingTime - the time of the newest saved reading of the Metric, with both the air temperature and the relative humidity for the derived Metric.
+//Zero time if there is none.
+func (dbc *DB) newestReadingTime(metric Metric) (time.Time, error) {
+	var newestTs sql.NullInt64
+	var err error
+	if IsDerivedMetric(metric.Name) {
+		err = dbc.QueryRow("SELECT MAX(r.ts) FROM readings r INNER JOIN readings rh ON rh.metric = ? AND rh.station_id = r.station_id AND rh.ts = r.ts WHERE r.metric = ?",
+			MetricRelativeHumidity.Name, MetricAirTemperature.Name).Scan(&newestTs)
+	} else {
+		err = dbc.QueryRow("SELECT MAX(ts) FROM readings WHERE metric = ?", metric.Name).Scan(&newestTs)
+	}
+	if err != nil || newestTs.Valid == false {
+		return time.Time{}, dbError("query newest reading", err)
+	}
+	return time.Unix(newestTs.Int64, 0), nil
+}
+
+//interpolationSamples - the reading nearest to the time (within the tolerance) of every station with a location, ordered by the station name.
+func (dbc *DB) interpolationSamples(metric Metric, at time.Time, tolerance time.Duration) ([]InterpolationSample, error) {
+	samples := []InterpolationSample{}
+	stations, err := dbc.geoStations(nil)
+	if err != nil {
+		return samples, err
+	}
+	located := map[string]geoStation{}
+	for _, st := range stations {
+		if st.Location.Latitude.Valid && st.Location.Longitude.Valid {
+			located[st.StationID] = st
+		}
+	}
+
+	from, to := at.Add(-tolerance).In(SGTLocation), at.Add(tolerance).In(SGTLocation)
+	filter := Filter{DateFrom: from.Format(strStandardFormat), DateTo: to.Format(strStandardFormat), Metric: metric.Name}
+	if tolerance < 12*time.Hour {
+		//Only the hours of the window are read, not the full days.
+		hours := map[int]bool{}
+		for t := from.Truncate(time.Hour); t.After(to) == false; t = t.Add(time.Hour) {
+			if hours[t.Hour()] == false {
+				hours[t.Hour()] = true
+				filter.Hours = append(filter.Hours, t.Hour())
+			}
+		}
+	}
+	nearest := map[string]InterpolationSample{}
+	err = dbc.queryReadings(filter, "r.ts", Page{}, func(StationID, StationName string, ts int64, value float64) error {
+		st, found := located[StationID]
+		timestamp := time.Unix(ts, 0)
+		if found == false || timestamp.Before(from) || timestamp.After(to) {
+			return nil
+		}
+		if sample, found := nearest[StationID]; found && absDuration(sample.Timestamp.Sub(at)) <= absDuration(timestamp.Sub(at)) {
+			return nil
+		}
+		nearest[StationID] = InterpolationSample{StationID: StationID, StationName: StationName, Location: Location{Latitude: st.Location.Latitude.Float64, Longitude: st.Location.Longitude.Float64},
+			Timestamp: timestamp.In(SGTLocation), Value: value}
+		return nil
+	})
+	if err != nil {
+		return samples, err
+	}
+	for _, sample := range nearest {
+		samples = append(samples, sample)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].StationName != samples[j].StationName {
+			return samples[i].StationName < samples[j].StationName
+		}
+		return samples[i].StationID < samples[j].StationID
+	})
+	return samples, nil
+}
+
+//absDuration - the duration without its sign.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+//Estimate - the interpolated value at the latitude and longitude, the value of the station when it is at the same location.
+func (ip *Interpolator) Estimate(lat, lon float64) Estimate {
+	estimate := Estimate{Location: Location{Latitude: lat, Longitude: lon}, Weights: make([]SampleWeight, len(ip.Samples))}
+	for i, sample := range ip.Samples {
+		estimate.Weights[i] = SampleWeight{InterpolationSample: sample, DistanceKm: haversineKm(estimate.Location, sample.Location)}
+	}
+
+	var weights []float64
+	if ip.Method == InterpolationKriging {
+		weights = ip.krigingWeights(estimate.Weights)
+	}
+	if weights == nil {
+		weights = ip.idwWeights(estimate.Weights)
+	}
+	for i, weight := range weights {
+		estimate.Weights[i].Weight = weight
+		estimate.Value += weight * estimate.Weights[i].Value
+	}
+	return estimate
+}
+
+//idwWeights - the inverse distance weights (summing to 1), all the weight to the station at the same location.
+func (ip *Interpolator) idwWeights(samples []SampleWeight) []float64 {
+	weights := make([]float64, len(samples))
+	total := 0.0
+	for i, sample := range samples {
+		if sample.DistanceKm < 1e-9 {
+			for j := range weights {
+				weights[j] = 0
+			}
+			weights[i] = 1
+			return weights
+		}
+		weights[i] = 1 / math.Pow(sample.DistanceKm, ip.Power)
+		total += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights
+}
+
+//variogram struct - the exponential variogram: gamma(h) = Sill * (1 - exp(-3h / RangeKm)), without nugget.
+type variogram struct {
+	Sill    float64
+	RangeKm float64
+}
+
+//gamma - the semivariance at the distance.
+func (v variogram) gamma(distanceKm float64) float64 {
+	return v.Sill * (1 - math.Exp(-3*distanceKm/v.RangeKm))
+}
+
+//fitVariogram - the variogram with the variance of the readings as the sill, and the range (up to the largest distance) fitted by the least squares
+//on the semivariance of every pair of stations.
+func fitVariogram(samples []InterpolationSample) variogram {
+	mean := 0.0
+	for _, sample := range samples {
+		mean += sample.Value
+	}
+	mean /= float64(len(samples))
+	fitted := variogram{}
+	for _, sample := range samples {
+		fitted.Sill += (sample.Value - mean) * (sample.Value - mean)
+	}
+	fitted.Sill /= float64(len(samples) - 1)
+
+	type pair struct{ distanceKm, semivariance float64 }
+	pairs := []pair{}
+	maxDistance := 0.0
+	for i := range samples {
+		for j := i + 1; j < len(samples); j++ {
+			d := haversineKm(samples[i].Location, samples[j].Location)
+			pairs = append(pairs, pair{d, (samples[i].Value - samples[j].Value) * (samples[i].Value - samples[j].Value) / 2})
+			maxDistance = math.Max(maxDistance, d)
+		}
+	}
+	bestSSE := math.Inf(1)
+	fitted.RangeKm = math.Max(maxDistance, krigingRangeSearchStepKm)
+	for rangeKm := krigingRangeSearchStepKm; rangeKm <= maxDistance; rangeKm += krigingRangeSearchStepKm {
+		candidate := variogram{Sill: fitted.Sill, RangeKm: rangeKm}
+		sse := 0.0
+		for _, p := range pairs {
+			sse += math.Pow(candidate.gamma(p.distanceKm)-p.semivariance, 2)
+		}
+		if sse < bestSSE {
+			bestSSE, fitted.RangeKm = sse, rangeKm
+		}
+	}
+	return fitted
+}
+
+//krigingWeights - the ordinary kriging weights (summing to 1), nil when the readings are all the same (no variance) or the system has no solution,
+//the IDW weights are used instead.
+func (ip *Interpolator) krigingWeights(samples []SampleWeight) []float64 {
+	if ip.variogram.Sill <= 0 {
+		return nil
+	}
+	n := len(samples)
+	//The kriging system: the semivariance between the stations, with the Lagrange multiplier for the weights summing to 1.
+	matrix := make([][]float64, n+1)
+	for i := range matrix {
+		matrix[i] = make([]float64, n+2)
+		for j := 0; j < n; j++ {
+			if i < n {
+				matrix[i][j] = ip.variogram.gamma(haversineKm(samples[i].Location, samples[j].Location))
+			} else {
+				matrix[i][j] = 1
+			}
+		}
+		if i < n {
+			matrix[i][n] = 1
+			matrix[i][n+1] = ip.variogram.gamma(samples[i].DistanceKm)
+		} else {
+			matrix[i][n+1] = 1
+		}
+	}
+	solution := solveLinear(matrix)
+	if solution == nil {
+		return nil
+	}
+	return solution[:n]
+}
+
+//solveLinear - the solution of the augmented matrix by the Gaussian elimination with the partial pivoting, nil if it is singular.
+func solveLinear(matrix [][]float64) []float64 {
+	n := len(matrix)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(matrix[row][col]) > math.Abs(matrix[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(matrix[pivot][col]) < 1e-12 {
+			return nil
+		}
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+		for row := col + 1; row < n; row++ {
+			factor := matrix[row][col] / matrix[col][col]
+			for k := col; k <= n; k++ {
+				matrix[row][k] -= factor * matrix[col][k]
+			}
+		}
+	}
+	solution := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := matrix[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= matrix[row][k] * solution[k]
+		}
+		solution[row] = sum / matrix[row][row]
+	}
+	return solution
+}
+
+//Grid - the Estimate at the center of every cell (cellSize degrees) of the bounding box, the last row and column may go past the north and east edge.
+func (ip *Interpolator) Grid(bbox BoundingBox, cellSize float64) (Grid, error) {
+	if cellSize <= 0 {
+		return Grid{}, &InputError{Value: fmt.Sprint(cellSize), Message: "the cell size must be more than 0", Err: ErrInvalidParameter}
+	}
+	if bbox.MinLat >= bbox.MaxLat || bbox.MinLon >= bbox.MaxLon || bbox.MinLat < -90 || bbox.MaxLat > 90 || bbox.MinLon < -180 || bbox.MaxLon > 180 {
+		return Grid{}, &InputError{Value: fmt.Sprintf("%v,%v,%v,%v", bbox.MinLat, bbox.MinLon, bbox.MaxLat, bbox.MaxLon), Message: "the bounding box must be min latitude, min longitude, max latitude, max longitude", Err: ErrInvalidParameter}
+	}
+	grid := Grid{Metric: ip.Metric, At: ip.At, Method: ip.Method, BoundingBox: bbox, CellSize: cellSize,
+		Rows: int(math.Ceil((bbox.MaxLat-bbox.MinLat)/cellSize - 1e-9)), Cols: int(math.Ceil((bbox.MaxLon-bbox.MinLon)/cellSize - 1e-9))}
+	if grid.Rows*grid.Cols > maxGridCells {
+		return Grid{}, &InputError{Value: fmt.Sprint(cellSize), Message: fmt.Sprintf("the grid would have %v cells, up to %v, please use a larger cell size", grid.Rows*grid.Cols, maxGridCells), Err: ErrInvalidParameter}
+	}
+	grid.Values = make([][]float64, grid.Rows)
+	for row := range grid.Values {
+		grid.Values[row] = make([]float64, grid.Cols)
+		for col := range grid.Values[row] {
+			lat, lon := grid.CellCenter(row, col)
+			grid.Values[row][col] = ip.Estimate(lat, lon).Value
+		}
+	}
+	return grid, nil
+}
+
+//CellCenter - the latitude and longitude of the center of the cell, the row 0 is the north edge.
+func (g Grid) CellCenter(row, col int) (lat, lon float64) {
+	return g.BoundingBox.MaxLat - (float64(row)+0.5)*g.CellSize, g.BoundingBox.MinLon + (float64(col)+0.5)*g.CellSize
+}
+
+//Write - write the Grid in the GridFormat.
+func (g Grid) Write(w io.Writer, format GridFormat) error {
+	out := bufio.NewWriter(w)
+	switch format {
+	case GridASCII:
+		//The lower left corner of the rows going down from the north edge.
+		fmt.Fprintf(out, "ncols %v\nnrows %v\nxllcorner %v\nyllcorner %v\ncellsize %v\nNODATA_value %v\n", g.Cols, g.Rows,
+			formatFloat(g.BoundingBox.MinLon), formatFloat(g.BoundingBox.MaxLat-float64(g.Rows)*g.CellSize), formatFloat(g.CellSize), gridNoDataValue)
+		for _, values := range g.Values {
+			cells := make([]string, len(values))
+			for col, value := range values {
+				cells[col] = formatGridValue(value)
+			}
+			out.WriteString(strings.Join(cells, " ") + "\n")
+		}
+	case GridGeoJSON:
+		if err := g.writeGeoJSON(out); err != nil {
+			return err
+		}
+	default:
+		writer := csv.NewWriter(out)
+		writer.Write([]string{"latitude", "longitude", "value"})
+		for row, values := range g.Values {
+			for col, value := range values {
+				lat, lon := g.CellCenter(row, col)
+				writer.Write([]string{formatFloat(roundCoordinate(lat)), formatFloat(roundCoordinate(lon)), formatGridValue(value)})
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+//gridFeature struct - the cell of the GeoJSON Grid.
+type gridFeature struct {
+	Type     string `json:"type"`
+	Geometry struct {
+		Type        string         `json:"type"`
+		Coordinates [][][2]float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties struct {
+		Value  json.Number `json:"value"`
+		Metric string      `json:"metric"`
+		Unit   string      `json:"unit"`
+	} `json:"properties"`
+}
+
+//writeGeoJSON - the FeatureCollection of the cells, one Polygon (counterclockwise) per cell, written one feature at a time.
+func (g Grid) writeGeoJSON(out *bufio.Writer) error {
+	out.WriteString(`{"type":"FeatureCollection","features":[`)
+	half := g.CellSize / 2
+	for row, values := range g.Values {
+		for col, value := range values {
+			lat, lon := g.CellCenter(row, col)
+			south, north, west, east := roundCoordinate(lat-half), roundCoordinate(lat+half), roundCoordinate(lon-half), roundCoordinate(lon+half)
+			feature := gridFeature{Type: "Feature"}
+			feature.Geometry.Type = "Polygon"
+			feature.Geometry.Coordinates = [][][2]float64{{{west, south}, {east, south}, {east, north}, {west, north}, {west, south}}}
+			feature.Properties.Value = json.Number(formatGridValue(value))
+			feature.Properties.Metric, feature.Properties.Unit = g.Metric.Name, g.Metric.Unit
+			body, err := json.Marshal(feature)
+			if err != nil {
+				return err
+			}
+			if row > 0 || col > 0 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+			out.Write(body)
+		}
+	}
+	out.WriteString("\n]}\n")
+	return nil
+}
+
+//formatGridValue - the value rounded to 2 decimals, the interpolation is not more precise than the readings.
+func formatGridValue(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Sprint(gridNoDataValue)
+	}
+	return formatFloat(math.Round(value*100) / 100)
+}
+
+//roundCoordinate - the coordinate rounded to 6 decimals (about 10 cm), without the floating point noise of the cell steps.
+func roundCoordinate(coordinate float64) float64 {
+	return math.Round(coordinate*1e6) / 1e6
+}
@@ -0,0 +1,190 @@
+package SGAirTemp
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testSamples - the readings of the mock stations, warmer to the east.
+func testSamples() []InterpolationSample {
+	samples := make([]InterpolationSample, len(mockStations))
+	for i, st := range mockStations {
+		samples[i] = InterpolationSample{StationID: st.StationID, StationName: st.StationName, Location: st.Location,
+			Value: 25 + (st.Location.Longitude-103.6)*10 + float64(i%2)*0.3}
+	}
+	return samples
+}
+
+// testInterpolator - the Interpolator of the samples, with the fitted variogram for the kriging.
+func testInterpolator(method InterpolationMethod, samples []InterpolationSample) *Interpolator {
+	ip := &Interpolator{Metric: MetricAirTemperature, Method: method, Power: DefaultIDWPower, Samples: samples}
+	if method == InterpolationKriging {
+		ip.variogram = fitVariogram(samples)
+	}
+	return ip
+}
+
+// sumWeights - the total weight of the Estimate.
+func sumWeights(estimate Estimate) float64 {
+	total := 0.0
+	for _, weight := range estimate.Weights {
+		total += weight.Weight
+	}
+	return total
+}
+
+func TestEstimateAtStation(t *testing.T) {
+	samples := testSamples()
+	for _, method := range []InterpolationMethod{InterpolationIDW, InterpolationKriging} {
+		ip := testInterpolator(method, samples)
+		for _, sample := range samples {
+			estimate := ip.Estimate(sample.Location.Latitude, sample.Location.Longitude)
+			if math.Abs(estimate.Value-sample.Value) > 1e-6 {
+				t.Errorf("%v at %v = %v, want the reading %v", method, sample.StationID, estimate.Value, sample.Value)
+			}
+		}
+	}
+
+	//The IDW gives all the weight to the station, not a division by 0.
+	estimate := testInterpolator(InterpolationIDW, samples).Estimate(samples[2].Location.Latitude, samples[2].Location.Longitude)
+	for i, weight := range estimate.Weights {
+		if want := map[bool]float64{true: 1, false: 0}[i == 2]; weight.Weight != want {
+			t.Errorf("IDW weight of %v = %v, want %v", weight.StationID, weight.Weight, want)
+		}
+	}
+}
+
+func TestEstimateWeightsSumToOne(t *testing.T) {
+	samples := testSamples()
+	points := []Location{{Latitude: 1.35, Longitude: 103.82}, {Latitude: 1.29, Longitude: 103.7}, {Latitude: 1.44, Longitude: 104.05}}
+	for _, method := range []InterpolationMethod{InterpolationIDW, InterpolationKriging} {
+		ip := testInterpolator(method, samples)
+		for _, point := range points {
+			estimate := ip.Estimate(point.Latitude, point.Longitude)
+			if total := sumWeights(estimate); math.Abs(total-1) > 1e-9 {
+				t.Errorf("%v weights at %v sum to %v, want 1", method, point, total)
+			}
+		}
+	}
+	//The kriging weights are the solution of the system, not the IDW fallback.
+	ip := testInterpolator(InterpolationKriging, samples)
+	estimate := ip.Estimate(points[0].Latitude, points[0].Longitude)
+	if ip.krigingWeights(estimate.Weights) == nil {
+		t.Error("krigingWeights = nil, want the solved weights")
+	}
+}
+
+func TestKrigingFallbackToIDW(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []InterpolationSample
+	}{
+		{
+			//Two stations at the same location make the system singular.
+			name:    "singular system",
+			samples: append(testSamples(), InterpolationSample{StationID: "S109-2", Location: mockStations[0].Location, Value: 40}),
+		},
+		{
+			name: "no variance",
+			samples: []InterpolationSample{
+				{StationID: "S1", Location: Location{Latitude: 1.30, Longitude: 103.7}, Value: 28},
+				{StationID: "S2", Location: Location{Latitude: 1.35, Longitude: 103.8}, Value: 28},
+				{StationID: "S3", Location: Location{Latitude: 1.40, Longitude: 103.9}, Value: 28},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kriging := testInterpolator(InterpolationKriging, tt.samples)
+			idw := testInterpolator(InterpolationIDW, tt.samples)
+			estimate := kriging.Estimate(1.36, 103.85)
+			if kriging.krigingWeights(estimate.Weights) != nil {
+				t.Fatal("krigingWeights solved, want nil for the IDW fallback")
+			}
+			want := idw.Estimate(1.36, 103.85)
+			if estimate.Value != want.Value {
+				t.Errorf("kriging = %v, want the IDW %v", estimate.Value, want.Value)
+			}
+			for i := range estimate.Weights {
+				if estimate.Weights[i].Weight != want.Weights[i].Weight {
+					t.Errorf("weight of %v = %v, want the IDW %v", estimate.Weights[i].StationID, estimate.Weights[i].Weight, want.Weights[i].Weight)
+				}
+			}
+		})
+	}
+}
+
+func TestSolveLinear(t *testing.T) {
+	//2x + y = 5, x - y = 1
+	solution := solveLinear([][]float64{{2, 1, 5}, {1, -1, 1}})
+	if len(solution) != 2 || math.Abs(solution[0]-2) > 1e-12 || math.Abs(solution[1]-1) > 1e-12 {
+		t.Errorf("solution = %v, want [2 1]", solution)
+	}
+	if solution := solveLinear([][]float64{{1, 2, 3}, {2, 4, 6}}); solution != nil {
+		t.Errorf("solution of the singular system = %v, want nil", solution)
+	}
+}
+
+func TestGridASCII(t *testing.T) {
+	ip := testInterpolator(InterpolationIDW, testSamples())
+	tests := []struct {
+		name   string
+		bbox   BoundingBox
+		cell   float64
+		header string
+	}{
+		{"exact cells", BoundingBox{MinLat: 1.2, MinLon: 103.6, MaxLat: 1.5, MaxLon: 104}, 0.1,
+			"ncols 4\nnrows 3\nxllcorner 103.6\nyllcorner 1.2\ncellsize 0.1\nNODATA_value -9999\n"},
+		//The last row goes past the south edge, the lower left corner is moved with it.
+		{"partial cells", BoundingBox{MinLat: 1.2, MinLon: 103.6, MaxLat: 1.45, MaxLon: 103.95}, 0.1,
+			"ncols 4\nnrows 3\nxllcorner 103.6\nyllcorner 1.15\ncellsize 0.1\nNODATA_value -9999\n"},
+		{"Singapore", SingaporeBoundingBox, DefaultGridCellSize,
+			"ncols 50\nnrows 33\nxllcorner 103.6\nyllcorner 1.15\ncellsize 0.01\nNODATA_value -9999\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid, err := ip.Grid(tt.bbox, tt.cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := grid.Write(&buf, GridASCII); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if header := strings.Join(lines[:6], "\n") + "\n"; header != tt.header {
+				t.Errorf("header:\n%v\nwant:\n%v", header, tt.header)
+			}
+			//One line per row, the north row first.
+			if len(lines) != 6+grid.Rows || len(strings.Fields(lines[6])) != grid.Cols {
+				t.Errorf("%v lines of %v values, want %v rows of %v", len(lines)-6, len(strings.Fields(lines[6])), grid.Rows, grid.Cols)
+			}
+			if lat, lon := grid.CellCenter(0, 0); math.Abs(lat-(tt.bbox.MaxLat-tt.cell/2)) > 1e-9 || math.Abs(lon-(tt.bbox.MinLon+tt.cell/2)) > 1e-9 {
+				t.Errorf("center of the north west cell = %v, %v, want half a cell from the corner %v, %v", lat, lon, tt.bbox.MaxLat, tt.bbox.MinLon)
+			}
+		})
+	}
+}
+
+func TestNewInterpolatorPower(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:00", 31)
+	tests := []struct {
+		power   float64
+		want    float64
+		wantErr error
+	}{
+		{0, DefaultIDWPower, nil},
+		{1.5, 1.5, nil},
+		{-1, 0, ErrInvalidParameter},
+	}
+	for _, tt := range tests {
+		ip, err := dbc.NewInterpolator(InterpolationOptions{Power: tt.power})
+		if errors.Is(err, tt.wantErr) == false || (err == nil && ip.Power != tt.want) {
+			t.Errorf("NewInterpolator(Power %v) = %+v, %v, want the power %v, %v", tt.power, ip, err, tt.want, tt.wantErr)
+		}
+	}
+}
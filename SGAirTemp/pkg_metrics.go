@@ -0,0 +1,76 @@
+package SGAirTemp
+
+import (
+	"strings"
+)
+
+//Metric struct - a measurement type of the realtime weather readings API, Name is also the API endpoint (environment/NAME).
+type Metric struct {
+	Name  string
+	Label string
+	Unit  string
+}
+
+//The metrics of the realtime weather readings, they share the same response shape (WeatherResponse).
+var (
+	MetricAirTemperature   = Metric{Name: "air-temperature", Label: "Temperature", Unit: "deg C"}
+	MetricRelativeHumidity = Metric{Name: "relative-humidity", Label: "Relative Humidity", Unit: "percentage"}
+	MetricRainfall         = Metric{Name: "rainfall", Label: "Rainfall", Unit: "mm"}
+	MetricWindSpeed        = Metric{Name: "wind-speed", Label: "Wind Speed", Unit: "knots"}
+	MetricWindDirection    = Metric{Name: "wind-direction", Label: "Wind Direction", Unit: "degrees"}
+)
+
+//DefaultMetric - the metric used when it is not chosen.
+var DefaultMetric = MetricAirTemperature
+
+//Metrics - all the supported metrics.
+func Metrics() []Metric {
+	return []Metric{MetricAirTemperature, MetricRelativeHumidity, MetricRainfall, MetricWindSpeed, MetricWindDirection}
+}
+
+//MetricNames - the names of all the supported metrics, including the DerivedMetrics.
+func MetricNames() []string {
+	names := []string{}
+	for _, m := range append(Metrics(), DerivedMetrics()...) {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+//MetricByName - the supported (or derived) metric with the name, empty name for the DefaultMetric.
+func MetricByName(name string) (Metric, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return DefaultMetric, nil
+	}
+	for _, m := range append(Metrics(), DerivedMetrics()...) {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return Metric{}, &InputError{Value: name, Message: "please choose " + strings.Join(MetricNames(), ", "), Err: ErrUnknownMetric}
+}
+
+//describeMetric - the label and unit of the Metric (including the DerivedMetrics and MetricWBGT) or the air quality reading type, only the name is known for the other.
+func describeMetric(name string) Metric {
+	for _, m := range append(append(append(Metrics(), DerivedMetrics()...), MetricWBGT), airQualityReadingTypes...) {
+		if m.Name == name {
+			return m
+		}
+	}
+	return Metric{Name: name, Label: name}
+}
+
+//metricFromQuery - the metric of the API query (environment/NAME?...), ie: the query saved on the ResponseStore.
+func metricFromQuery(query string) (Metric, error) {
+	endpoint := strings.SplitN(query, "?", 2)[0]
+	return MetricByName(strings.TrimPrefix(endpoint, "environment/"))
+}
+
+//metric - the Metric of the DB, DefaultMetric if it is not set.
+func (dbc *DB) metric() Metric {
+	if len(dbc.Metric.Name) > 0 {
+		return dbc.Metric
+	}
+	return DefaultMetric
+}
@@ -0,0 +1,236 @@
+package SGAirTemp
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//migrationFiles - the schema migrations, named VERSION_NAME.up.sql and VERSION_NAME.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+//Migration struct - one version of the database schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+//MigrationStatus struct - the Migration and when it was applied, AppliedAt is empty if it is not applied yet.
+type MigrationStatus struct {
+	Migration
+	AppliedAt string
+}
+
+//Migrations - all the Migrations embedded in the binary, ordered by the Version.
+func Migrations() ([]Migration, error) {
+	byVersion := map[int]*Migration{}
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name '%v'", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if ok == false {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %v has two names '%v' and '%v'", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if len(m.Up) == 0 {
+			return nil, fmt.Errorf("migration %04d_%v has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+//prepareSchemaVersion - create the schema_version table.
+//The database created before the schema_version table existed is recognized from its columns, so the applied migrations are not run again.
+func (dbc *DB) prepareSchemaVersion() error {
+	versionColumns, err := dbc.tableColumns("schema_version")
+	if err != nil {
+		return err
+	}
+	if len(versionColumns) > 0 {
+		return nil
+	}
+
+	if _, err := dbc.Exec("CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)"); err != nil {
+		return dbError("create table schema_version", err)
+	}
+
+	readingsColumns, err := dbc.tableColumns("readings")
+	if err != nil {
+		return err
+	}
+	baseline := 0
+	if _, ok := readingsColumns["ts"]; ok == true {
+		baseline = 2
+	} else if _, ok := readingsColumns["yr"]; ok == true {
+		baseline = 1
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version > baseline {
+			break
+		}
+		if _, err := dbc.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return dbError("record schema version", err)
+		}
+	}
+	return nil
+}
+
+//SchemaVersion - the latest applied migration version, 0 for the empty database.
+func (dbc *DB) SchemaVersion() (int, error) {
+	if err := dbc.prepareSchemaVersion(); err != nil {
+		return 0, err
+	}
+	strVersion, err := dbc.GetScalar("SELECT IFNULL(MAX(version), 0) scalarRes FROM schema_version")
+	if err != nil {
+		return 0, err
+	}
+	version, _ := strconv.Atoi(strVersion)
+	return version, nil
+}
+
+//MigrationStatus - every Migration with the time it was applied.
+func (dbc *DB) MigrationStatus() ([]MigrationStatus, error) {
+	var version int
+	var appliedAt string
+	applied := map[int]string{}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := dbc.prepareSchemaVersion(); err != nil {
+		return nil, err
+	}
+
+	rows, err := dbc.Query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, dbError("query schema version", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, dbError("query schema version", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("query schema version", err)
+	}
+
+	status := []MigrationStatus{}
+	for _, m := range migrations {
+		status = append(status, MigrationStatus{Migration: m, AppliedAt: applied[m.Version]})
+	}
+	return status, nil
+}
+
+//MigrateUp - apply the migrations until the target version, 0 for the latest version.
+//Every migration is applied in its own transaction together with its schema_version record.
+func (dbc *DB) MigrateUp(target int) ([]Migration, error) {
+	applied := []Migration{}
+	migrations, err := Migrations()
+	if err != nil {
+		return applied, err
+	}
+	current, err := dbc.SchemaVersion()
+	if err != nil {
+		return applied, err
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current || (target > 0 && m.Version > target) {
+			continue
+		}
+		if err := dbc.applyMigration(m.Up, "INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return applied, fmt.Errorf("migration %04d_%v up: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+//MigrateDown - revert the applied migrations, from the latest, until the target version is the latest applied.
+func (dbc *DB) MigrateDown(target int) ([]Migration, error) {
+	reverted := []Migration{}
+	migrations, err := Migrations()
+	if err != nil {
+		return reverted, err
+	}
+	current, err := dbc.SchemaVersion()
+	if err != nil {
+		return reverted, err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current || m.Version <= target {
+			continue
+		}
+		if len(m.Down) == 0 {
+			return reverted, fmt.Errorf("migration %04d_%v has no down file", m.Version, m.Name)
+		}
+		if err := dbc.applyMigration(m.Down, "DELETE FROM schema_version WHERE version = ?", m.Version); err != nil {
+			return reverted, fmt.Errorf("migration %04d_%v down: %w", m.Version, m.Name, err)
+		}
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
+
+//applyMigration - run the migration SQL and the schema_version statement in one transaction.
+func (dbc *DB) applyMigration(strSQL, versionSQL string, versionArgs ...interface{}) error {
+	tx, err := dbc.Begin()
+	if err != nil {
+		return dbError("begin migration", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(strSQL); err != nil {
+		return dbError("run migration", err)
+	}
+	if _, err := tx.Exec(versionSQL, versionArgs...); err != nil {
+		return dbError("record schema version", err)
+	}
+	return dbError("commit migration", tx.Commit())
+}
@@ -0,0 +1,222 @@
+package SGAirTemp
+
+import (
+	"strings"
+	"testing"
+)
+
+//testSchema - the SQL of every table and index (except schema_version), to compare the schema of two databases.
+func testSchema(t *testing.T, dbc *DB) map[string]string {
+	t.Helper()
+	var name, strSQL string
+	schema := map[string]string{}
+	rows, err := dbc.Query("SELECT name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name <> 'schema_version'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := rows.Scan(&name, &strSQL); err != nil {
+			t.Fatal(err)
+		}
+		schema[name] = strSQL
+	}
+	return schema
+}
+
+//latestMigration - the version of the last embedded Migration.
+func latestMigration(t *testing.T) int {
+	t.Helper()
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %v_%v, want the version %v (no gap)", m.Version, m.Name, i+1)
+		}
+		if len(m.Down) == 0 {
+			t.Errorf("migration %04d_%v has no down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrateUpDownRoundTrip(t *testing.T) {
+	dbc := openTestDB(t)
+	latest := latestMigration(t)
+
+	applied, err := dbc.MigrateUp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != latest {
+		t.Fatalf("MigrateUp applied %v migrations, want %v", len(applied), latest)
+	}
+	fresh := testSchema(t, dbc)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 14:00", 31.2)
+	saveTestReading(t, dbc, MetricRainfall, "S1", "2024-05-01 14:00", 0.2)
+
+	//Down to the timestamp schema, before the metric column: only the air temperature is kept.
+	if _, err := dbc.MigrateDown(2); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := dbc.SchemaVersion(); err != nil || version != 2 {
+		t.Fatalf("SchemaVersion after MigrateDown(2) = %v, %v, want 2", version, err)
+	}
+	if columns, _ := dbc.tableColumns("readings"); len(columns["metric"]) > 0 {
+		t.Errorf("readings still has the metric column after MigrateDown(2): %v", columns)
+	}
+	if cnt, err := dbc.GetScalar("SELECT count(*) FROM readings"); err != nil || cnt != "1" {
+		t.Errorf("readings after MigrateDown(2) = %v, %v, want the 1 air temperature reading", cnt, err)
+	}
+
+	//Up again: the schema is the same as the fresh database and the reading is kept.
+	if _, err := dbc.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	again := testSchema(t, dbc)
+	for name, strSQL := range fresh {
+		if again[name] != strSQL {
+			t.Errorf("schema of %v after down and up:\n%v\nwant:\n%v", name, again[name], strSQL)
+		}
+	}
+	if len(again) != len(fresh) {
+		t.Errorf("%v tables and indexes after down and up, want %v", len(again), len(fresh))
+	}
+	stats, err := dbc.Aggregate(Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01", Metric: MetricAirTemperature.Name})
+	if err != nil || stats.Count != 1 || stats.Max != 31.2 {
+		t.Errorf("air temperature after down and up = %+v, %v, want the 31.2 reading", stats, err)
+	}
+
+	//Down to nothing: only schema_version is left.
+	if _, err := dbc.MigrateDown(0); err != nil {
+		t.Fatal(err)
+	}
+	if left := testSchema(t, dbc); len(left) != 0 {
+		t.Errorf("tables left after MigrateDown(0): %v", left)
+	}
+}
+
+func TestMigrateUpTarget(t *testing.T) {
+	dbc := openTestDB(t)
+	applied, err := dbc.MigrateUp(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 3 || applied[2].Version != 3 {
+		t.Fatalf("MigrateUp(3) applied %v, want the versions 1 to 3", applied)
+	}
+	//The next call applies the rest only.
+	applied, err = dbc.MigrateUp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != latestMigration(t)-3 || applied[0].Version != 4 {
+		t.Errorf("MigrateUp(0) after 3 applied %v, want from the version 4", applied)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	dbc := openTestDB(t)
+	//The database of the first version, before schema_version: the Date/Time split in SGT and the location as TEXT.
+	legacy := []string{
+		"CREATE TABLE stations (station_id TEXT PRIMARY KEY, station_name TEXT, loc_latitude TEXT, loc_longitude TEXT)",
+		"CREATE TABLE readings (station_id TEXT, yr TEXT, mo TEXT, dt TEXT, hr TEXT, mi TEXT, value REAL)",
+		"INSERT INTO stations VALUES ('S109', 'Ang Mo Kio Avenue 5', '1.3764', '103.8492')",
+		"INSERT INTO stations VALUES ('S50', NULL, '1.3337', '103.7768')",
+		"INSERT INTO readings VALUES ('S109', '2024', '05', '01', '14', '00', 31.2)",
+		"INSERT INTO readings VALUES ('S109', '2024', '05', '01', '00', '05', 25.5)",
+		"INSERT INTO readings VALUES ('S50', '2024', '05', '01', '14', '00', NULL)",
+	}
+	for _, strSQL := range legacy {
+		if _, err := dbc.Exec(strSQL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := dbc.PrepareDBTable(); err != nil {
+		t.Fatal(err)
+	}
+	status, err := dbc.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if len(m.AppliedAt) == 0 {
+			t.Errorf("migration %04d_%v not applied on the legacy database", m.Version, m.Name)
+		}
+	}
+
+	//The SGT Date/Time is the Unix time, the reading without value is dropped.
+	var metric, tsSGT string
+	var ts int64
+	var value float64
+	rows, err := dbc.Query("SELECT metric, ts, ts_sgt, value FROM readings ORDER BY ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := []string{}
+	for rows.Next() {
+		if err := rows.Scan(&metric, &ts, &tsSGT, &value); err != nil {
+			t.Fatal(err)
+		}
+		if want := sgtTime(t, strings.Replace(tsSGT[:16], "T", " ", 1)).Unix(); ts != want {
+			t.Errorf("ts of %v = %v, want %v", tsSGT, ts, want)
+		}
+		got = append(got, metric+" "+tsSGT+" "+formatFloat(value))
+	}
+	want := []string{"air-temperature 2024-05-01T00:05:00+08:00 25.5", "air-temperature 2024-05-01T14:00:00+08:00 31.2"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("readings after the upgrade:\n%v\nwant:\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	//The location is REAL, the missing name is empty.
+	stations, _, err := dbc.Stations(Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 2 || stations[1].StationID != "S109" || stations[1].Location.Latitude != 1.3764 || stations[0].StationName != "" {
+		t.Errorf("stations after the upgrade = %+v", stations)
+	}
+}
+
+func TestPrepareSchemaVersionBaseline(t *testing.T) {
+	dbc := openTestDB(t)
+	//The timestamp schema (the version 2) created before schema_version, the first two migrations must not run again.
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:2] {
+		if _, err := dbc.Exec(m.Up); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saveTestReadingV2 := "INSERT INTO readings (station_id, ts, ts_sgt, value) VALUES ('S1', 1714543200, '2024-05-01T14:00:00+08:00', 30)"
+	if _, err := dbc.Exec(saveTestReadingV2); err != nil {
+		t.Fatal(err)
+	}
+
+	if version, err := dbc.SchemaVersion(); err != nil || version != 2 {
+		t.Fatalf("SchemaVersion of the timestamp schema = %v, %v, want 2", version, err)
+	}
+	applied, err := dbc.MigrateUp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) == 0 || applied[0].Version != 3 {
+		t.Errorf("MigrateUp applied %v, want from the version 3", applied)
+	}
+	if cnt, err := dbc.GetScalar("SELECT count(*) FROM readings WHERE metric = 'air-temperature'"); err != nil || cnt != "1" {
+		t.Errorf("readings after the upgrade = %v, %v, want 1", cnt, err)
+	}
+}
@@ -0,0 +1,485 @@
+package SGAirTemp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//mockStations - the stations served by the MockServer, taken from the real API.
+var mockStations = []Station{
+	{StationID: "S109", StationName: "Ang Mo Kio Avenue 5", Location: Location{Latitude: 1.3764, Longitude: 103.8492}},
+	{StationID: "S50", StationName: "Clementi Road", Location: Location{Latitude: 1.3337, Longitude: 103.7768}},
+	{StationID: "S107", StationName: "East Coast Parkway", Location: Location{Latitude: 1.3135, Longitude: 103.9625}},
+	{StationID: "S43", StationName: "Kim Chuan Road", Location: Location{Latitude: 1.3399, Longitude: 103.8878}},
+	{StationID: "S44", StationName: "Nanyang Avenue", Location: Location{Latitude: 1.34583, Longitude: 103.68166}},
+	{StationID: "S24", StationName: "Upper Changi Road North", Location: Location{Latitude: 1.3678, Longitude: 103.9826}},
+}
+
+//MockOptions struct - the data and the failure scenarios of the MockServer.
+type MockOptions struct {
+	//Stations - total stations served (max 6), 0 for all.
+	Stations int
+	//EmptyDates - the dates (YYYY-MM-DD) with no data, like the real API on 2020-06-10 and 2020-06-11.
+	EmptyDates []string
+	//ErrorDates - the dates (YYYY-MM-DD) always answered with ErrorStatus.
+	ErrorDates []string
+	//ErrorStatus - the status code of the ErrorDates, default is 500.
+	ErrorStatus int
+	//RateLimitEvery - every N-th request is answered with 429 and Retry-After: 1, 0 to disable.
+	RateLimitEvery int
+}
+
+//DefaultMockOptions - all stations, and the empty dates found on the real API.
+func DefaultMockOptions() MockOptions {
+	return MockOptions{EmptyDates: []string{"2020-06-10", "2020-06-11"}}
+}
+
+//MockHandler struct - a fake data.gov.sg API serving deterministic readings of every Metric, air quality and weather forecast API for any date (date) or date/time (date_time) query.
+//Every station has a reading on every minute (every 5 minutes for the rainfall), every region on every hour (PSI and PM2.5),
+//the value only depends on the metric, station/region and time, so the same query always returns the same response.
+type MockHandler struct {
+	opts       MockOptions
+	emptyDates map[string]bool
+	errorDates map[string]bool
+
+	mu       sync.Mutex
+	requests int
+}
+
+//NewMockHandler - create the MockHandler, mount it on the API version roots (/v1 and /v2).
+func NewMockHandler(opts MockOptions) *MockHandler {
+	h := &MockHandler{opts: opts, emptyDates: map[string]bool{}, errorDates: map[string]bool{}}
+	for _, d := range opts.EmptyDates {
+		h.emptyDates[d] = true
+	}
+	for _, d := range opts.ErrorDates {
+		h.errorDates[d] = true
+	}
+	return h
+}
+
+//NewMockServer - start the MockHandler on a local httptest server, use its URL + "/v1" as the APIClient BaseURL and its URL + "/v2" as the V2BaseURL.
+//Close the server after use.
+func NewMockServer(opts MockOptions) *httptest.Server {
+	mux := http.NewServeMux()
+	handler := NewMockHandler(opts)
+	mux.Handle("/v1/", http.StripPrefix("/v1", handler))
+	mux.Handle("/v2/", http.StripPrefix("/v2", handler))
+	return httptest.NewServer(mux)
+}
+
+//Requests - the total requests served so far.
+func (h *MockHandler) Requests() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.requests
+}
+
+func (h *MockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests++
+	totalRequests := h.requests
+	h.mu.Unlock()
+
+	metricName := strings.TrimPrefix(r.URL.Path, "/environment/")
+	isWBGT := r.URL.Path == "/"+strings.SplitN(wbgtQuery, "?", 2)[0] && r.URL.Query().Get("api") == MetricWBGT.Name
+	metric, err := MetricByName(metricName)
+	if IsDerivedMetric(metricName) {
+		err = ErrUnknownMetric
+	}
+	source, errSource := AirQualitySourceByName(metricName)
+	forecast, errForecast := ForecastSourceByName(metricName)
+	if errForecast == nil && forecast.Name != metricName {
+		errForecast = ErrUnknownMetric
+	}
+	if ((err != nil && errSource != nil && errForecast != nil) || len(metricName) == 0) && isWBGT == false {
+		mockError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if isWBGT == true {
+		metric = MetricWBGT
+	}
+	if errSource == nil {
+		//The air quality is reported hourly.
+		metric = Metric{Name: source.Name}
+	}
+	if h.opts.RateLimitEvery > 0 && totalRequests%h.opts.RateLimitEvery == 0 {
+		w.Header().Set("Retry-After", "1")
+		mockError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+
+	//The readings from the start of the day (date) or only the minute (date_time), in SGT.
+	//The v2 API (WBGT) has the date/time on the date too.
+	strDateTime, strDate := r.URL.Query().Get("date_time"), r.URL.Query().Get("date")
+	if isWBGT == true && strings.Contains(strDate, "T") {
+		strDateTime, strDate = strDate, ""
+	}
+	var dateFrom time.Time
+	totalMinutes := 1
+	if len(strDateTime) > 0 {
+		dt, err := time.ParseInLocation("2006-01-02T15:04:05", strDateTime, SGTLocation)
+		if err != nil {
+			mockError(w, http.StatusBadRequest, "date_time must be YYYY-MM-DDTHH:mm:ss")
+			return
+		}
+		dateFrom = dt.Truncate(mockInterval(metric))
+	} else if len(strDate) > 0 {
+		dt, err := time.ParseInLocation(strStandardFormat, strDate, SGTLocation)
+		if err != nil {
+			mockError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+			return
+		}
+		dateFrom = dt
+		totalMinutes = 24 * 60
+	} else {
+		dateFrom = time.Now().In(SGTLocation).Truncate(time.Minute)
+	}
+
+	strDate = dateFrom.Format(strStandardFormat)
+	if h.errorDates[strDate] == true {
+		status := h.opts.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		mockError(w, status, "internal server error")
+		return
+	}
+	if errSource == nil {
+		h.serveRegions(w, source, dateFrom, totalMinutes)
+		return
+	}
+	if errForecast == nil {
+		h.serveForecasts(w, forecast, dateFrom, totalMinutes)
+		return
+	}
+	if isWBGT == true {
+		h.serveWBGT(w, r.URL.Query().Get("paginationToken"), dateFrom, totalMinutes)
+		return
+	}
+
+	response := WeatherResponse{Metadata: Metadata{Station: []Station{}, ReadingUnit: metric.Unit}, Items: []WeatherData{}}
+	if h.emptyDates[strDate] == false && strDate >= EarliestDataAvail {
+		stations := mockStations
+		if h.opts.Stations > 0 && h.opts.Stations < len(stations) {
+			stations = stations[:h.opts.Stations]
+		}
+		response.Metadata.Station = stations
+
+		now := time.Now()
+		for m := 0; m < totalMinutes; m += int(mockInterval(metric) / time.Minute) {
+			ts := dateFrom.Add(time.Duration(m) * time.Minute)
+			if ts.After(now) {
+				break
+			}
+			WeatherData := WeatherData{Timestamp: ts.Format(time.RFC3339)}
+			for i, st := range stations {
+				WeatherData.Readings = append(WeatherData.Readings, Reading{StationID: st.StationID, Value: MockValue(metric, i, ts)})
+			}
+			response.Items = append(response.Items, WeatherData)
+		}
+		if len(response.Items) == 0 {
+			response.Metadata.Station = []Station{}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+//serveRegions - the hourly readings of the air quality API for every region, from dateFrom for totalMinutes.
+func (h *MockHandler) serveRegions(w http.ResponseWriter, source AirQualitySource, dateFrom time.Time, totalMinutes int) {
+	response := RegionResponse{RegionMetadata: []RegionMetadata{}, Items: []RegionData{}}
+	strDate := dateFrom.Format(strStandardFormat)
+	if h.emptyDates[strDate] == false && strDate >= EarliestDataAvail {
+		regions := append([]RegionMetadata{{Name: NationalRegion}}, DefaultRegions...)
+		readingTypes := []string{"pm25_one_hourly"}
+		if source.Name == SourcePSI.Name {
+			readingTypes = []string{"psi_twenty_four_hourly", "pm25_twenty_four_hourly", "pm10_twenty_four_hourly"}
+		}
+
+		now := time.Now()
+		for m := 0; m < totalMinutes; m += 60 {
+			ts := dateFrom.Add(time.Duration(m) * time.Minute)
+			if ts.After(now) {
+				break
+			}
+			RegionData := RegionData{Timestamp: ts.Format(time.RFC3339), UpdateTimestamp: ts.Add(8 * time.Minute).Format(time.RFC3339), Readings: map[string]map[string]float64{}}
+			for _, readingType := range readingTypes {
+				RegionData.Readings[readingType] = map[string]float64{}
+				for i, rg := range regions {
+					RegionData.Readings[readingType][rg.Name] = MockRegionValue(readingType, i, ts)
+				}
+			}
+			response.Items = append(response.Items, RegionData)
+		}
+		if len(response.Items) > 0 {
+			response.RegionMetadata = regions
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+//mockForecastAreas - the areas of the 2-hour forecast served by the MockServer, taken from the real API.
+var mockForecastAreas = []RegionMetadata{
+	{Name: "Ang Mo Kio", LabelLocation: Location{Latitude: 1.375, Longitude: 103.839}},
+	{Name: "Bedok", LabelLocation: Location{Latitude: 1.321, Longitude: 103.924}},
+	{Name: "Changi", LabelLocation: Location{Latitude: 1.357, Longitude: 103.987}},
+	{Name: "City", LabelLocation: Location{Latitude: 1.292, Longitude: 103.844}},
+	{Name: "Clementi", LabelLocation: Location{Latitude: 1.315, Longitude: 103.76}},
+	{Name: "Jurong West", LabelLocation: Location{Latitude: 1.34, Longitude: 103.705}},
+}
+
+//serveForecasts - the forecast issuances of the day (totalMinutes > 1), or the latest issuance at dateFrom.
+//The 2-hour forecast is issued every 30 minutes, the 24-hour forecast at 05:30, 11:30 and 17:30, and the 4-day forecast at 05:30.
+func (h *MockHandler) serveForecasts(w http.ResponseWriter, source ForecastSource, dateFrom time.Time, totalMinutes int) {
+	response := ForecastResponse{Items: []ForecastData{}}
+	strDate := dateFrom.Format(strStandardFormat)
+	if h.emptyDates[strDate] == false && strDate >= EarliestDataAvail {
+		now := time.Now()
+		for _, issued := range mockIssuances(source, dateFrom, totalMinutes) {
+			if issued.After(now) {
+				break
+			}
+			response.Items = append(response.Items, mockForecast(source, issued))
+		}
+		if source.Name == Forecast2Hour.Name && len(response.Items) > 0 {
+			response.AreaMetadata = mockForecastAreas
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+//mockIssuances - the issuance times of the forecast on the day of dateFrom (totalMinutes > 1), or only the latest one at dateFrom.
+func mockIssuances(source ForecastSource, dateFrom time.Time, totalMinutes int) []time.Time {
+	schedule := func(day time.Time) []time.Time {
+		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, SGTLocation)
+		issuances := []time.Time{}
+		switch source.Name {
+		case Forecast2Hour.Name:
+			for m := 0; m < 24*60; m += 30 {
+				issuances = append(issuances, dayStart.Add(time.Duration(m)*time.Minute))
+			}
+		case Forecast24Hour.Name:
+			for _, h := range []int{5, 11, 17} {
+				issuances = append(issuances, dayStart.Add(time.Duration(h)*time.Hour+30*time.Minute))
+			}
+		default:
+			issuances = append(issuances, dayStart.Add(5*time.Hour+30*time.Minute))
+		}
+		return issuances
+	}
+
+	if totalMinutes > 1 {
+		return schedule(dateFrom)
+	}
+	issuances := append(schedule(dateFrom.AddDate(0, 0, -1)), schedule(dateFrom)...)
+	for i := len(issuances) - 1; i >= 0; i-- {
+		if issuances[i].After(dateFrom) == false {
+			return issuances[i : i+1]
+		}
+	}
+	return nil
+}
+
+//mockForecast - the deterministic forecast issued at the time, the forecasted temperature range is the range of the mock readings,
+//a degree or two off on some days, and more off for the later days of the 4-day forecast.
+func mockForecast(source ForecastSource, issued time.Time) ForecastData {
+	item := ForecastData{Timestamp: issued.Format(time.RFC3339), UpdateTimestamp: issued.Add(5 * time.Minute).Format(time.RFC3339)}
+	switch source.Name {
+	case Forecast2Hour.Name:
+		item.ValidPeriod = &ValidPeriod{Start: issued.Format(time.RFC3339), End: issued.Add(2 * time.Hour).Format(time.RFC3339)}
+		for i, area := range mockForecastAreas {
+			item.Forecasts = append(item.Forecasts, ForecastEntry{Area: area.Name, Forecast: mockForecastText(i, issued.Add(time.Hour))})
+		}
+	case Forecast24Hour.Name:
+		validFrom := issued.Add(30 * time.Minute)
+		item.ValidPeriod = &ValidPeriod{Start: validFrom.Format(time.RFC3339), End: validFrom.Add(24 * time.Hour).Format(time.RFC3339)}
+		general := mockForecastEntry(issued, validFrom, validFrom.Add(24*time.Hour), 0)
+		item.General = &general
+		for p := 0; p < 4; p++ {
+			periodFrom := validFrom.Add(time.Duration(p*6) * time.Hour)
+			period := ForecastPeriod{Time: ValidPeriod{Start: periodFrom.Format(time.RFC3339), End: periodFrom.Add(6 * time.Hour).Format(time.RFC3339)}, Regions: map[string]string{}}
+			for i, rg := range DefaultRegions {
+				period.Regions[rg.Name] = mockForecastText(i, periodFrom.Add(3*time.Hour))
+			}
+			item.Periods = append(item.Periods, period)
+		}
+	default:
+		issuedDay := time.Date(issued.Year(), issued.Month(), issued.Day(), 0, 0, 0, 0, SGTLocation)
+		for d := 1; d <= 4; d++ {
+			day := issuedDay.AddDate(0, 0, d)
+			entry := mockForecastEntry(issued, day, day.AddDate(0, 0, 1), d)
+			entry.Date = day.Format(strStandardFormat)
+			entry.Timestamp = day.Format(time.RFC3339)
+			item.Forecasts = append(item.Forecasts, entry)
+		}
+	}
+	return item
+}
+
+//mockForecastEntry - the national forecast for the valid period, the error grows with the lead (days).
+func mockForecastEntry(issued, validFrom, validTo time.Time, lead int) ForecastEntry {
+	tempLow, tempHigh := math.Inf(1), math.Inf(-1)
+	humidityLow, humidityHigh := math.Inf(1), math.Inf(-1)
+	for ts := validFrom; ts.Before(validTo); ts = ts.Add(time.Hour) {
+		for i := range mockStations {
+			temp := MockValue(MetricAirTemperature, i, ts)
+			tempLow, tempHigh = math.Min(tempLow, temp), math.Max(tempHigh, temp)
+			humidity := MockValue(MetricRelativeHumidity, i, ts)
+			humidityLow, humidityHigh = math.Min(humidityLow, humidity), math.Max(humidityHigh, humidity)
+		}
+	}
+	offset := float64((issued.YearDay()+lead)%3 - 1)
+	spread := float64(1 + lead/2)
+
+	direction := "VARIABLE"
+	switch issued.Month() {
+	case time.December, time.January, time.February, time.March:
+		direction = "NE"
+	case time.June, time.July, time.August, time.September:
+		direction = "SW"
+	}
+	return ForecastEntry{
+		Forecast:         mockForecastText(0, validFrom.Add(9*time.Hour)),
+		Temperature:      &ForecastRange{Low: math.Floor(tempLow) + offset*spread, High: math.Ceil(tempHigh) + offset},
+		RelativeHumidity: &ForecastRange{Low: math.Floor(humidityLow/5) * 5, High: math.Ceil(humidityHigh/5) * 5},
+		Wind:             &ForecastWind{Speed: &ForecastRange{Low: 10, High: 20}, Direction: direction},
+	}
+}
+
+//mockForecastText - the forecast of the area (index) at the time, following the mock rainfall.
+func mockForecastText(areaIndex int, ts time.Time) string {
+	ts = ts.In(SGTLocation)
+	if (ts.YearDay()+areaIndex)%3 == 0 && ts.Hour() >= 14 && ts.Hour() < 18 {
+		return "Thundery Showers"
+	}
+	if ts.Hour() >= 7 && ts.Hour() < 19 {
+		return "Partly Cloudy (Day)"
+	}
+	return "Partly Cloudy (Night)"
+}
+
+//mockWBGTPageSize - the total records on every page of the WBGT API.
+const mockWBGTPageSize = 32
+
+//serveWBGT - the page (paginationToken is the first record, empty for the first page) of the WBGT records every 15 minutes, from dateFrom for totalMinutes.
+func (h *MockHandler) serveWBGT(w http.ResponseWriter, paginationToken string, dateFrom time.Time, totalMinutes int) {
+	response := WBGTResponse{}
+	response.Data.Records = []WBGTRecord{}
+	strDate := dateFrom.Format(strStandardFormat)
+	if h.emptyDates[strDate] == false && strDate >= EarliestDataAvail {
+		stations := mockStations
+		if h.opts.Stations > 0 && h.opts.Stations < len(stations) {
+			stations = stations[:h.opts.Stations]
+		}
+		first, _ := strconv.Atoi(paginationToken)
+
+		now := time.Now()
+		interval := int(mockInterval(MetricWBGT) / time.Minute)
+		for m := first * interval; m < totalMinutes; m += interval {
+			ts := dateFrom.Add(time.Duration(m) * time.Minute)
+			if ts.After(now) {
+				break
+			}
+			if len(response.Data.Records) == mockWBGTPageSize {
+				response.Data.PaginationToken = strconv.Itoa(m / interval)
+				break
+			}
+			record := WBGTRecord{DateTime: ts.Format(time.RFC3339), UpdatedTimestamp: ts.Add(2 * time.Minute).Format(time.RFC3339)}
+			record.Item.IsStationData = true
+			record.Item.Type = "observation"
+			for i, st := range stations {
+				wbgt := MockValue(MetricWBGT, i, ts)
+				category := HeatStressCategory(wbgt)
+				record.Item.Readings = append(record.Item.Readings, WBGTReading{
+					Station:    WBGTStation{ID: st.StationID, Name: st.StationName},
+					Location:   WBGTLocation{Latitude: json.Number(strconv.FormatFloat(st.Location.Latitude, 'f', -1, 64)), Longitude: json.Number(strconv.FormatFloat(st.Location.Longitude, 'f', -1, 64))},
+					WBGT:       json.Number(strconv.FormatFloat(wbgt, 'f', 1, 64)),
+					HeatStress: strings.ToUpper(category[:1]) + category[1:],
+				})
+			}
+			response.Data.Records = append(response.Data.Records, record)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+//mockInterval - the time between the readings of the metric (or the air quality API).
+func mockInterval(metric Metric) time.Duration {
+	switch metric.Name {
+	case MetricWBGT.Name:
+		return 15 * time.Minute
+	case MetricRainfall.Name:
+		return 5 * time.Minute
+	case SourcePSI.Name, SourcePM25.Name:
+		return time.Hour
+	}
+	return time.Minute
+}
+
+//MockRegionValue - the deterministic air quality reading of the reading type for the region (index) at the time.
+//The PSI is between 40 and 70, a bit higher every few days, the PM values follow it.
+func MockRegionValue(readingType string, regionIndex int, ts time.Time) float64 {
+	ts = ts.In(SGTLocation)
+	daily := math.Sin(2 * math.Pi * float64(ts.Hour()-6) / 24)
+	base := 50 + 8*daily + float64(regionIndex%3)*2 + float64(ts.YearDay()%5)*2
+
+	switch readingType {
+	case "pm25_one_hourly":
+		return math.Round(base/3 + 4*daily)
+	case "pm25_twenty_four_hourly":
+		return math.Round(base / 3)
+	case "pm10_twenty_four_hourly":
+		return math.Round(base / 2)
+	}
+	return math.Round(base)
+}
+
+//MockValue - the deterministic reading of the metric for the station (index) at the time.
+//The temperature is between 24 and 33 degree, the highest at 14:00 SGT, the humidity goes the other way,
+//it rains on the afternoon of some days, the WBGT reaches the high heat stress on the hottest afternoons and the wind turns around the day.
+func MockValue(metric Metric, stationIndex int, ts time.Time) float64 {
+	ts = ts.In(SGTLocation)
+	minuteOfDay := float64(ts.Hour()*60 + ts.Minute())
+	daily := math.Sin(2 * math.Pi * (minuteOfDay - 480) / 1440)
+
+	var value float64
+	switch metric.Name {
+	case MetricRelativeHumidity.Name:
+		value = 80 - 14*daily - float64(stationIndex%5)*0.5
+	case MetricRainfall.Name:
+		if (ts.YearDay()+stationIndex)%3 == 0 && ts.Hour() >= 15 && ts.Hour() < 17 {
+			value = 0.2 * float64(1+stationIndex%3)
+		}
+	case MetricWindSpeed.Name:
+		value = 6 + 4*daily + float64(stationIndex%4)*0.5
+	case MetricWBGT.Name:
+		value = 28.5 + 4.5*daily + float64(stationIndex%3)*0.4 + float64(ts.YearDay()%5)*0.2
+	case MetricWindDirection.Name:
+		return math.Mod(math.Round(90+minuteOfDay/4+float64(stationIndex*30)), 360)
+	default:
+		value = 28 + 4*daily + float64(stationIndex%5)*0.3 + float64(ts.YearDay()%7)*0.1
+	}
+	return math.Round(value*10) / 10
+}
+
+//mockError - the error body, in the same shape as the real API.
+func mockError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"code":%v,"message":%v}`, status, strconv.Quote(message))
+}
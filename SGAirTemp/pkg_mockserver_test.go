@@ -0,0 +1,141 @@
+package SGAirTemp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCallTemperatureAPIAndSave(t *testing.T) {
+	dbc := newTestDB(t)
+	client, handler := newMockClient(t, MockOptions{Stations: 3})
+	dbc.API = client
+
+	if err := dbc.CallTemperatureAPIAndSave(context.Background(), "2024-05-01", "", false); err != nil {
+		t.Fatal(err)
+	}
+	filter := Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01"}
+	if total, err := dbc.CountReadings(filter); err != nil || total != 3*24*60 {
+		t.Fatalf("readings saved = %v, %v, want a reading every minute of 3 stations", total, err)
+	}
+	stations, _, err := dbc.Stations(Page{})
+	if err != nil || len(stations) != 3 {
+		t.Fatalf("stations saved = %+v, %v, want 3", stations, err)
+	}
+
+	//The saved value is the one served for the station and minute.
+	ts := sgtTime(t, "2024-05-01 13:37")
+	want := MockValue(MetricAirTemperature, 1, ts)
+	value, err := dbc.GetScalar("SELECT value scalarRes FROM readings WHERE metric = ? AND station_id = ? AND ts = ?", MetricAirTemperature.Name, mockStations[1].StationID, ts.Unix())
+	if err != nil || value != formatFloat(want) {
+		t.Errorf("value of %v at 13:37 = %v, %v, want %v", mockStations[1].StationID, value, err, want)
+	}
+
+	//Calling again replaces the same readings.
+	if err := dbc.CallTemperatureAPIAndSave(context.Background(), "2024-05-01", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if total, _ := dbc.CountReadings(filter); total != 3*24*60 || handler.Requests() != 2 {
+		t.Errorf("%v readings after %v requests, want %v after 2", total, handler.Requests(), 3*24*60)
+	}
+}
+
+func TestCallTemperatureAPIAndSaveFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     MockOptions
+		wantErr  error
+		requests int
+	}{
+		{"empty day", DefaultMockOptions(), ErrAPIEmptyBody, 1},
+		{"5xx after the retries", MockOptions{ErrorDates: []string{"2020-06-10"}, ErrorStatus: 502}, ErrAPIUnavailable, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc := newTestDB(t)
+			client, handler := newMockClient(t, tt.opts)
+			dbc.API = client
+
+			err := dbc.CallTemperatureAPIAndSave(context.Background(), "2020-06-10", "", false)
+			if errors.Is(err, tt.wantErr) == false {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if handler.Requests() != tt.requests {
+				t.Errorf("%v requests served, want %v", handler.Requests(), tt.requests)
+			}
+			if total, err := dbc.CountReadings(Filter{}); err != nil || total != 0 {
+				t.Errorf("readings saved = %v, %v, want none", total, err)
+			}
+		})
+	}
+}
+
+func TestCallTemperatureAPIAndSaveRetryAfter(t *testing.T) {
+	dbc := newTestDB(t)
+	//The first request is answered, the second with 429 and Retry-After: 1.
+	client, handler := newMockClient(t, MockOptions{Stations: 2, RateLimitEvery: 2})
+	dbc.API = client
+
+	if err := dbc.CallTemperatureAPIAndSave(context.Background(), "2024-05-01", "", false); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := dbc.CallTemperatureAPIAndSave(context.Background(), "2024-05-02", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("saved after %v, want to wait the Retry-After of 1s", elapsed)
+	}
+	if total, err := dbc.CountReadings(Filter{DateFrom: "2024-05-02", DateTo: "2024-05-02"}); err != nil || total != 2*24*60 || handler.Requests() != 3 {
+		t.Errorf("%v readings, %v after %v requests, want %v after 3", total, err, handler.Requests(), 2*24*60)
+	}
+}
+
+func TestEnsureOneDayHourlyData(t *testing.T) {
+	dbc := newTestDB(t)
+	//Every 10th request is rate limited (waiting the Retry-After of 1s), the scheduler still saves the 24 hours.
+	//One worker, so the retry is never rate limited again.
+	client, handler := newMockClient(t, MockOptions{Stations: 2, RateLimitEvery: 10})
+	dbc.API = client
+	dbc.Scheduler = NewFetchScheduler(1, 0)
+
+	if err := dbc.EnsureOneDayHourlyData(context.Background(), "2024-05-01", "", false); err != nil {
+		t.Fatal(err)
+	}
+	filter := Filter{DateFrom: "2024-05-01", DateTo: "2024-05-01", Minutes: []int{0}}
+	if total, err := dbc.CountReadings(filter); err != nil || total != 2*24 {
+		t.Errorf("hourly readings saved = %v, %v, want %v", total, err, 2*24)
+	}
+	if handler.Requests() <= 24 {
+		t.Errorf("%v requests served, want the 24 hours and the retries", handler.Requests())
+	}
+
+	//The complete day is not called again.
+	served := handler.Requests()
+	if err := dbc.EnsureOneDayHourlyData(context.Background(), "2024-05-01", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if handler.Requests() != served {
+		t.Errorf("%v requests served for the complete day, want none", handler.Requests()-served)
+	}
+}
+
+func TestPrintOneMonthStatisticFirstMonth(t *testing.T) {
+	dbc := newTestDB(t)
+	client, handler := newMockClient(t, MockOptions{Stations: 2})
+	dbc.API = client
+	dbc.Scheduler = NewFetchScheduler(4, 0)
+
+	//December 2016 is called from the EarliestDataAvail, including it.
+	if err := dbc.PrintOneMonthStatistic(context.Background(), "2016-12", ""); err != nil {
+		t.Fatal(err)
+	}
+	if handler.Requests() != 18*24 {
+		t.Errorf("%v requests served, want the 24 hours of the 18 days from %v", handler.Requests(), EarliestDataAvail)
+	}
+	filter := Filter{DateFrom: EarliestDataAvail, DateTo: EarliestDataAvail, Minutes: []int{0}}
+	if total, err := dbc.CountReadings(filter); err != nil || total != 2*24 {
+		t.Errorf("hourly readings saved on %v = %v, %v, want %v", EarliestDataAvail, total, err, 2*24)
+	}
+}
@@ -0,0 +1,194 @@
+//go:build parquet
+
+package SGAirTemp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+//parquetBatchSize - the rows buffered before they are given to the Parquet writer.
+const parquetBatchSize = 4096
+
+//ParquetReading struct - one row of the Parquet export, the reading with its station.
+//The timestamp is the UTC instant (TIMESTAMP_MICROS, adjusted to UTC), the partition is the year and month of the SGT time.
+type ParquetReading struct {
+	Timestamp   time.Time `parquet:"timestamp,timestamp(microsecond)"`
+	Metric      string    `parquet:"metric,dict"`
+	StationID   string    `parquet:"station_id,dict"`
+	StationName string    `parquet:"station_name,dict"`
+	Latitude    float64   `parquet:"latitude"`
+	Longitude   float64   `parquet:"longitude"`
+	Value       float64   `parquet:"value"`
+}
+
+//parquetPartitionWriter struct - the Parquet file of one partition, written to a temporary file until it is complete.
+type parquetPartitionWriter struct {
+	partition ParquetPartition
+	file      *os.File
+	writer    *parquet.GenericWriter[ParquetReading]
+	batch     []ParquetReading
+}
+
+//ExportParquet - write the readings matching the filter (of the Metric of the DB if the filter has none) to Parquet files (Snappy compressed)
+//partitioned by the year and month (SGT) in the Hive layout: DIR/year=YYYY/month=MM/METRIC.parquet, so the directory can be read by DuckDB or Spark as one table.
+//The readings are streamed in the time order, only the current month is open. The file is renamed to its path once complete, replacing the previous export of the month,
+//so the file always has the whole month: the DateFrom and DateTo are rounded out to the first and last day of their month (see parquetFilter).
+//The export stopped by the ctx (ie: Ctrl+C) keeps the months already complete, the current one is removed.
+func (dbc *DB) ExportParquet(ctx context.Context, dir string, filter Filter) (partitions []ParquetPartition, err error) {
+	filter, err = parquetFilter(dbc.dbFilter(filter))
+	if err != nil {
+		return partitions, err
+	}
+	stations, _, err := dbc.Stations(Page{})
+	if err != nil {
+		return partitions, err
+	}
+	stationByID := map[string]Station{}
+	for _, st := range stations {
+		stationByID[st.StationID] = st
+	}
+
+	var current *parquetPartitionWriter
+	defer func() {
+		//Failed in the middle, the incomplete file is not kept.
+		if current != nil {
+			current.abort()
+		}
+	}()
+
+	err = dbc.queryReadings(filter, "r.ts, s.station_name", Page{}, func(StationID, StationName string, ts int64, value float64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		timestamp := time.Unix(ts, 0).UTC()
+		sgt := timestamp.In(SGTLocation)
+		path := filepath.Join(dir, fmt.Sprintf("year=%04d", sgt.Year()), fmt.Sprintf("month=%02d", int(sgt.Month())), filter.Metric+".parquet")
+		if current == nil || current.partition.Path != path {
+			if current != nil {
+				if err := current.close(); err != nil {
+					return err
+				}
+				partitions = append(partitions, current.partition)
+				current = nil
+			}
+			var err error
+			if current, err = newParquetPartitionWriter(path); err != nil {
+				return err
+			}
+		}
+		station := stationByID[StationID]
+		return current.write(ParquetReading{
+			Timestamp:   timestamp,
+			Metric:      filter.Metric,
+			StationID:   StationID,
+			StationName: StationName,
+			Latitude:    station.Location.Latitude,
+			Longitude:   station.Location.Longitude,
+			Value:       value,
+		})
+	})
+	if err != nil {
+		return partitions, err
+	}
+	if current != nil {
+		if err := current.close(); err != nil {
+			return partitions, err
+		}
+		partitions = append(partitions, current.partition)
+		current = nil
+	}
+	return partitions, nil
+}
+
+//parquetFilter - the filter of whole months, the DateFrom is moved to the first day of its month and the DateTo to the last day of its month.
+//The filter of the stations, hours or minutes is refused, its file would replace the month of all the readings with only a part of it.
+func parquetFilter(filter Filter) (Filter, error) {
+	if len(filter.StationIDs) > 0 || len(filter.Hours) > 0 || len(filter.Minutes) > 0 {
+		return filter, &InputError{Value: "station, hours or minutes", Message: "the parquet file of the month always has all the readings of the month, please filter by the dates only", Err: ErrInvalidParameter}
+	}
+	if len(filter.DateFrom) > 0 {
+		DateFrom, err := sgtDayStart(filter.DateFrom)
+		if err != nil {
+			return filter, err
+		}
+		filter.DateFrom = DateFrom.AddDate(0, 0, 1-DateFrom.Day()).Format(strStandardFormat)
+	}
+	if len(filter.DateTo) > 0 {
+		DateTo, err := sgtDayStart(filter.DateTo)
+		if err != nil {
+			return filter, err
+		}
+		filter.DateTo = time.Date(DateTo.Year(), DateTo.Month()+1, 0, 0, 0, 0, 0, SGTLocation).Format(strStandardFormat)
+	}
+	return filter, nil
+}
+
+//newParquetPartitionWriter - create the directory of the partition and its temporary file.
+func newParquetPartitionWriter(path string) (*parquetPartitionWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	return &parquetPartitionWriter{
+		partition: ParquetPartition{Path: path},
+		file:      file,
+		writer:    parquet.NewGenericWriter[ParquetReading](file, parquet.Compression(&parquet.Snappy)),
+		batch:     make([]ParquetReading, 0, parquetBatchSize),
+	}, nil
+}
+
+//write - add the row to the batch, the full batch is given to the Parquet writer.
+func (pw *parquetPartitionWriter) write(row ParquetReading) error {
+	pw.batch = append(pw.batch, row)
+	pw.partition.Rows++
+	if len(pw.batch) < parquetBatchSize {
+		return nil
+	}
+	return pw.flush()
+}
+
+//flush - give the batch to the Parquet writer.
+func (pw *parquetPartitionWriter) flush() error {
+	if _, err := pw.writer.Write(pw.batch); err != nil {
+		return err
+	}
+	pw.batch = pw.batch[:0]
+	return nil
+}
+
+//close - complete the Parquet file and rename it to the partition path.
+func (pw *parquetPartitionWriter) close() error {
+	if err := pw.flush(); err != nil {
+		pw.abort()
+		return err
+	}
+	if err := pw.writer.Close(); err != nil {
+		pw.abort()
+		return err
+	}
+	if err := pw.file.Close(); err != nil {
+		os.Remove(pw.file.Name())
+		return err
+	}
+	if err := os.Rename(pw.file.Name(), pw.partition.Path); err != nil {
+		os.Remove(pw.file.Name())
+		return err
+	}
+	return nil
+}
+
+//abort - remove the incomplete temporary file.
+func (pw *parquetPartitionWriter) abort() {
+	pw.file.Close()
+	os.Remove(pw.file.Name())
+}
@@ -0,0 +1,11 @@
+//go:build !parquet
+
+package SGAirTemp
+
+import "context"
+
+//ExportParquet - the Parquet writer needs github.com/parquet-go/parquet-go, it is only built with the parquet build tag (go build -tags parquet).
+//Without it, ExportParquet always returns ErrParquetNotBuilt.
+func (dbc *DB) ExportParquet(ctx context.Context, dir string, filter Filter) ([]ParquetPartition, error) {
+	return nil, ErrParquetNotBuilt
+}
@@ -0,0 +1,73 @@
+//go:build parquet
+
+package SGAirTemp
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestParquetFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		from, to string
+		wantErr  error
+	}{
+		{"no dates", Filter{}, "", "", nil},
+		{"part of the month", Filter{DateFrom: "2024-05-15", DateTo: "2024-05-20"}, "2024-05-01", "2024-05-31", nil},
+		{"months", Filter{DateFrom: "2023-12-31", DateTo: "2024-02-01"}, "2023-12-01", "2024-02-29", nil},
+		{"whole month", Filter{DateFrom: "2024-04-01", DateTo: "2024-04-30"}, "2024-04-01", "2024-04-30", nil},
+		{"invalid date", Filter{DateFrom: "15/05/2024"}, "", "", ErrInvalidDateFormat},
+		{"stations", Filter{StationIDs: []string{"S1"}}, "", "", ErrInvalidParameter},
+		{"hourly", Filter{Minutes: []int{0}}, "", "", ErrInvalidParameter},
+	}
+	for _, tt := range tests {
+		filter, err := parquetFilter(tt.filter)
+		if tt.wantErr != nil {
+			if errors.Is(err, tt.wantErr) == false {
+				t.Errorf("%v: error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || filter.DateFrom != tt.from || filter.DateTo != tt.to {
+			t.Errorf("%v: filter from %v to %v, %v, want from %v to %v", tt.name, filter.DateFrom, filter.DateTo, err, tt.from, tt.to)
+		}
+	}
+}
+
+func TestExportParquetWholeMonths(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-04-30 23:59", 27)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 00:00", 28)
+	saveTestReading(t, dbc, MetricAirTemperature, "S2", "2024-05-15 12:00", 31)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-31 23:59", 26)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-06-01 00:00", 25)
+	dir := t.TempDir()
+
+	//The export of one day writes the whole month.
+	partitions, err := dbc.ExportParquet(context.Background(), dir, Filter{DateFrom: "2024-05-15", DateTo: "2024-05-15"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ParquetPartition{Path: filepath.Join(dir, "year=2024", "month=05", "air-temperature.parquet"), Rows: 3}
+	if len(partitions) != 1 || partitions[0] != want {
+		t.Errorf("partitions = %+v, want %+v", partitions, want)
+	}
+
+	partitions, err = dbc.ExportParquet(context.Background(), dir, Filter{})
+	if err != nil || len(partitions) != 3 || partitions[0].Rows != 1 || partitions[1] != want || partitions[2].Rows != 1 {
+		t.Errorf("partitions of all the readings = %+v, %v, want April, May and June", partitions, err)
+	}
+
+	if _, err := dbc.ExportParquet(context.Background(), dir, Filter{StationIDs: []string{"S1"}}); errors.Is(err, ErrInvalidParameter) == false {
+		t.Errorf("export of the station = %v, want ErrInvalidParameter", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := dbc.ExportParquet(ctx, dir, Filter{}); errors.Is(err, context.Canceled) == false {
+		t.Errorf("export after the cancel = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,122 @@
+package SGAirTemp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//replayResponse - save the stored response of the weather Metric, the air quality, the weather forecast or the WBGT API, recognized from its query.
+func replayResponse(tx *sql.Tx, rw *responseWriter, stored StoredResponse) (int, error) {
+	if strings.HasPrefix(stored.Query, "v2/"+wbgtQuery) {
+		response := WBGTResponse{}
+		if err := json.Unmarshal(stored.Body, &response); err != nil {
+			return 0, err
+		}
+		return saveWBGTResponse(tx, rw, response)
+	}
+
+	endpoint := strings.TrimPrefix(strings.SplitN(stored.Query, "?", 2)[0], "environment/")
+	if _, err := AirQualitySourceByName(endpoint); err == nil {
+		response := RegionResponse{}
+		if err := json.Unmarshal(stored.Body, &response); err != nil {
+			return 0, err
+		}
+		return saveRegionResponse(tx, response)
+	}
+	if source, err := ForecastSourceByName(endpoint); err == nil {
+		response := ForecastResponse{}
+		if err := json.Unmarshal(stored.Body, &response); err != nil {
+			return 0, err
+		}
+		return saveForecastResponse(tx, source, response)
+	}
+
+	metric, err := metricFromQuery(stored.Query)
+	if err != nil {
+		return 0, err
+	}
+	response := WeatherResponse{}
+	if err := json.Unmarshal(stored.Body, &response); err != nil {
+		return 0, err
+	}
+	return rw.Save(metric, response)
+}
+
+//ReplayOptions struct - the replay of the ResponseStore to the Database.
+type ReplayOptions struct {
+	//Rebuild - delete all the readings (and region readings, forecasts, heat stress) first, so the readings are only the ones from the ResponseStore.
+	Rebuild bool
+	//Progress - called after every response file, can be nil.
+	Progress func(stored StoredResponse, totalSaved int)
+}
+
+//ReplaySummary struct - the result of the replay.
+type ReplaySummary struct {
+	Files         int
+	EmptyFiles    int
+	TotalReadings int
+}
+
+//Replay - save the readings of every response on the ResponseStore without calling the API, ie: after the schema is changed.
+//All the responses are saved in one transaction, so the failed replay (including the Rebuild) leaves the Database as it was.
+func (dbc *DB) Replay(store *ResponseStore, opts ReplayOptions) (summary ReplaySummary, err error) {
+	files, err := store.Files()
+	if err != nil {
+		return summary, err
+	}
+
+	tx, err := dbc.Begin()
+	if err != nil {
+		return summary, dbError("begin replay", err)
+	}
+	defer tx.Rollback()
+
+	if opts.Rebuild == true {
+		if _, err := tx.Exec("DELETE FROM readings"); err != nil {
+			return summary, dbError("delete readings", err)
+		}
+		if _, err := tx.Exec("DELETE FROM region_readings"); err != nil {
+			return summary, dbError("delete region readings", err)
+		}
+		if _, err := tx.Exec("DELETE FROM forecasts"); err != nil {
+			return summary, dbError("delete forecasts", err)
+		}
+		if _, err := tx.Exec("DELETE FROM heat_stress"); err != nil {
+			return summary, dbError("delete heat stress", err)
+		}
+	}
+
+	rw, err := newResponseWriter(tx)
+	if err != nil {
+		return summary, err
+	}
+	defer rw.Close()
+
+	for _, strPath := range files {
+		stored, err := readStoredResponse(strPath)
+		if err != nil {
+			return summary, err
+		}
+		summary.Files++
+		if isEmptyBody(stored.Body) {
+			summary.EmptyFiles++
+			continue
+		}
+
+		totalSaved, err := replayResponse(tx, rw, stored)
+		if err != nil {
+			return summary, fmt.Errorf("replay %v (%v): %w", strPath, stored.Query, err)
+		}
+		summary.TotalReadings += totalSaved
+		if opts.Progress != nil {
+			opts.Progress(stored, totalSaved)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ReplaySummary{}, dbError("commit replay", err)
+	}
+	return summary, nil
+}
@@ -0,0 +1,139 @@
+package SGAirTemp
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//ResponseStore struct - the raw API responses on disk, content-addressed by the query.
+//Every response is saved on Dir/KK/KEY.json.gz where KEY is the sha256 of the query (ie: environment/air-temperature?date=2020-06-01),
+//so the same query always replaces the same file.
+type ResponseStore struct {
+	Dir string
+}
+
+//StoredResponse struct - the file content of the ResponseStore.
+type StoredResponse struct {
+	Query     string          `json:"query"`
+	FetchedAt string          `json:"fetched_at"`
+	Body      json.RawMessage `json:"body"`
+}
+
+//NewResponseStore - create the ResponseStore on the directory, it is created on the first Put.
+func NewResponseStore(dir string) *ResponseStore {
+	return &ResponseStore{Dir: dir}
+}
+
+//responseKey - the sha256 of the query.
+func responseKey(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+//path - the file of the query.
+func (rs *ResponseStore) path(query string) string {
+	key := responseKey(query)
+	return filepath.Join(rs.Dir, key[:2], key+".json.gz")
+}
+
+//Put - save the raw response body of the query, replacing the previous one.
+//It is written to a temporary file first, so the interrupted write never leaves a broken file.
+func (rs *ResponseStore) Put(query string, body []byte) error {
+	if json.Valid(body) == false {
+		return fmt.Errorf("response of '%v' is not a valid JSON", query)
+	}
+	content, err := json.Marshal(StoredResponse{Query: query, FetchedAt: time.Now().UTC().Format(time.RFC3339), Body: body})
+	if err != nil {
+		return err
+	}
+
+	strPath := rs.path(query)
+	if err := os.MkdirAll(filepath.Dir(strPath), 0755); err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(strPath), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	zw := gzip.NewWriter(tmpFile)
+	if _, err := zw.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), strPath)
+}
+
+//Get - the saved response of the query, false if it is not saved yet.
+func (rs *ResponseStore) Get(query string) (StoredResponse, bool, error) {
+	stored, err := readStoredResponse(rs.path(query))
+	if errors.Is(err, os.ErrNotExist) {
+		return stored, false, nil
+	}
+	return stored, err == nil, err
+}
+
+//Files - all the response files, ordered by the time they were written (oldest first).
+func (rs *ResponseStore) Files() ([]string, error) {
+	type storedFile struct {
+		path    string
+		modTime time.Time
+	}
+	files := []storedFile{}
+	err := filepath.Walk(rs.Dir, func(strPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() == false && strings.HasSuffix(info.Name(), ".json.gz") && strings.HasPrefix(info.Name(), ".") == false {
+			files = append(files, storedFile{path: strPath, modTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	paths := []string{}
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
+
+//readStoredResponse - read the gzip file of the ResponseStore.
+func readStoredResponse(strPath string) (stored StoredResponse, err error) {
+	f, err := os.Open(strPath)
+	if err != nil {
+		return stored, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return stored, fmt.Errorf("read %v: %w", strPath, err)
+	}
+	defer zr.Close()
+	if err := json.NewDecoder(zr).Decode(&stored); err != nil {
+		return stored, fmt.Errorf("read %v: %w", strPath, err)
+	}
+	return stored, nil
+}
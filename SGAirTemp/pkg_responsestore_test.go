@@ -0,0 +1,164 @@
+package SGAirTemp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestResponseStorePutGet(t *testing.T) {
+	store := NewResponseStore(t.TempDir())
+	query := "environment/air-temperature?date=2024-05-01"
+	if _, ok, err := store.Get(query); ok == true || err != nil {
+		t.Fatalf("Get before the Put = %v, %v, want not saved", ok, err)
+	}
+
+	if err := store.Put(query, []byte(`{"items":[1]}`)); err != nil {
+		t.Fatal(err)
+	}
+	//The same query replaces the same file.
+	if err := store.Put(query, []byte(`{"items":[2]}`)); err != nil {
+		t.Fatal(err)
+	}
+	stored, ok, err := store.Get(query)
+	if err != nil || ok == false || stored.Query != query || string(stored.Body) != `{"items":[2]}` {
+		t.Errorf("Get = %+v, %v, %v, want the last body of %v", stored, ok, err, query)
+	}
+	if err := store.Put("environment/air-temperature?date=2024-05-02", []byte("not JSON")); err == nil {
+		t.Error("Put of the invalid JSON saved, want the error")
+	}
+
+	files, err := store.Files()
+	if err != nil || len(files) != 1 || files[0] != store.path(query) {
+		t.Errorf("Files = %v, %v, want only %v", files, err, store.path(query))
+	}
+}
+
+func TestResponseStoreFilesOrder(t *testing.T) {
+	store := NewResponseStore(t.TempDir())
+	queries := []string{"environment/rainfall?date=2024-05-03", "environment/rainfall?date=2024-05-01", "environment/rainfall?date=2024-05-02"}
+	start := time.Now().Add(-time.Hour)
+	for i, query := range queries {
+		if err := store.Put(query, []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+		modTime := start.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(store.path(query), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := store.Files()
+	if err != nil || len(files) != len(queries) {
+		t.Fatalf("Files = %v, %v, want %v files", files, err, len(queries))
+	}
+	for i, query := range queries {
+		if files[i] != store.path(query) {
+			t.Errorf("file %v = %v, want %v written in that order", i, files[i], query)
+		}
+	}
+}
+
+func TestAPIClientStore(t *testing.T) {
+	client, handler := newMockClient(t, MockOptions{Stations: 2, EmptyDates: []string{"2024-05-02"}})
+	storeDir := t.TempDir()
+	client.Store = NewResponseStore(storeDir)
+
+	//The past date is called once, then read from the Store.
+	for i := 0; i < 2; i++ {
+		response, err := client.GetReadings(context.Background(), MetricAirTemperature, "2024-05-01", "")
+		if err != nil || len(response.Items) != 24*60 {
+			t.Fatalf("GetReadings = %v items, %v, want the FULL day", len(response.Items), err)
+		}
+	}
+	if handler.Requests() != 1 || storedFiles(t, storeDir) != 1 {
+		t.Errorf("%v requests served and %v files saved, want 1 and 1", handler.Requests(), storedFiles(t, storeDir))
+	}
+
+	//Refresh calls the API again.
+	client.Refresh = true
+	if _, err := client.GetReadings(context.Background(), MetricAirTemperature, "2024-05-01", ""); err != nil {
+		t.Fatal(err)
+	}
+	client.Refresh = false
+	if handler.Requests() != 2 {
+		t.Errorf("%v requests served with Refresh, want 2", handler.Requests())
+	}
+
+	//The empty response is saved, but always called again.
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetReadings(context.Background(), MetricAirTemperature, "2024-05-02", ""); errors.Is(err, ErrAPIEmptyBody) == false {
+			t.Fatalf("GetReadings of the empty day = %v, want ErrAPIEmptyBody", err)
+		}
+	}
+	if handler.Requests() != 4 || storedFiles(t, storeDir) != 2 {
+		t.Errorf("%v requests served and %v files saved, want 4 and 2", handler.Requests(), storedFiles(t, storeDir))
+	}
+
+	//Today is not complete yet, it is never saved.
+	today := time.Now().In(SGTLocation).Format(strStandardFormat)
+	if _, err := client.GetReadings(context.Background(), MetricAirTemperature, today, ""); err != nil && errors.Is(err, ErrAPIEmptyBody) == false {
+		t.Fatal(err)
+	}
+	if total := storedFiles(t, storeDir); total != 2 {
+		t.Errorf("%v files saved after today is called, want today not saved", total)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	client, _ := newMockClient(t, MockOptions{Stations: 2, EmptyDates: []string{"2024-05-03"}})
+	store := NewResponseStore(t.TempDir())
+	client.Store = store
+	for _, date := range []string{"2024-05-01", "2024-05-02", "2024-05-03"} {
+		if _, err := client.GetReadings(context.Background(), MetricAirTemperature, date, ""); err != nil && errors.Is(err, ErrAPIEmptyBody) == false {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.GetReadings(context.Background(), MetricRainfall, "2024-05-01", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	dbc := newTestDB(t)
+	//The reading not on the Store is kept, unless it is a Rebuild.
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-04-30 12:00", 30)
+	progress := 0
+	summary, err := dbc.Replay(store, ReplayOptions{Progress: func(stored StoredResponse, totalSaved int) { progress++ }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantReadings := 2*2*24*60 + 2*24*60/int(mockInterval(MetricRainfall)/time.Minute)
+	want := ReplaySummary{Files: 4, EmptyFiles: 1, TotalReadings: wantReadings}
+	if summary != want || progress != 3 {
+		t.Errorf("summary = %+v after %v progress, want %+v after 3", summary, progress, want)
+	}
+	if total, err := dbc.CountReadings(Filter{}); err != nil || total != 2*2*24*60+1 {
+		t.Errorf("air temperature readings = %v, %v, want %v", total, err, 2*2*24*60+1)
+	}
+
+	summary, err = dbc.Replay(store, ReplayOptions{Rebuild: true})
+	if err != nil || summary != want {
+		t.Fatalf("summary of the Rebuild = %+v, %v, want %+v", summary, err, want)
+	}
+	if total, err := dbc.CountReadings(Filter{}); err != nil || total != 2*2*24*60 {
+		t.Errorf("air temperature readings after the Rebuild = %v, %v, want %v", total, err, 2*2*24*60)
+	}
+}
+
+func TestReplayFailure(t *testing.T) {
+	store := NewResponseStore(t.TempDir())
+	if err := store.Put("environment/air-temperature?date=2024-05-01", []byte(`{"items":"not the items of the WeatherResponse, but long enough to not be taken as the empty body of the API, which is 110 bytes or less"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-04-30 12:00", 30)
+	if _, err := dbc.Replay(store, ReplayOptions{Rebuild: true}); err == nil {
+		t.Fatal("Replay of the invalid response succeeded, want the error")
+	}
+	//The failed Rebuild is rolled back.
+	if total, err := dbc.CountReadings(Filter{}); err != nil || total != 1 {
+		t.Errorf("readings after the failed replay = %v, %v, want the 1 reading kept", total, err)
+	}
+}
@@ -0,0 +1,154 @@
+package SGAirTemp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+//The default of the FetchScheduler, kept low to be polite to the data.gov.sg API.
+const (
+	DefaultFetchWorkers      = 4
+	DefaultRequestsPerSecond = 5.0
+)
+
+//FetchRequest struct - one API call of the Metric, Time (HH:mm) empty for the FULL day of the Date (YYYY-MM-DD).
+type FetchRequest struct {
+	Metric Metric
+	Date   string
+	Time   string
+}
+
+//FetchResult struct - the result of one FetchRequest, TotalSaved is the total readings saved to the Database.
+type FetchResult struct {
+	Request    FetchRequest
+	TotalSaved int
+	Err        error
+}
+
+//FetchScheduler struct - call the API with Workers concurrent calls, not more than RequestsPerSecond (0 for no limit).
+//The responses are saved by a single writer, so the Sqlite database never has concurrent writes.
+type FetchScheduler struct {
+	Workers           int
+	RequestsPerSecond float64
+	//fetcher - calls the API instead of the API client of the DB, ie: the fake of the tests.
+	fetcher readingsFetcher
+}
+
+//readingsFetcher - the API call of the FetchScheduler, implemented by the APIClient.
+type readingsFetcher interface {
+	GetReadings(ctx context.Context, metric Metric, ValDate, ValTime string) (WeatherResponse, error)
+}
+
+//NewFetchScheduler - create the FetchScheduler.
+func NewFetchScheduler(workers int, requestsPerSecond float64) *FetchScheduler {
+	return &FetchScheduler{Workers: workers, RequestsPerSecond: requestsPerSecond}
+}
+
+//fetchedResponse - the API response waiting to be saved by the writer.
+type fetchedResponse struct {
+	request  FetchRequest
+	response WeatherResponse
+	err      error
+}
+
+//Run - fetch every request with the DB API client and save the responses to the Database.
+//onResult is called by the writer (never concurrently) after each response is saved or failed, in the order of completion.
+//The run stops on the first database error, the first error returned by onResult or when the ctx is done, and that error is returned.
+//The request failed because the ctx is done is not given to onResult, so it can be fetched again later.
+func (fs *FetchScheduler) Run(ctx context.Context, dbc *DB, requests []FetchRequest, onResult func(result FetchResult) error) error {
+	workers := fs.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var client readingsFetcher = dbc.apiClient()
+	if fs.fetcher != nil {
+		client = fs.fetcher
+	}
+	queue := make(chan FetchRequest)
+	fetched := make(chan fetchedResponse)
+	stop := make(chan struct{})
+
+	//Rate limiter, every API call takes one tick.
+	var limiter <-chan time.Time
+	if fs.RequestsPerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / fs.RequestsPerSecond))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	go func() {
+		defer close(queue)
+		for _, request := range requests {
+			select {
+			case queue <- request:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for request := range queue {
+				if limiter != nil {
+					select {
+					case <-limiter:
+					case <-stop:
+						return
+					case <-ctx.Done():
+						return
+					}
+				}
+				response, err := client.GetReadings(ctx, request.Metric, request.Date, request.Time)
+				select {
+				case fetched <- fetchedResponse{request: request, response: response, err: err}:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(fetched)
+	}()
+
+	//The single writer.
+	var runErr error
+	for f := range fetched {
+		//Stopped, only drain the workers.
+		if runErr != nil {
+			continue
+		}
+		if f.err != nil && ctx.Err() != nil {
+			runErr = ctx.Err()
+			close(stop)
+			continue
+		}
+		result := FetchResult{Request: f.request, Err: f.err}
+		if f.err == nil {
+			result.TotalSaved, result.Err = dbc.SaveWeatherResponse(f.request.Metric, f.response)
+		}
+
+		if errors.Is(result.Err, ErrDB) {
+			runErr = result.Err
+		} else if onResult != nil {
+			runErr = onResult(result)
+		}
+		if runErr != nil {
+			close(stop)
+		}
+	}
+	if runErr == nil {
+		runErr = ctx.Err()
+	}
+	return runErr
+}
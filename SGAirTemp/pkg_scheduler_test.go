@@ -0,0 +1,220 @@
+package SGAirTemp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//fakeFetcher - the readingsFetcher of the tests, one station with one reading at 00:00 SGT of the requested date.
+type fakeFetcher struct {
+	//delay - the time every call takes, to have the workers overlap.
+	delay time.Duration
+	//fail - the dates returning the empty body APIError.
+	fail map[string]bool
+	//block - the dates waiting until the ctx is done.
+	block map[string]bool
+
+	mu        sync.Mutex
+	calls     int
+	active    int
+	maxActive int
+}
+
+func (f *fakeFetcher) GetReadings(ctx context.Context, metric Metric, ValDate, ValTime string) (WeatherResponse, error) {
+	f.mu.Lock()
+	f.calls++
+	f.active++
+	if f.active > f.maxActive {
+		f.maxActive = f.active
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.active--
+		f.mu.Unlock()
+	}()
+
+	if f.block[ValDate] {
+		<-ctx.Done()
+		return WeatherResponse{}, &APIError{URL: ValDate, Kind: ErrAPIUnavailable, Err: ctx.Err()}
+	}
+	time.Sleep(f.delay)
+	if f.fail[ValDate] {
+		return WeatherResponse{}, &APIError{URL: ValDate, Kind: ErrAPIEmptyBody}
+	}
+	return WeatherResponse{
+		Metadata: Metadata{Station: []Station{{StationID: "S1", StationName: "Station S1", Location: Location{Latitude: 1.35, Longitude: 103.8}}}},
+		Items:    []WeatherData{{Timestamp: ValDate + "T00:00:00+08:00", Readings: []Reading{{StationID: "S1", Value: 27}}}},
+	}, nil
+}
+
+//stats - the total calls and the most concurrent calls.
+func (f *fakeFetcher) stats() (calls, maxActive int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls, f.maxActive
+}
+
+//testRequests - the FULL day requests of the air temperature from 2024-05-01.
+func testRequests(total int) []FetchRequest {
+	requests := make([]FetchRequest, total)
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, SGTLocation)
+	for i := range requests {
+		requests[i] = FetchRequest{Metric: MetricAirTemperature, Date: start.AddDate(0, 0, i).Format(strStandardFormat)}
+	}
+	return requests
+}
+
+func TestFetchSchedulerRun(t *testing.T) {
+	dbc := newTestDB(t)
+	fetcher := &fakeFetcher{delay: 5 * time.Millisecond, fail: map[string]bool{"2024-05-03": true}}
+	scheduler := &FetchScheduler{Workers: 3, fetcher: fetcher}
+	requests := testRequests(12)
+
+	var inResult int32
+	results := map[string]FetchResult{}
+	err := scheduler.Run(context.Background(), dbc, requests, func(result FetchResult) error {
+		//The single writer never calls onResult concurrently.
+		if atomic.AddInt32(&inResult, 1) != 1 {
+			t.Error("onResult called concurrently")
+		}
+		defer atomic.AddInt32(&inResult, -1)
+		results[result.Request.Date] = result
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls, maxActive := fetcher.stats()
+	if calls != len(requests) || len(results) != len(requests) {
+		t.Errorf("%v calls and %v results, want %v", calls, len(results), len(requests))
+	}
+	if maxActive > scheduler.Workers {
+		t.Errorf("%v concurrent calls, want at most %v workers", maxActive, scheduler.Workers)
+	}
+	//The failed call is given to onResult and the run goes on.
+	if result := results["2024-05-03"]; errors.Is(result.Err, ErrAPIEmptyBody) == false || result.TotalSaved != 0 {
+		t.Errorf("result of the failed call = %+v, want ErrAPIEmptyBody", result)
+	}
+	if result := results["2024-05-04"]; result.Err != nil || result.TotalSaved != 1 {
+		t.Errorf("result of 2024-05-04 = %+v, want 1 reading saved", result)
+	}
+	if total, err := dbc.CountReadings(Filter{}); err != nil || total != len(requests)-1 {
+		t.Errorf("readings saved = %v, %v, want %v", total, err, len(requests)-1)
+	}
+}
+
+func TestFetchSchedulerRateLimit(t *testing.T) {
+	dbc := newTestDB(t)
+	fetcher := &fakeFetcher{}
+	scheduler := &FetchScheduler{Workers: 4, RequestsPerSecond: 20, fetcher: fetcher}
+	requests := testRequests(5)
+
+	start := time.Now()
+	if err := scheduler.Run(context.Background(), dbc, requests, nil); err != nil {
+		t.Fatal(err)
+	}
+	//Every call waits for its tick of 50ms, whatever the total workers.
+	if elapsed, want := time.Since(start), time.Duration(len(requests))*50*time.Millisecond; elapsed < want-10*time.Millisecond {
+		t.Errorf("%v requests at 20 per second took %v, want at least %v", len(requests), elapsed, want)
+	}
+	if calls, _ := fetcher.stats(); calls != len(requests) {
+		t.Errorf("%v calls, want %v", calls, len(requests))
+	}
+}
+
+func TestFetchSchedulerStop(t *testing.T) {
+	errStop := errors.New("stop")
+	tests := []struct {
+		name string
+		//prepare - break the database before the run.
+		prepare  func(t *testing.T, dbc *DB)
+		onResult func(result FetchResult) error
+		wantErr  error
+		results  int
+	}{
+		{
+			name: "database error",
+			prepare: func(t *testing.T, dbc *DB) {
+				if _, err := dbc.Exec("DROP TABLE readings"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrDB,
+			results: 0,
+		},
+		{
+			name:     "onResult error",
+			onResult: func(result FetchResult) error { return fmt.Errorf("%w on %v", errStop, result.Request.Date) },
+			wantErr:  errStop,
+			results:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc := newTestDB(t)
+			if tt.prepare != nil {
+				tt.prepare(t, dbc)
+			}
+			fetcher := &fakeFetcher{delay: time.Millisecond}
+			scheduler := &FetchScheduler{Workers: 4, fetcher: fetcher}
+			requests := testRequests(200)
+
+			results := 0
+			err := scheduler.Run(context.Background(), dbc, requests, func(result FetchResult) error {
+				results++
+				if tt.onResult != nil {
+					return tt.onResult(result)
+				}
+				return nil
+			})
+			if errors.Is(err, tt.wantErr) == false {
+				t.Fatalf("Run error = %v, want %v", err, tt.wantErr)
+			}
+			if results != tt.results {
+				t.Errorf("onResult called %v times, want %v", results, tt.results)
+			}
+			//The queued requests are not fetched after the stop, only the workers already calling are drained.
+			if calls, _ := fetcher.stats(); calls >= len(requests)/4 {
+				t.Errorf("%v of %v requests fetched after the stop", calls, len(requests))
+			}
+		})
+	}
+}
+
+func TestFetchSchedulerCancel(t *testing.T) {
+	dbc := newTestDB(t)
+	requests := testRequests(40)
+	//Every request after the first waits for the ctx.
+	block := map[string]bool{}
+	for _, request := range requests[1:] {
+		block[request.Date] = true
+	}
+	fetcher := &fakeFetcher{block: block}
+	scheduler := &FetchScheduler{Workers: 4, fetcher: fetcher}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var results []FetchResult
+	err := scheduler.Run(ctx, dbc, requests, func(result FetchResult) error {
+		results = append(results, result)
+		cancel()
+		return nil
+	})
+	if errors.Is(err, context.Canceled) == false {
+		t.Fatalf("Run error = %v, want context.Canceled", err)
+	}
+	//The requests failed because of the cancel are not given to onResult.
+	if len(results) != 1 || results[0].Request.Date != requests[0].Date || results[0].Err != nil {
+		t.Errorf("results = %+v, want only the %v saved", results, requests[0].Date)
+	}
+	if calls, _ := fetcher.stats(); calls >= len(requests)/2 {
+		t.Errorf("%v of %v requests fetched after the cancel", calls, len(requests))
+	}
+}
@@ -0,0 +1,376 @@
+package SGAirTemp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//The page size of the Server list endpoints, when the limit parameter is not given and its maximum.
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+//openAPIDocument - the OpenAPI document of the Server, served on /openapi.json.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+//Server struct - the read only REST JSON API of the saved stations, readings and statistics, the API of data.gov.sg is never called.
+//Every response has an ETag (the sha256 of the body), the request with the same ETag on If-None-Match is answered with 304.
+type Server struct {
+	dbc *DB
+	mux *http.ServeMux
+}
+
+//NewServer - create the Server of the DB, the Metric of the DB is used when the metric parameter is not given.
+func NewServer(dbc *DB) *Server {
+	s := &Server{dbc: dbc, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/stations", s.serveStations)
+	s.mux.HandleFunc("/readings", s.serveReadings)
+	s.mux.HandleFunc("/stats", s.serveStats)
+	s.mux.HandleFunc("/stations.geojson", s.serveGeoJSON)
+	s.mux.HandleFunc("/openapi.json", s.serveOpenAPI)
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONError(w, http.StatusNotFound, "not found: "+r.URL.Path)
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed: "+r.Method)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+//stationJSON struct - the Station of the /stations response.
+type stationJSON struct {
+	StationID string  `json:"station_id"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+//readingJSON struct - the StationReading of the /readings response.
+type readingJSON struct {
+	StationID   string    `json:"station_id"`
+	StationName string    `json:"station_name"`
+	Timestamp   time.Time `json:"timestamp"`
+	Value       float64   `json:"value"`
+}
+
+//occurrenceJSON struct - the Occurrence of the /stats response.
+type occurrenceJSON struct {
+	StationID   string    `json:"station_id"`
+	StationName string    `json:"station_name"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+//statisticsJSON struct - the /stats response.
+type statisticsJSON struct {
+	Metric         string           `json:"metric"`
+	Unit           string           `json:"unit"`
+	Granularity    string           `json:"granularity"`
+	DateFrom       string           `json:"date_from,omitempty"`
+	DateTo         string           `json:"date_to,omitempty"`
+	StationID      string           `json:"station_id,omitempty"`
+	Count          int              `json:"count"`
+	Mean           float64          `json:"mean"`
+	Median         float64          `json:"median"`
+	Min            float64          `json:"min"`
+	MinOccurrences []occurrenceJSON `json:"min_occurrences"`
+	Max            float64          `json:"max"`
+	MaxOccurrences []occurrenceJSON `json:"max_occurrences"`
+}
+
+//listJSON struct - the page of the list endpoints, Next is the path and query of the next page, empty on the last page.
+type listJSON struct {
+	Metric string      `json:"metric,omitempty"`
+	Unit   string      `json:"unit,omitempty"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+	Next   string      `json:"next,omitempty"`
+	Items  interface{} `json:"items"`
+}
+
+func (s *Server) serveStations(w http.ResponseWriter, r *http.Request) {
+	page, err := pageParams(r)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	stations, total, err := s.dbc.Stations(page)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	items := make([]stationJSON, 0, len(stations))
+	for _, st := range stations {
+		items = append(items, stationJSON{StationID: st.StationID, Name: st.StationName, Latitude: st.Location.Latitude, Longitude: st.Location.Longitude})
+	}
+	writeJSON(w, r, listJSON{Total: total, Limit: page.Limit, Offset: page.Offset, Next: nextPage(r, page, total), Items: items})
+}
+
+func (s *Server) serveReadings(w http.ResponseWriter, r *http.Request) {
+	page, err := pageParams(r)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	metric, err := s.metricParam(r)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	q := r.URL.Query()
+	filter := Filter{DateFrom: strings.TrimSpace(q.Get("from")), DateTo: strings.TrimSpace(q.Get("to")), StationIDs: stationParams(r), Metric: metric.Name}
+	total, err := s.dbc.CountReadings(filter)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	readings, err := s.dbc.Readings(filter, page)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	items := make([]readingJSON, 0, len(readings))
+	for _, reading := range readings {
+		items = append(items, readingJSON(reading))
+	}
+	writeJSON(w, r, listJSON{Metric: metric.Name, Unit: metric.Unit, Total: total, Limit: page.Limit, Offset: page.Offset, Next: nextPage(r, page, total), Items: items})
+}
+
+func (s *Server) serveStats(w http.ResponseWriter, r *http.Request) {
+	metric, err := s.metricParam(r)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	stationIDs := stationParams(r)
+	if len(stationIDs) > 1 {
+		writeServerError(w, &InputError{Value: strings.Join(stationIDs, ","), Message: "please provide one station, or none for all stations", Err: ErrInvalidParameter})
+		return
+	}
+	StationID := strings.Join(stationIDs, "")
+
+	q := r.URL.Query()
+	granularity := q.Get("granularity")
+	var query StatisticQuery
+	switch granularity {
+	case "day", "fullday":
+		dateVal := strings.TrimSpace(q.Get("date"))
+		if _, err := sgtDayStart(dateVal); err != nil {
+			writeServerError(w, err)
+			return
+		}
+		if granularity == "day" {
+			query = NewDayQuery(dateVal, StationID)
+		} else {
+			query = NewFullDayQuery(dateVal, StationID)
+		}
+	case "month":
+		if query, err = NewMonthQuery(q.Get("month"), StationID); err != nil {
+			writeServerError(w, err)
+			return
+		}
+	case "all":
+		query = NewAllDataQuery(StationID)
+	default:
+		writeServerError(w, &InputError{Value: granularity, Message: "please choose the granularity day, fullday, month or all", Err: ErrInvalidParameter})
+		return
+	}
+	query.Metric = metric.Name
+
+	stats, err := s.dbc.GetStatistics(query)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, r, statisticsJSON{
+		Metric:         metric.Name,
+		Unit:           metric.Unit,
+		Granularity:    granularity,
+		DateFrom:       query.DateFrom,
+		DateTo:         query.DateTo,
+		StationID:      StationID,
+		Count:          stats.Count,
+		Mean:           stats.Mean,
+		Median:         stats.Median,
+		Min:            stats.Min,
+		MinOccurrences: occurrencesJSON(stats.MinOccurrences),
+		Max:            stats.Max,
+		MaxOccurrences: occurrencesJSON(stats.MaxOccurrences),
+	})
+}
+
+func (s *Server) serveGeoJSON(w http.ResponseWriter, r *http.Request) {
+	metric, err := s.metricParam(r)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	dateVal := strings.TrimSpace(r.URL.Query().Get("date"))
+	if len(dateVal) > 0 {
+		if _, err := sgtDayStart(dateVal); err != nil {
+			writeServerError(w, err)
+			return
+		}
+	}
+	collection, err := s.dbc.StationsGeoJSON(GeoJSONQuery{Date: dateVal, StationIDs: stationParams(r), Metric: metric.Name})
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(collection); err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeBodyType(w, r, "application/geo+json", body.Bytes())
+}
+
+func (s *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeBody(w, r, openAPIDocument)
+}
+
+//metricParam - the Metric (including MetricWBGT) of the metric parameter, the Metric of the DB if it is not given.
+func (s *Server) metricParam(r *http.Request) (Metric, error) {
+	name := strings.TrimSpace(r.URL.Query().Get("metric"))
+	if len(name) == 0 {
+		return s.dbc.metric(), nil
+	}
+	if name == MetricWBGT.Name {
+		return MetricWBGT, nil
+	}
+	return MetricByName(name)
+}
+
+//stationParams - the station IDs of the station parameters, repeated or comma separated.
+func stationParams(r *http.Request) []string {
+	stationIDs := []string{}
+	for _, value := range r.URL.Query()["station"] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); len(id) > 0 {
+				stationIDs = append(stationIDs, id)
+			}
+		}
+	}
+	return stationIDs
+}
+
+//pageParams - the Page of the limit (default DefaultPageLimit, max MaxPageLimit) and offset parameters.
+func pageParams(r *http.Request) (Page, error) {
+	page := Page{Limit: DefaultPageLimit}
+	q := r.URL.Query()
+	if value := q.Get("limit"); len(value) > 0 {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			return page, &InputError{Value: value, Message: "the limit must be from 1 to " + strconv.Itoa(MaxPageLimit), Err: ErrInvalidParameter}
+		}
+		page.Limit = limit
+	}
+	if value := q.Get("offset"); len(value) > 0 {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return page, &InputError{Value: value, Message: "the offset must be 0 or more", Err: ErrInvalidParameter}
+		}
+		page.Offset = offset
+	}
+	return page, nil
+}
+
+//nextPage - the path and query of the page after the page, empty if it is the last page.
+func nextPage(r *http.Request, page Page, total int) string {
+	if page.Offset+page.Limit >= total {
+		return ""
+	}
+	q := r.URL.Query()
+	q.Set("limit", strconv.Itoa(page.Limit))
+	q.Set("offset", strconv.Itoa(page.Offset+page.Limit))
+	return r.URL.Path + "?" + q.Encode()
+}
+
+//occurrencesJSON - the Occurrences of the /stats response, never null.
+func occurrencesJSON(occurrences []Occurrence) []occurrenceJSON {
+	items := make([]occurrenceJSON, 0, len(occurrences))
+	for _, occ := range occurrences {
+		items = append(items, occurrenceJSON(occ))
+	}
+	return items
+}
+
+//writeJSON - write the value as the JSON body with its ETag, without escaping the & of the next page.
+func writeJSON(w http.ResponseWriter, r *http.Request, value interface{}) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeBody(w, r, body.Bytes())
+}
+
+//writeBody - write the JSON body with its ETag, or 304 without the body if the client has the same ETag.
+func writeBody(w http.ResponseWriter, r *http.Request, body []byte) {
+	writeBodyType(w, r, "application/json", body)
+}
+
+//writeBodyType - writeBody with the Content-Type, ie: application/geo+json.
+func writeBodyType(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
+
+//etagMatch - the If-None-Match header has the ETag (or *), the weak ETag is compared by its value.
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+//writeServerError - the InputError is answered with 400, the other errors with 500.
+func writeServerError(w http.ResponseWriter, err error) {
+	var inputErr *InputError
+	if errors.As(err, &inputErr) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, err.Error())
+}
+
+//writeJSONError - the error body: {"error": message}.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,232 @@
+package SGAirTemp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//serveTest - the response of the Server to the GET request of the target, with the If-None-Match header if it is not empty.
+func serveTest(t *testing.T, server *Server, target, ifNoneMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	if len(ifNoneMatch) > 0 {
+		request.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestServerPageParams(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 10:00", 30)
+	server := NewServer(dbc)
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantError  string
+	}{
+		{"limit=0", http.StatusBadRequest, "the limit must be from 1 to 1000"},
+		{"limit=1001", http.StatusBadRequest, "the limit must be from 1 to 1000"},
+		{"limit=ten", http.StatusBadRequest, "the limit must be from 1 to 1000"},
+		{"offset=-1", http.StatusBadRequest, "the offset must be 0 or more"},
+		{"offset=first", http.StatusBadRequest, "the offset must be 0 or more"},
+		{"limit=1000&offset=0", http.StatusOK, ""},
+		{"", http.StatusOK, ""},
+	}
+	for _, path := range []string{"/stations", "/readings"} {
+		for _, tt := range tests {
+			recorder := serveTest(t, server, path+"?"+tt.query, "")
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("%v?%v: status %v, want %v", path, tt.query, recorder.Code, tt.wantStatus)
+				continue
+			}
+			if tt.wantStatus == http.StatusOK {
+				continue
+			}
+			var body map[string]string
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || strings.Contains(body["error"], tt.wantError) == false {
+				t.Errorf("%v?%v: body %v, want the error %v", path, tt.query, recorder.Body.String(), tt.wantError)
+			}
+		}
+	}
+
+	//The default limit.
+	var page listJSON
+	if err := json.Unmarshal(serveTest(t, server, "/stations", "").Body.Bytes(), &page); err != nil || page.Limit != DefaultPageLimit || page.Offset != 0 || page.Total != 1 {
+		t.Errorf("page = %+v, %v, want the limit %v and 1 station", page, err, DefaultPageLimit)
+	}
+}
+
+func TestServerReadingsNextPage(t *testing.T) {
+	dbc := newTestDB(t)
+	//Every other minute has 0% humidity, the dew point can't be computed for it.
+	for m := 0; m < 10; m++ {
+		dateTime := fmt.Sprintf("2024-05-01 10:%02d", m)
+		saveTestReading(t, dbc, MetricAirTemperature, "S1", dateTime, 30)
+		saveTestReading(t, dbc, MetricRelativeHumidity, "S1", dateTime, float64(m%2)*80)
+	}
+	server := NewServer(dbc)
+
+	tests := []struct {
+		metric    Metric
+		total     int
+		pageSizes []int
+	}{
+		{MetricAirTemperature, 10, []int{4, 4, 2}},
+		{MetricDewPoint, 5, []int{4, 1}},
+		{MetricHumidex, 5, []int{4, 1}},
+		{MetricHeatIndex, 10, []int{4, 4, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric.Name, func(t *testing.T) {
+			target := "/readings?metric=" + tt.metric.Name + "&from=2024-05-01&to=2024-05-01&limit=4"
+			seen := map[string]bool{}
+			for i, wantSize := range tt.pageSizes {
+				recorder := serveTest(t, server, target, "")
+				if recorder.Code != http.StatusOK {
+					t.Fatalf("page %v: status %v, want 200: %v", i, recorder.Code, recorder.Body.String())
+				}
+				//The & of the next page is not escaped.
+				if strings.Contains(recorder.Body.String(), `\u0026`) {
+					t.Errorf("page %v: body has the escaped &: %v", i, recorder.Body.String())
+				}
+				var page struct {
+					listJSON
+					Items []readingJSON `json:"items"`
+				}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+					t.Fatal(err)
+				}
+				if page.Metric != tt.metric.Name || page.Unit != tt.metric.Unit || page.Total != tt.total || page.Offset != i*4 {
+					t.Errorf("page %v = %v %v, total %v at offset %v, want %v %v, total %v at offset %v", i, page.Metric, page.Unit, page.Total, page.Offset, tt.metric.Name, tt.metric.Unit, tt.total, i*4)
+				}
+				//Every page is full until the total.
+				if len(page.Items) != wantSize {
+					t.Errorf("page %v has %v readings, want %v", i, len(page.Items), wantSize)
+				}
+				for _, item := range page.Items {
+					key := item.Timestamp.String()
+					if seen[key] {
+						t.Errorf("page %v repeats the reading of %v", i, key)
+					}
+					seen[key] = true
+				}
+				if i == len(tt.pageSizes)-1 {
+					if len(page.Next) > 0 {
+						t.Errorf("last page has the next page %v", page.Next)
+					}
+					break
+				}
+				wantNext := fmt.Sprintf("/readings?from=2024-05-01&limit=4&metric=%v&offset=%v&to=2024-05-01", tt.metric.Name, (i+1)*4)
+				if page.Next != wantNext {
+					t.Fatalf("page %v: next = %v, want %v", i, page.Next, wantNext)
+				}
+				target = page.Next
+			}
+			if len(seen) != tt.total {
+				t.Errorf("%v readings on the pages, want the total %v", len(seen), tt.total)
+			}
+		})
+	}
+}
+
+func TestServerETag(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 10:00", 30)
+	server := NewServer(dbc)
+
+	for _, target := range []string{"/stations", "/readings", "/stats?granularity=all", "/stations.geojson", "/openapi.json"} {
+		recorder := serveTest(t, server, target, "")
+		etag := recorder.Header().Get("ETag")
+		if recorder.Code != http.StatusOK || len(etag) == 0 || recorder.Header().Get("Cache-Control") != "no-cache" {
+			t.Errorf("%v: status %v with the ETag %v, want 200 with an ETag", target, recorder.Code, etag)
+			continue
+		}
+		tests := []struct {
+			ifNoneMatch string
+			wantStatus  int
+		}{
+			{etag, http.StatusNotModified},
+			{"W/" + etag, http.StatusNotModified},
+			{`"other", ` + etag, http.StatusNotModified},
+			{"*", http.StatusNotModified},
+			{`"other"`, http.StatusOK},
+		}
+		for _, tt := range tests {
+			recorder := serveTest(t, server, target, tt.ifNoneMatch)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("%v with If-None-Match %v: status %v, want %v", target, tt.ifNoneMatch, recorder.Code, tt.wantStatus)
+			}
+			if recorder.Code == http.StatusNotModified && (recorder.Body.Len() > 0 || recorder.Header().Get("ETag") != etag) {
+				t.Errorf("%v with If-None-Match %v: 304 with the body %q and the ETag %v, want no body and the same ETag", target, tt.ifNoneMatch, recorder.Body.String(), recorder.Header().Get("ETag"))
+			}
+		}
+	}
+
+	//The ETag changes with the saved data.
+	etag := serveTest(t, server, "/stations", "").Header().Get("ETag")
+	saveTestReading(t, dbc, MetricAirTemperature, "S2", "2024-05-01 10:00", 29)
+	recorder := serveTest(t, server, "/stations", etag)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("ETag") == etag {
+		t.Errorf("status %v with the ETag %v after a new station, want 200 with a new ETag", recorder.Code, recorder.Header().Get("ETag"))
+	}
+}
+
+func TestServerErrorStatus(t *testing.T) {
+	dbc := newTestDB(t)
+	saveTestReading(t, dbc, MetricAirTemperature, "S1", "2024-05-01 10:00", 30)
+	server := NewServer(dbc)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+	}{
+		{"unknown metric", http.MethodGet, "/readings?metric=snowfall", http.StatusBadRequest},
+		{"invalid from", http.MethodGet, "/readings?from=01/05/2024", http.StatusBadRequest},
+		{"unknown granularity", http.MethodGet, "/stats?granularity=week", http.StatusBadRequest},
+		{"invalid date", http.MethodGet, "/stats?granularity=day&date=2024-5-1", http.StatusBadRequest},
+		{"invalid month", http.MethodGet, "/stats?granularity=month&month=May", http.StatusBadRequest},
+		{"two stations", http.MethodGet, "/stats?granularity=all&station=S1,S2", http.StatusBadRequest},
+		{"invalid geojson date", http.MethodGet, "/stations.geojson?date=yesterday", http.StatusBadRequest},
+		{"unknown path", http.MethodGet, "/temperatures", http.StatusNotFound},
+		{"post", http.MethodPost, "/stations", http.StatusMethodNotAllowed},
+		{"valid stats", http.MethodGet, "/stats?granularity=day&date=2024-05-01&station=S1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.target, nil))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status %v, want %v: %v", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+			var body map[string]string
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil || len(body["error"]) == 0 || recorder.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("body %v of %v, want the JSON error", recorder.Body.String(), recorder.Header().Get("Content-Type"))
+			}
+			if tt.wantStatus == http.StatusMethodNotAllowed && recorder.Header().Get("Allow") != "GET, HEAD" {
+				t.Errorf("Allow = %v, want GET, HEAD", recorder.Header().Get("Allow"))
+			}
+		})
+	}
+
+	//The database error is a 500.
+	if _, err := dbc.Exec("DROP TABLE readings"); err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"/readings", "/stats?granularity=all"} {
+		if recorder := serveTest(t, server, target, ""); recorder.Code != http.StatusInternalServerError {
+			t.Errorf("%v without the readings table: status %v, want 500", target, recorder.Code)
+		}
+	}
+}
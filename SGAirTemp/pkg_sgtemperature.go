@@ -2,15 +2,10 @@ package SGAirTemp
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"net/url"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +14,12 @@ import (
 //DB struct - a placeholder for Database connection.
 type DB struct {
 	*sql.DB
+	//Scheduler - used when many API calls are needed, ie: the hourly data of a day or a month.
+	Scheduler *FetchScheduler
+	//API - the client calling the API, the timeout and retries are set here.
+	API *APIClient
+	//Metric - the measurement type fetched and used by the statistics, DefaultMetric if it is not set.
+	Metric Metric
 }
 
 //EarliestDataAvail - Taken form "Coverage" https://data.gov.sg/dataset/realtime-weather-readings
@@ -26,6 +27,9 @@ type DB struct {
 const EarliestDataAvail = "2016-12-14"
 const strStandardFormat = "2006-01-02"
 
+//ArrayHour - Fixed array. To generate hourly
+var ArrayHour = []string{"00", "01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11", "12", "13", "14", "15", "16", "17", "18", "19", "20", "21", "22", "23"}
+
 //Location struct - to form the location object inside the Station
 type Location struct {
 	Latitude  float64 `json:"latitude"`
@@ -40,176 +44,158 @@ type Station struct {
 }
 
 //Metadata struct - to form the location object inside the Station
+//ReadingType and ReadingUnit are provided by the API, ie: "DBT 1M F" and "deg C" for the air-temperature.
 type Metadata struct {
-	Station []Station `json:"stations"`
+	Station     []Station `json:"stations"`
+	ReadingType string    `json:"reading_type,omitempty"`
+	ReadingUnit string    `json:"reading_unit,omitempty"`
 }
 
-//TemperatureReading - to as the placeholder to stored the TemperatureReading
-type TemperatureReading struct {
+//Reading - to as the placeholder to stored the reading of the station, the unit is on the Metric.
+type Reading struct {
 	StationID string  `json:"station_id"`
 	Value     float64 `json:"value"`
 }
 
-//TemperatureData struct - as placeholder for the response: items.readings
-type TemperatureData struct {
-	Timestamp          string               `json:"timestamp"`
-	TemperatureReading []TemperatureReading `json:"readings"`
+//WeatherData struct - as placeholder for the response: items.readings
+type WeatherData struct {
+	Timestamp string    `json:"timestamp"`
+	Readings  []Reading `json:"readings"`
 }
 
-//TemperatureResponse struct - response body for the TemperatureAPI response
-type TemperatureResponse struct {
-	Metadata        Metadata          `json:"metadata"`
-	TemperatureData []TemperatureData `json:"items"`
+//WeatherResponse struct - response body of the realtime weather readings API, the same for every Metric.
+type WeatherResponse struct {
+	Metadata Metadata      `json:"metadata"`
+	Items    []WeatherData `json:"items"`
 }
 
 //GetDateInput - Get Date input from user.
-func GetDateInput() string {
+func GetDateInput() (string, error) {
 	//Get the Input of Date from user.
 	inpDate := bufio.NewReader(os.Stdin)
 	fmt.Print("Provide Date (format: YYYY-MM-DD): ")
 	dateVal, _ := inpDate.ReadString('\n')
 
 	//Validate the Date Input
-	dateVal = CheckInputDate(dateVal)
-
-	//All validation is completed and pass, return it.
-	return dateVal
+	return CheckInputDate(dateVal)
 }
 
 //GetTimeInput - Get Time input from user.
-func GetTimeInput() string {
+func GetTimeInput() (string, error) {
 	//Get the time input from the user.
 	inpTime := bufio.NewReader(os.Stdin)
 	fmt.Print("Provide Time (format: HH:mm): ")
 	timeVal, _ := inpTime.ReadString('\n')
 
 	//Validate the Time Input
-	timeVal = CheckInputTime(timeVal)
-
-	//All validation is completed and pass, return it.
-	return timeVal
+	return CheckInputTime(timeVal)
 }
 
 //GetDateTimeInput - Get Date/Time input from user.
 //Validate if the Date/Time is valid.
 //If Date/Time is empty, it wil use the current Date/Time
-func GetDateTimeInput() (string, string) {
+func GetDateTimeInput() (string, string, error) {
 
 	//Get the date input from the user.
-	dateVal := GetDateInput()
+	dateVal, err := GetDateInput()
+	if err != nil {
+		return dateVal, "", err
+	}
 
 	//Get the time input from the user.
-	timeVal := GetTimeInput()
+	timeVal, err := GetTimeInput()
 
-	return dateVal, timeVal
+	return dateVal, timeVal, err
 }
 
-//APICallAndGetResponse - API Call for the
-func APICallAndGetResponse(ValDate, ValTime string) (response TemperatureResponse, errorMessage string) {
-	dateTimeCondition := ""
-	if len(ValDate) > 0 && len(ValTime) > 0 {
-		dateTimeCondition = fmt.Sprintf("date_time=%v", url.QueryEscape(ValDate+"T"+ValTime+":00"))
-	} else if len(ValDate) > 0 {
-		dateTimeCondition = fmt.Sprintf("date=%v", url.QueryEscape(ValDate))
-	} else {
-		return response, "Please provide Date and Time."
-	}
-	//String API Call, we only need to get the data until the minute level.
-	strAPICall := fmt.Sprintf("https://api.data.gov.sg/v1/environment/air-temperature?%v", dateTimeCondition)
-	res, err := http.Get(strAPICall)
+//APICallAndGetResponse - API Call of the DefaultMetric for the Date (YYYY-MM-DD) and Time (HH:mm) with the DefaultAPIClient, empty Time for the FULL day.
+func APICallAndGetResponse(ctx context.Context, ValDate, ValTime string) (WeatherResponse, error) {
+	return DefaultAPIClient.GetReadings(ctx, DefaultMetric, ValDate, ValTime)
+}
 
-	if err != nil {
-		log.Fatal(err)
-	} else {
-		//We found the
-		if res.StatusCode == 200 {
-			//Read the response body and stored it to variable body
-			body, err := ioutil.ReadAll(res.Body)
-			if err != nil {
-				panic(err.Error())
-			} else {
-				//API couldn't provide data.
-				//Sometimes the API don't have the data for that day, at least I found the date they can't provide data is: 2020-06-10 and 2020-06-11
-				if len(body) <= 110 {
-					errorMessage = fmt.Sprintf("Error, can't find API response body info for %v %v\nDuring calling-> %v", ValDate, ValTime, strAPICall)
-				} else {
-					//All fine, Unmarshal the response from the API Response Body - Parsing
-					json.Unmarshal([]byte(body), &response)
-				}
-			}
-		} else {
-			errorMessage = fmt.Sprintf("\nError Code: %v\nDuring calling-> %v", res.StatusCode, strAPICall)
-		}
+//apiClient - the API client of the DB, DefaultAPIClient if it is not set.
+func (dbc *DB) apiClient() *APIClient {
+	if dbc.API != nil {
+		return dbc.API
 	}
-	return response, errorMessage
+	return DefaultAPIClient
 }
 
 //CallTemperatureAPIAndSave - Get User Input for Date and Time and Save it to Database
-func (dbc *DB) CallTemperatureAPIAndSave(ValDate, ValTime string, displayResult bool) (resultInfo string) {
-	//Call the API for TemperatureReading and retrieve the response.
-	response, err1 := APICallAndGetResponse(ValDate, ValTime)
-	if err1 != "" {
-		resultInfo = err1
-	} else {
-		//Iterate for the Station object and save it to the Database
-		for i := 0; i < (len(response.Metadata.Station)); i++ {
-			st := response.Metadata.Station[i]
-			dbc.InsertStation(st.StationID, st.StationName, strconv.FormatFloat(st.Location.Latitude, 'f', 5, 64), strconv.FormatFloat(st.Location.Longitude, 'f', 5, 64))
+//The readings are of the DB Metric (air-temperature by default), the raw response is also saved to the Store of the API client, if any.
+//The derived Metric calls the API of the air temperature and the relative humidity.
+func (dbc *DB) CallTemperatureAPIAndSave(ctx context.Context, ValDate, ValTime string, displayResult bool) error {
+	var response WeatherResponse
+	for _, metric := range sourceMetrics(dbc.metric()) {
+		//Call the API for the readings of the Metric and retrieve the response.
+		var err error
+		response, err = dbc.apiClient().GetReadings(ctx, metric, ValDate, ValTime)
+		if err != nil {
+			return err
 		}
 
-		//Iterate for the TemperatureReading object and save it to the Database
-		totalReadings := len(response.TemperatureData)
-		if len(ValTime) == 0 && totalReadings > 0 && displayResult == false {
-			fmt.Printf("\nProcessing the API response, this may take a while: ")
+		//Save the Station and Reading object to the Database
+		if _, err := dbc.SaveWeatherResponse(metric, response); err != nil {
+			return err
 		}
-		for a := 0; a < totalReadings; a++ {
-			if len(ValTime) == 0 && totalReadings > 0 && displayResult == false {
-				fmt.Printf(".")
-			}
-			TemperatureData := &response.TemperatureData[a]
-			strTimeStamp := TemperatureData.Timestamp
-			for i := 0; i < (len(TemperatureData.TemperatureReading)); i++ {
-				rd := TemperatureData.TemperatureReading[i]
-				dbc.InsertTemperatureReading(rd.StationID, strTimeStamp, rd.Value)
-			}
-			if len(ValTime) > 0 && displayResult == true && totalReadings > 0 {
-				fmt.Printf("\nThe Result returned by the API might not be the same timing as what you input.\nThe API will sometimes return the nearest time on what you requested.")
-				arrDateTime := strings.Split(strTimeStamp, "T")
-				valDate := string(arrDateTime[0])
-				valTime := string([]rune(arrDateTime[1])[0:5])
-				dbc.PrintTemperatureReading(valDate, valTime)
-			}
+	}
 
+	if len(ValTime) > 0 && displayResult == true {
+		for _, WeatherData := range response.Items {
+			fmt.Printf("\nThe Result returned by the API might not be the same timing as what you input.\nThe API will sometimes return the nearest time on what you requested.")
+			arrDateTime := strings.Split(WeatherData.Timestamp, "T")
+			valDate := string(arrDateTime[0])
+			valTime := string([]rune(arrDateTime[1])[0:5])
+			if err := dbc.PrintTemperatureReading(valDate, valTime); err != nil {
+				return err
+			}
 		}
-		if len(ValTime) == 0 && displayResult == false && totalReadings > 0 {
-			fmt.Printf(" Done")
-		}
-
 	}
-	return resultInfo
+	if len(ValTime) == 0 && displayResult == false && len(response.Items) > 0 {
+		fmt.Printf("\nSaved %v timestamps of readings. Done", len(response.Items))
+	}
+	return nil
 }
 
 //UserInputAndSaveTemperatureData - Get User Input for Date and Time and Save it to Database
-func (dbc *DB) UserInputAndSaveTemperatureData() string {
-	resultInfo := ""
-	dateVal, timeVal := GetDateTimeInput()
+func (dbc *DB) UserInputAndSaveTemperatureData(ctx context.Context) error {
+	dateVal, timeVal, err := GetDateTimeInput()
+	if err != nil {
+		return err
+	}
+	return dbc.FetchAndSaveTemperatureData(ctx, dateVal, timeVal)
+}
 
-	if ValidateDateTimeLessThanNow(dateVal, timeVal) == true {
-		resultInfo = dbc.CallTemperatureAPIAndSave(dateVal, timeVal, true)
-	} else {
-		resultInfo = fmt.Sprintf("The Inputted date/time '%v %v' must be not later than current time ", dateVal, timeVal)
+//FetchAndSaveTemperatureData - Validate the Date and Time, call the API and Save the result to Database.
+//Empty Date/Time will use the current Date/Time.
+func (dbc *DB) FetchAndSaveTemperatureData(ctx context.Context, dateVal, timeVal string) error {
+	dateVal, err := CheckInputDate(dateVal)
+	if err != nil {
+		return err
+	}
+	timeVal, err = CheckInputTime(timeVal)
+	if err != nil {
+		return err
 	}
-	return resultInfo
+
+	if ValidateDateTimeLessThanNow(dateVal, timeVal) == false {
+		return &InputError{Value: dateVal + " " + timeVal, Message: "must be not later than current time", Err: ErrFutureDate}
+	}
+	return dbc.CallTemperatureAPIAndSave(ctx, dateVal, timeVal, true)
 }
 
 //GetChoosenStation - function to get ID of chosen Station, empty string if the user choose all.
-func (dbc *DB) GetChoosenStation() string {
+func (dbc *DB) GetChoosenStation() (string, error) {
 	StringChosenID := ""
 	StationIDs := []string{}
 	var StationID, StationName string
 	var i int
 	StrQuery := "SELECT station_id, station_name FROM stations ORDER BY station_name"
-	rows, _ := dbc.Query(StrQuery)
+	rows, err := dbc.Query(StrQuery)
+	if err != nil {
+		return "", dbError("query stations", err)
+	}
+	defer rows.Close()
 
 	fmt.Println("Please choose the Station:")
 	fmt.Println("0. ALL Stations")
@@ -225,326 +211,178 @@ func (dbc *DB) GetChoosenStation() string {
 		//Get the Input from the User
 		inp := GetUserInput(fmt.Sprintf("\nYour choice (0-%v): ", i))
 		inputInt, _ := strconv.Atoi(inp)
-		if inputInt > 0 {
+		if inputInt > 0 && inputInt <= i {
 			//Minus 1 since the array start from 1.
 			StringChosenID = StationIDs[inputInt-1]
 		} else {
 			fmt.Printf("\nThe input date is not between 1 to %v, so ALL Station is selected by default.", i)
 		}
 	}
-	return StringChosenID
+	return StringChosenID, nil
 }
 
 //GetOneDayStatistic a function to get the hourly data
-func (dbc *DB) GetOneDayStatistic(strDateRequested string) string {
-	resultInfo := ""
-	var StationName string
-	var yr string
-	var mo string
-	var dt string
-	var hr string
-	var mi string
-	var value float64
-	var cntExisting int
-
-	dontShowMessage := false
-	var dateVal string
+//When strDateRequested is provided, it will only make sure the hourly data for that date is saved, without asking the user.
+func (dbc *DB) GetOneDayStatistic(ctx context.Context, strDateRequested string) error {
 	if len(strDateRequested) > 0 {
-		dateVal = CheckInputDate(strDateRequested)
-		dontShowMessage = true
+		dateVal, err := CheckInputDate(strDateRequested)
+		if err != nil {
+			return err
+		}
+		return dbc.EnsureOneDayHourlyData(ctx, dateVal, "", false)
 	}
 
-	callAPI := false
-
-	minTemp := 9999.99
-	maxTemp := -9999.99
-	totalTemp := 0.00
-	totalReadings := 0.00
-	centerData := 0
-
-	//Minimum Temperature Date/Time and StationName
-	minTempDateTimeAndStation := ""
-	//Maximum Temperature Date/Time and StationName
-	maxTempDateTimeAndStation := ""
-
-	//Flexible array, by using splices
-	WhereCondition := []string{}
-
-	//Fixed array. To generate hourly
-	ArrayHour := []string{"00", "01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11", "12", "13", "14", "15", "16", "17", "18", "19", "20", "21", "22", "23"}
+	dateVal, err := GetDateInput()
+	if err != nil {
+		return err
+	}
+	StationID, err := dbc.GetChoosenStation()
+	if err != nil {
+		return err
+	}
+	return dbc.PrintOneDayStatistic(ctx, dateVal, StationID)
+}
 
-	if dontShowMessage == false {
-		dateVal = GetDateInput()
+//EnsureOneDayHourlyData - make sure the 24 hourly readings for the date are saved, call the API if one of the station(s) is incomplete.
+//Empty StationID means ALL Stations.
+//The API error for an hour is printed and the other hours are still called, only the database error is returned.
+func (dbc *DB) EnsureOneDayHourlyData(ctx context.Context, dateVal, StationID string, showProgress bool) error {
+	if ValidateInputDateMaxYesterday(dateVal) == false {
+		return errNotLaterThanYesterday(dateVal)
 	}
 
-	StationID := ""
-	if dontShowMessage == false {
-		StationID = dbc.GetChoosenStation()
+	requests, err := dbc.hourlyRequestsNeeded(dateVal, StationID)
+	if err != nil || len(requests) == 0 {
+		return err
 	}
-	StartExecutionTime := time.Now()
-	if ValidateInputDateMaxYesterday(dateVal) == true {
-		StrQuery := ""
 
-		//Get the maximum length of the all the Station's name.
-		MaxStationNameLen := dbc.GetScalar("SELECT LENGTH(station_name) scalarRes FROM stations ORDER BY LENGTH(station_name) DESC LIMIT 1")
-		MaxStationNameLenInt, _ := strconv.Atoi(MaxStationNameLen)
+	fmt.Printf("\nCalling the API to check for every hour for date (YYYY-MM-DD): %v\n", dateVal)
+	return dbc.fetchHourly(ctx, requests, showProgress)
+}
 
-		//extract the year, month and date
-		yrCond := string([]rune(dateVal)[0:4])
-		moCond := string([]rune(dateVal)[5:7])
-		dtCond := string([]rune(dateVal)[8:10])
+//hourlyRequestsNeeded - the 24 hourly API calls of the date, if there is no data or one of the station(s) not having the 24 data.
+func (dbc *DB) hourlyRequestsNeeded(dateVal, StationID string) ([]FetchRequest, error) {
+	requests := []FetchRequest{}
+	callAPI := false
 
-		//Set the Where condition
-		WhereCondition = append(WhereCondition, fmt.Sprintf("yr ='%v'", yrCond))
-		WhereCondition = append(WhereCondition, fmt.Sprintf("mo ='%v'", moCond))
-		WhereCondition = append(WhereCondition, fmt.Sprintf("dt ='%v'", dtCond))
-		WhereCondition = append(WhereCondition, fmt.Sprintf("hr IN ('%v')", strings.Join(ArrayHour[:], "','")))
-		WhereCondition = append(WhereCondition, "mi ='00'")
-		if len(StationID) > 0 {
-			WhereCondition = append(WhereCondition, fmt.Sprintf("r.station_id ='%v'", StationID))
-		}
+	filter := NewDayQuery(dateVal, StationID).Filter()
+	counts, err := dbc.CountReadingsByStation(filter)
+	if err != nil {
+		return requests, err
+	}
 
-		StrQueryCnt := fmt.Sprintf("SELECT count(value) AS scalarRes FROM readings r INNER JOIN stations s ON s.station_id = r.station_id WHERE %v GROUP BY s.station_name", strings.Join(WhereCondition[:], " AND "))
-		GetTotalRows, _ := strconv.Atoi(dbc.GetScalar(StrQueryCnt))
-
-		if GetTotalRows > 0 {
-			StrQuery := fmt.Sprintf("SELECT s.station_name, count(value) AS cnt FROM readings r INNER JOIN stations s ON s.station_id = r.station_id WHERE %v GROUP BY s.station_name ORDER BY s.station_name", strings.Join(WhereCondition[:], " AND "))
-			rows, _ := dbc.Query(StrQuery)
-			for rows.Next() {
-				rows.Scan(&StationName, &cntExisting)
-				if cntExisting < 24 {
-					//One of the station(s) not having the 24 data, we need to pull it from the API
-					callAPI = true
-				}
-			}
-		} else {
-			//Couldn't find any data, call the API
+	//Couldn't find any data, or one of the station(s) not having the 24 data, we need to pull it from the API
+	if len(counts) == 0 {
+		callAPI = true
+	}
+	for _, cntExisting := range counts {
+		if cntExisting < 24 {
 			callAPI = true
 		}
+	}
 
-		if callAPI == true {
-			fmt.Printf("\nCalling the API to check for every hour for date (YYYY-MM-DD): %v\n", dateVal)
+	if callAPI == true {
+		for _, metric := range sourceMetrics(dbc.metric()) {
 			for h := 0; h < 24; h++ {
-				if dontShowMessage == false {
-					fmt.Printf("%v ", ArrayHour[h]+":00")
-				} else {
-					fmt.Printf(".")
-				}
-				resultInfo := dbc.CallTemperatureAPIAndSave(dateVal, ArrayHour[h]+":00", false)
-				if resultInfo != "" {
-					fmt.Println(resultInfo)
-				}
+				requests = append(requests, FetchRequest{Metric: metric, Date: dateVal, Time: ArrayHour[h] + ":00"})
 			}
 		}
+	}
+	return requests, nil
+}
 
-		if dontShowMessage == false {
-			StrQueryCnt = fmt.Sprintf("SELECT count(value) AS scalarRes FROM readings r INNER JOIN stations s ON s.station_id = r.station_id WHERE %v ", strings.Join(WhereCondition[:], " AND "))
-			GetTotalRows, _ = strconv.Atoi(dbc.GetScalar(StrQueryCnt))
-
-			if GetTotalRows > 0 {
-				centerData = int(GetTotalRows / 2)
-				EvenPosStart := centerData
-				EvenPosEnd := centerData + 1
-				EvenPosStartValue := 0.00
-				EvenPosEndValue := 0.00
-
-				StrQuery = fmt.Sprintf("SELECT s.station_name, yr, mo, dt, hr, mi, value FROM readings r INNER JOIN stations s ON s.station_id = r.station_id WHERE %v ORDER BY r.value, s.station_name, yr, mo, dt, hr, mi", strings.Join(WhereCondition[:], " AND "))
-				rows, _ := dbc.Query(StrQuery)
-
-				fmt.Printf("\n%"+MaxStationNameLen+"s"+" | %16s | %s\n", "StationName", "Date/Time", "Value")
-				fmt.Printf("%s\n", strings.Repeat("=", MaxStationNameLenInt+27))
-
-				for rows.Next() {
-					rows.Scan(&StationName, &yr, &mo, &dt, &hr, &mi, &value)
-					fmt.Printf("%"+MaxStationNameLen+"s"+" | %v-%v-%v %v:%v | %v\n", StationName, yr, mo, dt, hr, mi, value)
-					if value > maxTemp {
-						maxTemp = value
-
-						//Maximum Temperature Date/Time and StationName
-						maxTempDateTimeAndStation = fmt.Sprintf("  - %v-%v-%v %v:%v -> %v\n", yr, mo, dt, hr, mi, StationName)
-					} else if value == maxTemp {
-						maxTempDateTimeAndStation = fmt.Sprintf("%v  - %v-%v-%v %v:%v -> %v\n", maxTempDateTimeAndStation, yr, mo, dt, hr, mi, StationName)
-					}
-
-					if value < minTemp {
-						minTemp = value
-
-						//Minimum Temperature Date/Time and StationName
-						minTempDateTimeAndStation = fmt.Sprintf("  - %v-%v-%v %v:%v -> %v\n", yr, mo, dt, hr, mi, StationName)
-					} else if value == minTemp {
-						maxTempDateTimeAndStation = fmt.Sprintf("%v  - %v-%v-%v %v:%v -> %v\n", minTempDateTimeAndStation, yr, mo, dt, hr, mi, StationName)
-					}
-
-					totalTemp += value
-					totalReadings++
-					if EvenPosStart == int(totalReadings) {
-						EvenPosStartValue = value
-					}
-					if EvenPosEnd == int(totalReadings) {
-						EvenPosEndValue = value
-					}
-				}
-				fmt.Printf("\nTotal Readings                   : %v", totalReadings)
-				fmt.Printf("\nAverange Readings                : %.2f", (totalTemp / totalReadings))
-				//Is Even, so we need to get the average of the two of the center data
-				if (GetTotalRows % 2) == 0 {
-					fmt.Printf("\nMean Readings                    : %.2f", (EvenPosStartValue+EvenPosEndValue)/2)
-				} else {
-					//Is ODD, just get the center/middle data
-					fmt.Printf("\nMean Readings                    : %.2f", EvenPosEndValue)
-				}
-
-				fmt.Printf("\nMinimum Temperature              : %v", minTemp)
-				fmt.Printf("\nMinimum Temperature Occurence(s) : ")
-				fmt.Printf("\n%v", minTempDateTimeAndStation)
-				fmt.Printf("\nMaximum Temperature              : %v", maxTemp)
-				fmt.Printf("\nMaximum Temperature Occurence(s) : ")
-				fmt.Printf("\n%v", maxTempDateTimeAndStation)
-				EndExecutionTime := time.Now()
-				TimeNeeded := EndExecutionTime.Sub(StartExecutionTime)
-				fmt.Printf("\nTime Needed                      : %v", TimeNeeded)
-			} else {
-				resultInfo = fmt.Sprintf("Couldn't find the data reading for '%v'. ", dateVal)
-			}
+//fetchHourly - call the API for the hourly requests with the Scheduler, the API error is printed and not returned.
+func (dbc *DB) fetchHourly(ctx context.Context, requests []FetchRequest, showProgress bool) error {
+	scheduler := dbc.Scheduler
+	if scheduler == nil {
+		scheduler = NewFetchScheduler(DefaultFetchWorkers, DefaultRequestsPerSecond)
+	}
+	return scheduler.Run(ctx, dbc, requests, func(result FetchResult) error {
+		if showProgress == true {
+			fmt.Printf("%v ", result.Request.Time)
+		} else {
+			fmt.Printf(".")
+		}
+		if result.Err != nil {
+			fmt.Println(result.Err)
 		}
-	} else {
-		resultInfo = fmt.Sprintf("The Inputted date %s must be not later than yesterday. ", dateVal)
+		return nil
+	})
+}
+
+//PrintOneDayStatistic - print the hourly statistic (24 hours) for the date, empty StationID means ALL Stations.
+func (dbc *DB) PrintOneDayStatistic(ctx context.Context, dateVal, StationID string) error {
+	dateVal, err := CheckInputDate(dateVal)
+	if err != nil {
+		return err
 	}
 
-	return resultInfo
+	if err := dbc.EnsureOneDayHourlyData(ctx, dateVal, StationID, true); err != nil {
+		return err
+	}
+
+	query := NewDayQuery(dateVal, StationID)
+	stats, err := dbc.GetStatistics(query)
+	if err != nil {
+		return err
+	}
+	if stats.Count == 0 {
+		return errNoData(dateVal)
+	}
+
+	if err := dbc.printStatisticReadings(query.Filter()); err != nil {
+		return err
+	}
+	PrintStatistics(stats)
+	return nil
 }
 
 //GetOneFullDayStatistic a function to get one full day statistic provided by the API
-func (dbc *DB) GetOneFullDayStatistic() string {
-	resultInfo := ""
-	var StationName string
-	var yr string
-	var mo string
-	var dt string
-	var hr string
-	var mi string
-	var value float64
-
-	dontShowMessage := false
-	var dateVal string
-	dateVal = GetDateInput()
-	dateVal = CheckInputDate(dateVal)
-
-	minTemp := 9999.99
-	maxTemp := -9999.99
-	totalTemp := 0.00
-	totalReadings := 0.00
-	centerData := 0
-
-	//Minimum Temperature Date/Time and StationName
-	minTempDateTimeAndStation := ""
-	//Maximum Temperature Date/Time and StationName
-	maxTempDateTimeAndStation := ""
-
-	//Flexible array, by using splices
-	WhereCondition := []string{}
-
-	if ValidateInputDateMaxYesterday(dateVal) == true {
-		StrQuery := ""
-
-		//extract the year, month and date
-		yrCond := string([]rune(dateVal)[0:4])
-		moCond := string([]rune(dateVal)[5:7])
-		dtCond := string([]rune(dateVal)[8:10])
-
-		StationID := ""
-		if dontShowMessage == false {
-			StationID = dbc.GetChoosenStation()
-		}
+func (dbc *DB) GetOneFullDayStatistic(ctx context.Context) error {
+	dateVal, err := GetDateInput()
+	if err != nil {
+		return err
+	}
+	if ValidateInputDateMaxYesterday(dateVal) == false {
+		return errNotLaterThanYesterday(dateVal)
+	}
+	StationID, err := dbc.GetChoosenStation()
+	if err != nil {
+		return err
+	}
+	return dbc.PrintOneFullDayStatistic(ctx, dateVal, StationID)
+}
 
-		//Set the Where condition
-		WhereCondition = append(WhereCondition, fmt.Sprintf("yr ='%v'", yrCond))
-		WhereCondition = append(WhereCondition, fmt.Sprintf("mo ='%v'", moCond))
-		WhereCondition = append(WhereCondition, fmt.Sprintf("dt ='%v'", dtCond))
-		if len(StationID) > 0 {
-			WhereCondition = append(WhereCondition, fmt.Sprintf("r.station_id ='%v'", StationID))
-		}
+//PrintOneFullDayStatistic - print the statistic of every minutes data for the date, empty StationID means ALL Stations.
+func (dbc *DB) PrintOneFullDayStatistic(ctx context.Context, dateVal, StationID string) error {
+	dateVal, err := CheckInputDate(dateVal)
+	if err != nil {
+		return err
+	}
 
-		StartExecutionTime := time.Now()
-
-		fmt.Printf("\nCalling the API to check for FULL day (YYYY-MM-DD): %v\n", dateVal)
-		resultInfo := dbc.CallTemperatureAPIAndSave(dateVal, "", false)
-
-		if len(resultInfo) <= 0 {
-			StrQueryCnt := fmt.Sprintf("SELECT count(value) AS scalarRes FROM readings r INNER JOIN stations s ON s.station_id = r.station_id WHERE %v ", strings.Join(WhereCondition[:], " AND "))
-			GetTotalRows, _ := strconv.Atoi(dbc.GetScalar(StrQueryCnt))
-
-			if GetTotalRows > 0 {
-				centerData = int(GetTotalRows / 2)
-				EvenPosStart := centerData
-				EvenPosEnd := centerData + 1
-				EvenPosStartValue := 0.00
-				EvenPosEndValue := 0.00
-
-				StrQuery = fmt.Sprintf("SELECT s.station_name, yr, mo, dt, hr, mi, value FROM readings r INNER JOIN stations s ON s.station_id = r.station_id WHERE %v ORDER BY r.value, s.station_name, yr, mo, dt, hr, mi", strings.Join(WhereCondition[:], " AND "))
-				rows, _ := dbc.Query(StrQuery)
-
-				for rows.Next() {
-					rows.Scan(&StationName, &yr, &mo, &dt, &hr, &mi, &value)
-					if value > maxTemp {
-						maxTemp = value
-
-						//Maximum Temperature Date/Time and StationName
-						maxTempDateTimeAndStation = fmt.Sprintf("  - %v-%v-%v %v:%v -> %v\n", yr, mo, dt, hr, mi, StationName)
-					} else if value == maxTemp {
-						maxTempDateTimeAndStation = fmt.Sprintf("%v  - %v-%v-%v %v:%v -> %v\n", maxTempDateTimeAndStation, yr, mo, dt, hr, mi, StationName)
-					}
-
-					if value < minTemp {
-						minTemp = value
-
-						//Minimum Temperature Date/Time and StationName
-						minTempDateTimeAndStation = fmt.Sprintf("  - %v-%v-%v %v:%v -> %v\n", yr, mo, dt, hr, mi, StationName)
-					} else if value == minTemp {
-						maxTempDateTimeAndStation = fmt.Sprintf("%v  - %v-%v-%v %v:%v -> %v\n", minTempDateTimeAndStation, yr, mo, dt, hr, mi, StationName)
-					}
-
-					totalTemp += value
-					totalReadings++
-					if EvenPosStart == int(totalReadings) {
-						EvenPosStartValue = value
-					}
-					if EvenPosEnd == int(totalReadings) {
-						EvenPosEndValue = value
-					}
-				}
-				fmt.Printf("\nTotal Readings                   : %v", totalReadings)
-				fmt.Printf("\nAverange Readings                : %.2f", (totalTemp / totalReadings))
-				//Is Even, so we need to get the average of the two of the center data
-				if (GetTotalRows % 2) == 0 {
-					fmt.Printf("\nMean Readings                    : %.2f", (EvenPosStartValue+EvenPosEndValue)/2)
-				} else {
-					//Is ODD, just get the center/middle data
-					fmt.Printf("\nMean Readings                    : %.2f", EvenPosEndValue)
-				}
-
-				fmt.Printf("\nMinimum Temperature              : %v", minTemp)
-				fmt.Printf("\nMinimum Temperature Occurence(s) : ")
-				fmt.Printf("\n%v", minTempDateTimeAndStation)
-				fmt.Printf("\nMaximum Temperature              : %v", maxTemp)
-				fmt.Printf("\nMaximum Temperature Occurence(s) : ")
-				fmt.Printf("\n%v", maxTempDateTimeAndStation)
-				EndExecutionTime := time.Now()
-				TimeNeeded := EndExecutionTime.Sub(StartExecutionTime)
-				fmt.Printf("\nTime Needed                      : %v", TimeNeeded)
+	if ValidateInputDateMaxYesterday(dateVal) == false {
+		return errNotLaterThanYesterday(dateVal)
+	}
 
-			}
-		}
-	} else {
-		resultInfo = fmt.Sprintf("The Inputted date %s must be not later than yesterday. ", dateVal)
+	fmt.Printf("\nCalling the API to check for FULL day (YYYY-MM-DD): %v\n", dateVal)
+	if err := dbc.CallTemperatureAPIAndSave(ctx, dateVal, "", false); err != nil {
+		return err
 	}
 
-	return resultInfo
+	stats, err := dbc.GetStatistics(NewFullDayQuery(dateVal, StationID))
+	if err != nil {
+		return err
+	}
+	if stats.Count == 0 {
+		return errNoData(dateVal)
+	}
+	PrintStatistics(stats)
+	return nil
 }
 
 //GetUserInput a function to get the user input.
 func GetUserInput(strMessage string) string {
-	fmt.Printf(strMessage)
+	fmt.Print(strMessage)
 	inpChoice := bufio.NewReader(os.Stdin)
 	inp, _ := inpChoice.ReadString('\n')
 	inp = strings.TrimRight(inp, "\r\n")
@@ -554,257 +392,77 @@ func GetUserInput(strMessage string) string {
 
 //GetOneMonthStatistic a function to get the one month statistic.
 //If the requested month is this month, it will get the data from 01 until yesterday (D-1).
-func (dbc *DB) GetOneMonthStatistic() string {
-	resultInfo := ""
-
-	var StationName string
-	var yr string
-	var mo string
-	var dt string
-	var hr string
-	var mi string
-	var value float64
-	//	var cntExisting int
-
-	//Fixed array. To generate hourly
-	ArrayHour := []string{"00", "01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11", "12", "13", "14", "15", "16", "17", "18", "19", "20", "21", "22", "23"}
-
-	//Flexible array, by using splices
-	WhereCondition := []string{}
-
-	minTemp := 9999.99
-	maxTemp := -9999.99
-	totalTemp := 0.00
-	totalReadings := 0.00
-	EvenPosStartValue := 0.00
-	EvenPosEndValue := 0.00
-
-	centerData := 0
-
-	//Minimum Temperature Date/Time and StationName
-	minTempDateTimeAndStation := ""
-	//Maximum Temperature Date/Time and StationName
-	maxTempDateTimeAndStation := ""
-
+func (dbc *DB) GetOneMonthStatistic(ctx context.Context) error {
 	strYearMonthInput := GetUserInput(fmt.Sprintf("\nYour input (YYYY-MM): "))
-	arrYearMonth := strings.Split(strYearMonthInput, "-")
-	intYearInp, _ := strconv.Atoi(arrYearMonth[0])
-	intMonthInp, _ := strconv.Atoi(arrYearMonth[1])
-
-	//Year Month is not valid.
-	if (regexp.MustCompile(`\d{4}-\d{2}`).MatchString(strYearMonthInput) == false) || (intMonthInp < 1 && intMonthInp > 13) {
-		resultInfo = fmt.Sprintf("The inputed Data '%s' is not match with YYYY-MM format.\n", strYearMonthInput)
-	} else {
-
-		strYearMonthMinData := strings.Split(EarliestDataAvail, "-")
-		intYearMinData, _ := strconv.Atoi(strYearMonthMinData[0])
-		intMonthMinData, _ := strconv.Atoi(strYearMonthMinData[1])
-
-		//Year/Month inputted is less than the minimum data available from API
-		if (intYearInp < intYearMinData) || (intYearInp == intYearMinData && intMonthInp < intMonthMinData) {
-			resultInfo = fmt.Sprintf("The inputted Year/Month is less than the minimum data available from API (%v).", EarliestDataAvail)
-		} else {
-			//Create the Inputted Date/Time object, the created object will without UTC/Timezone
-			InputDate, _ := time.Parse(strStandardFormat, strYearMonthInput+"-01")
-			//Last Day of Month - Add 1 month and minus 1 day.
-			LastDayOfMonth := InputDate.AddDate(0, 1, -1)
-			LastDate := string([]rune(LastDayOfMonth.Format(strStandardFormat))[8:10])
-			LastDateInt, _ := strconv.Atoi(LastDate)
-			reqDateStr := ""
-			//Create the Date object of the EarliestDataAvail
-			EarliestDate, _ := time.Parse(strStandardFormat, EarliestDataAvail)
-
-			//Unix Value for Earliest Date.
-			EarliestDateUnix := EarliestDate.Unix()
-
-			for d := 1; d <= LastDateInt; d++ {
-				if d < 10 {
-					reqDateStr = fmt.Sprintf("%v-0%v", strYearMonthInput, d)
-				} else {
-					reqDateStr = fmt.Sprintf("%v-%v", strYearMonthInput, d)
-				}
-				reqDate, _ := time.Parse(strStandardFormat, reqDateStr)
-				reqDateUnix := reqDate.Unix()
-				if reqDateUnix > EarliestDateUnix {
-					_ = dbc.GetOneDayStatistic(reqDateStr)
-				}
-			}
-
-			StationID := dbc.GetChoosenStation()
-
-			//Set the Where condition
-			WhereCondition = append(WhereCondition, fmt.Sprintf("yr ='%v'", intYearInp))
-			if intMonthInp < 10 {
-				WhereCondition = append(WhereCondition, fmt.Sprintf("mo ='0%v'", intMonthInp))
-			} else {
-				WhereCondition = append(WhereCondition, fmt.Sprintf("mo ='%v'", intMonthInp))
-			}
-
-			WhereCondition = append(WhereCondition, fmt.Sprintf("hr IN ('%s')", strings.Join(ArrayHour[:], "','")))
-			WhereCondition = append(WhereCondition, "mi ='00'")
-			if len(StationID) > 0 {
-				WhereCondition = append(WhereCondition, fmt.Sprintf("r.station_id ='%v'", StationID))
-			}
-
-			StartExecutionTime := time.Now()
-			StrQueryCnt := fmt.Sprintf("SELECT count(value) AS scalarRes FROM readings r INNER JOIN stations s ON s.station_id = r.station_id WHERE %v GROUP BY s.station_name", strings.Join(WhereCondition[:], " AND "))
-			GetTotalRows, _ := strconv.Atoi(dbc.GetScalar(StrQueryCnt))
-			if GetTotalRows > 0 {
-				centerData = int(GetTotalRows / 2)
-				EvenPosStart := centerData
-				EvenPosEnd := centerData + 1
-
-				StrQuery := "SELECT s.station_name, yr, mo, dt, hr, mi, value FROM readings r INNER JOIN stations s ON s.station_id = r.station_id WHERE " + strings.Join(WhereCondition[:], " AND ") + " ORDER BY r.value, s.station_name, yr, mo, dt, hr, mi"
-				rows, _ := dbc.Query(StrQuery)
-				for rows.Next() {
-					rows.Scan(&StationName, &yr, &mo, &dt, &hr, &mi, &value)
-					if value > maxTemp {
-						maxTemp = value
-
-						//Maximum Temperature Date/Time and StationName
-						maxTempDateTimeAndStation = fmt.Sprintf("  - %v-%v-%v %v:%v -> %v\n", yr, mo, dt, hr, mi, StationName)
-					} else if value == maxTemp {
-						maxTempDateTimeAndStation = fmt.Sprintf("%v  - %v-%v-%v %v:%v -> %v\n", maxTempDateTimeAndStation, yr, mo, dt, hr, mi, StationName)
-					}
-
-					if value < minTemp {
-						minTemp = value
-
-						//Minimum Temperature Date/Time and StationName
-						minTempDateTimeAndStation = fmt.Sprintf("  - %v-%v-%v %v:%v -> %v\n", yr, mo, dt, hr, mi, StationName)
-					} else if value == minTemp {
-						minTempDateTimeAndStation = fmt.Sprintf("%v  - %v-%v-%v %v:%v -> %v\n", minTempDateTimeAndStation, yr, mo, dt, hr, mi, StationName)
-					}
-
-					totalTemp += value
-					totalReadings++
-					if EvenPosStart == int(totalReadings) {
-						EvenPosStartValue = value
-					}
-					if EvenPosEnd == int(totalReadings) {
-						EvenPosEndValue = value
-					}
-				}
-				fmt.Printf("\nTotal Readings                   : %v", totalReadings)
-				fmt.Printf("\nAverange Readings                : %.2f", (totalTemp / totalReadings))
-				//Is Even, so we need to get the average of the two of the center data
-				if (GetTotalRows % 2) == 0 {
-					fmt.Printf("\nMean Readings                    : %.2f", (EvenPosStartValue+EvenPosEndValue)/2)
-				} else {
-					//Is ODD, just get the center/middle data
-					fmt.Printf("\nMean Readings                    : %.2f", EvenPosEndValue)
-				}
-
-				fmt.Printf("\nMinimum Temperature              : %v", minTemp)
-				fmt.Printf("\nMinimum Temperature Occurence(s) : ")
-				fmt.Printf("\n%v", minTempDateTimeAndStation)
-				fmt.Printf("\nMaximum Temperature              : %v", maxTemp)
-				fmt.Printf("\nMaximum Temperature Occurence(s) : ")
-				fmt.Printf("\n%v", maxTempDateTimeAndStation)
-				EndExecutionTime := time.Now()
-				TimeNeeded := EndExecutionTime.Sub(StartExecutionTime)
-				fmt.Printf("\nTime Needed                      : %v", TimeNeeded)
-
-			}
-		}
+	StationID, err := dbc.GetChoosenStation()
+	if err != nil {
+		return err
 	}
-	return resultInfo
+	return dbc.PrintOneMonthStatistic(ctx, strYearMonthInput, StationID)
 }
 
-//GetAllDataStatistic a function to get the hourly data
-func (dbc *DB) GetAllDataStatistic() string {
-	resultInfo := ""
-	WhereCondition := ""
-	var StationName string
-	var yr string
-	var mo string
-	var dt string
-	var hr string
-	var mi string
-	var value float64
-
-	minTemp := 9999.99
-	maxTemp := -9999.99
-	totalTemp := 0.00
-	totalReadings := 0.00
-	centerData := 0
-
-	//Minimum Temperature Date/Time and StationName
-	minTempDateTimeAndStation := ""
-	//Maximum Temperature Date/Time and StationName
-	maxTempDateTimeAndStation := ""
-
-	StationID := dbc.GetChoosenStation()
-
-	//Set the Where condition
-	if len(StationID) > 0 {
-		WhereCondition = fmt.Sprintf(" WHERE r.station_id ='%v' ", StationID)
-	}
-	StartExecutionTime := time.Now()
-
-	StrQueryCnt := fmt.Sprintf("SELECT count(value) AS scalarRes FROM readings r INNER JOIN stations s ON s.station_id = r.station_id %v ", WhereCondition)
-	GetTotalRows, _ := strconv.Atoi(dbc.GetScalar(StrQueryCnt))
-
-	if GetTotalRows > 0 {
-		centerData = int(GetTotalRows / 2)
-		EvenPosStart := centerData
-		EvenPosEnd := centerData + 1
-		EvenPosStartValue := 0.00
-		EvenPosEndValue := 0.00
-
-		StrQuery := fmt.Sprintf("SELECT s.station_name, yr, mo, dt, hr, mi, value FROM readings r INNER JOIN stations s ON s.station_id = r.station_id %v ORDER BY r.value, s.station_name, yr, mo, dt, hr, mi", WhereCondition)
-		rows, _ := dbc.Query(StrQuery)
-
-		for rows.Next() {
-			rows.Scan(&StationName, &yr, &mo, &dt, &hr, &mi, &value)
-			if value > maxTemp {
-				maxTemp = value
-
-				//Maximum Temperature Date/Time and StationName
-				maxTempDateTimeAndStation = fmt.Sprintf("  - %v-%v-%v %v:%v -> %v\n", yr, mo, dt, hr, mi, StationName)
-			} else if value == maxTemp {
-				maxTempDateTimeAndStation = fmt.Sprintf("%v  - %v-%v-%v %v:%v -> %v\n", maxTempDateTimeAndStation, yr, mo, dt, hr, mi, StationName)
-			}
-
-			if value < minTemp {
-				minTemp = value
+//PrintOneMonthStatistic - print the hourly statistic for the month (YYYY-MM), empty StationID means ALL Stations.
+func (dbc *DB) PrintOneMonthStatistic(ctx context.Context, strYearMonthInput, StationID string) error {
+	query, err := NewMonthQuery(strYearMonthInput, StationID)
+	if err != nil {
+		return err
+	}
 
-				//Minimum Temperature Date/Time and StationName
-				minTempDateTimeAndStation = fmt.Sprintf("  - %v-%v-%v %v:%v -> %v\n", yr, mo, dt, hr, mi, StationName)
-			} else if value == minTemp {
-				minTempDateTimeAndStation = fmt.Sprintf("%v  - %v-%v-%v %v:%v -> %v\n", minTempDateTimeAndStation, yr, mo, dt, hr, mi, StationName)
-			}
+	//Year/Month inputted is less than the minimum data available from API
+	if query.DateTo < EarliestDataAvail {
+		return &InputError{Value: strYearMonthInput, Message: fmt.Sprintf("the minimum data available from API is %v", EarliestDataAvail), Err: ErrDateBeforeEarliest}
+	}
 
-			totalTemp += value
-			totalReadings++
-			if EvenPosStart == int(totalReadings) {
-				EvenPosStartValue = value
-			}
-			if EvenPosEnd == int(totalReadings) {
-				EvenPosEndValue = value
+	//Make sure every day of the month, from the EarliestDataAvail until yesterday, had the hourly data.
+	//The API calls of the whole month are given to the Scheduler together.
+	requests := []FetchRequest{}
+	reqDate, _ := time.Parse(strStandardFormat, query.DateFrom)
+	for reqDateStr := query.DateFrom; reqDateStr <= query.DateTo; reqDateStr = reqDate.Format(strStandardFormat) {
+		if reqDateStr >= EarliestDataAvail && ValidateInputDateMaxYesterday(reqDateStr) == true {
+			dayRequests, err := dbc.hourlyRequestsNeeded(reqDateStr, "")
+			if err != nil {
+				return err
 			}
+			requests = append(requests, dayRequests...)
 		}
-		fmt.Printf("\nTotal Readings                   : %v", totalReadings)
-		fmt.Printf("\nAverange Readings                : %.2f", (totalTemp / totalReadings))
-		//Is Even, so we need to get the average of the two of the center data
-		if (GetTotalRows % 2) == 0 {
-			fmt.Printf("\nMean Readings                    : %.2f", (EvenPosStartValue+EvenPosEndValue)/2)
-		} else {
-			//Is ODD, just get the center/middle data
-			fmt.Printf("\nMean Readings                    : %.2f", EvenPosEndValue)
+		reqDate = reqDate.AddDate(0, 0, 1)
+	}
+	if len(requests) > 0 {
+		fmt.Printf("\nCalling the API for %v hourly data of %v\n", len(requests), strYearMonthInput)
+		if err := dbc.fetchHourly(ctx, requests, false); err != nil {
+			return err
 		}
+	}
 
-		fmt.Printf("\nMinimum Temperature              : %v", minTemp)
-		fmt.Printf("\nMinimum Temperature Occurence(s) : ")
-		fmt.Printf("\n%v", minTempDateTimeAndStation)
-		fmt.Printf("\nMaximum Temperature              : %v", maxTemp)
-		fmt.Printf("\nMaximum Temperature Occurence(s) : ")
-		fmt.Printf("\n%v", maxTempDateTimeAndStation)
-		EndExecutionTime := time.Now()
-		TimeNeeded := EndExecutionTime.Sub(StartExecutionTime)
-		fmt.Printf("\nTime Needed                      : %v", TimeNeeded)
+	stats, err := dbc.GetStatistics(query)
+	if err != nil {
+		return err
+	}
+	if stats.Count == 0 {
+		return errNoData(strYearMonthInput)
+	}
+	PrintStatistics(stats)
+	return nil
+}
 
+//GetAllDataStatistic a function to get the hourly data
+func (dbc *DB) GetAllDataStatistic() error {
+	StationID, err := dbc.GetChoosenStation()
+	if err != nil {
+		return err
 	}
+	return dbc.PrintAllDataStatistic(StationID)
+}
 
-	return resultInfo
+//PrintAllDataStatistic - print the statistic from all the saved data, empty StationID means ALL Stations.
+func (dbc *DB) PrintAllDataStatistic(StationID string) error {
+	stats, err := dbc.GetStatistics(NewAllDataQuery(StationID))
+	if err != nil {
+		return err
+	}
+	if stats.Count == 0 {
+		return errNoData("all the saved data")
+	}
+	PrintStatistics(stats)
+	return nil
 }
@@ -0,0 +1,184 @@
+package SGAirTemp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//sgtOffsetSeconds - Singapore Time is UTC+8 without daylight saving.
+const sgtOffsetSeconds = 8 * 60 * 60
+
+//SGTLocation - Singapore Time (UTC+8), the timezone of the timestamp returned by the API.
+var SGTLocation = time.FixedZone("SGT", sgtOffsetSeconds)
+
+//Granularity - which readings of the day are used for the statistic.
+type Granularity int
+
+const (
+	//GranularityHourly - only the reading on every hour (HH:00), 24 readings per station per day.
+	GranularityHourly Granularity = iota
+	//GranularityMinute - every reading saved, the API provides it for every minute.
+	GranularityMinute
+)
+
+//StatisticQuery struct - the date range, station and granularity of the statistic.
+type StatisticQuery struct {
+	//DateFrom - first date (YYYY-MM-DD) included, empty for no lower bound.
+	DateFrom string
+	//DateTo - last date (YYYY-MM-DD) included, empty for no upper bound.
+	DateTo string
+	//StationID - empty for ALL Stations.
+	StationID   string
+	Granularity Granularity
+	//Metric - the Metric name, empty for the Metric of the DB.
+	Metric string
+}
+
+//Occurrence struct - when and where the reading happened.
+type Occurrence struct {
+	StationID   string
+	StationName string
+	Timestamp   time.Time
+}
+
+//Statistics struct - the result of the statistic for a StatisticQuery.
+type Statistics struct {
+	Query          StatisticQuery
+	Count          int
+	Mean           float64
+	Median         float64
+	Min            float64
+	MinOccurrences []Occurrence
+	Max            float64
+	MaxOccurrences []Occurrence
+	Elapsed        time.Duration
+}
+
+//NewDayQuery - the hourly (24 hours) statistic query of the date.
+func NewDayQuery(dateVal, StationID string) StatisticQuery {
+	return StatisticQuery{DateFrom: dateVal, DateTo: dateVal, StationID: StationID, Granularity: GranularityHourly}
+}
+
+//NewFullDayQuery - the every minutes statistic query of the date.
+func NewFullDayQuery(dateVal, StationID string) StatisticQuery {
+	return StatisticQuery{DateFrom: dateVal, DateTo: dateVal, StationID: StationID, Granularity: GranularityMinute}
+}
+
+//NewMonthQuery - the hourly statistic query of the month (YYYY-MM).
+func NewMonthQuery(strYearMonth, StationID string) (query StatisticQuery, err error) {
+	strYearMonth = strings.TrimSpace(strYearMonth)
+	if regexp.MustCompile(`^\d{4}-\d{2}$`).MatchString(strYearMonth) == false {
+		return query, &InputError{Value: strYearMonth, Message: "please use YYYY-MM format", Err: ErrInvalidDateFormat}
+	}
+	FirstDayOfMonth, err := time.Parse(strStandardFormat, strYearMonth+"-01")
+	if err != nil {
+		return query, &InputError{Value: strYearMonth, Message: "please use YYYY-MM format", Err: ErrInvalidDate}
+	}
+	//Last Day of Month - Add 1 month and minus 1 day.
+	LastDayOfMonth := FirstDayOfMonth.AddDate(0, 1, -1)
+	query = StatisticQuery{
+		DateFrom:    FirstDayOfMonth.Format(strStandardFormat),
+		DateTo:      LastDayOfMonth.Format(strStandardFormat),
+		StationID:   StationID,
+		Granularity: GranularityHourly,
+	}
+	return query, nil
+}
+
+//NewAllDataQuery - the statistic query of all the saved data.
+func NewAllDataQuery(StationID string) StatisticQuery {
+	return StatisticQuery{StationID: StationID, Granularity: GranularityMinute}
+}
+
+//Filter - the aggregation Filter of the query.
+func (q StatisticQuery) Filter() Filter {
+	filter := Filter{DateFrom: q.DateFrom, DateTo: q.DateTo, Metric: q.Metric}
+	if q.Granularity == GranularityHourly {
+		filter.Minutes = []int{0}
+	}
+	if len(q.StationID) > 0 {
+		filter.StationIDs = []string{q.StationID}
+	}
+	return filter
+}
+
+//GetStatistics - calculate the statistic of the saved readings for the query, nothing is printed or fetched from the API.
+func (dbc *DB) GetStatistics(query StatisticQuery) (stats Statistics, err error) {
+	if len(query.Metric) == 0 {
+		query.Metric = dbc.metric().Name
+	}
+	stats, err = dbc.Aggregate(query.Filter())
+	stats.Query = query
+	return stats, err
+}
+
+//PrintStatistics - print the statistic to the console, with the label and unit of the Metric.
+func PrintStatistics(stats Statistics) {
+	metric := describeMetric(stats.Query.Metric)
+	//The width of the longest caption.
+	width := len("Minimum " + metric.Label + " Occurence(s) ")
+	if width < 33 {
+		width = 33
+	}
+	caption := "\n%-" + strconv.Itoa(width) + "s: "
+
+	fmt.Printf(caption+"%v", "Total Readings", stats.Count)
+	fmt.Printf(caption+"%.2f %v", "Averange Readings", stats.Mean, metric.Unit)
+	fmt.Printf(caption+"%.2f %v", "Median Readings", stats.Median, metric.Unit)
+	fmt.Printf(caption+"%v %v", "Minimum "+metric.Label, stats.Min, metric.Unit)
+	fmt.Printf(caption, "Minimum "+metric.Label+" Occurence(s)")
+	fmt.Printf("\n%v", formatOccurrences(stats.MinOccurrences))
+	fmt.Printf(caption+"%v %v", "Maximum "+metric.Label, stats.Max, metric.Unit)
+	fmt.Printf(caption, "Maximum "+metric.Label+" Occurence(s)")
+	fmt.Printf("\n%v", formatOccurrences(stats.MaxOccurrences))
+	fmt.Printf(caption+"%v", "Time Needed", stats.Elapsed)
+}
+
+//formatOccurrences - one line for every occurrence: "  - YYYY-MM-DD HH:mm -> StationName"
+func formatOccurrences(occurrences []Occurrence) string {
+	strOccurrences := ""
+	for _, occ := range occurrences {
+		strOccurrences += fmt.Sprintf("  - %v -> %v\n", occ.Timestamp.Format("2006-01-02 15:04"), occ.StationName)
+	}
+	return strOccurrences
+}
+
+//printStatisticReadings - print every readings used by the statistic, ordered by the value.
+func (dbc *DB) printStatisticReadings(filter Filter) error {
+	type statisticReading struct {
+		StationName string
+		ts          int64
+		value       float64
+	}
+
+	//Get the maximum length of the all the Station's name.
+	MaxStationNameLen, err := dbc.maxStationNameLen()
+	if err != nil {
+		return err
+	}
+	MaxStationNameLenInt, _ := strconv.Atoi(MaxStationNameLen)
+
+	readings := []statisticReading{}
+	err = dbc.queryReadings(filter, dbc.dbFilter(filter).valueOrder(), Page{}, func(StationID, StationName string, ts int64, value float64) error {
+		readings = append(readings, statisticReading{StationName: StationName, ts: ts, value: value})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	//The derived values are not in the order of the saved readings.
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].value < readings[j].value
+	})
+
+	fmt.Printf("\n%"+MaxStationNameLen+"s"+" | %16s | %s\n", "StationName", "Date/Time", "Value")
+	fmt.Printf("%s\n", strings.Repeat("=", MaxStationNameLenInt+27))
+	for _, rd := range readings {
+		fmt.Printf("%"+MaxStationNameLen+"s"+" | %v | %v\n", rd.StationName, formatSGT(rd.ts), rd.value)
+	}
+	return nil
+}
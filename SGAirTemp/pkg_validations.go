@@ -2,7 +2,6 @@ package SGAirTemp
 
 import (
 	"fmt"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -10,7 +9,7 @@ import (
 )
 
 //CheckInputDate - a function to validated the input date
-func CheckInputDate(StrDate string) (validatedDate string) {
+func CheckInputDate(StrDate string) (validatedDate string, err error) {
 	currentTime := time.Now()
 	curDateTimeStr := currentTime.Format("2006-01-02T15:04:05")
 	curDateTimeArr := strings.Split(curDateTimeStr, "T")
@@ -28,11 +27,10 @@ func CheckInputDate(StrDate string) (validatedDate string) {
 	}
 
 	//Regex Format for YYYY-MM-DD
-	datePattern := regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+	datePattern := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
 
 	if datePattern.MatchString(validatedDate) == false {
-		fmt.Printf("The inputed Data '%s' is not match with YYYY-MM-DD format.\n", validatedDate)
-		os.Exit(1)
+		return validatedDate, &InputError{Value: validatedDate, Message: "please use YYYY-MM-DD format", Err: ErrInvalidDateFormat}
 	}
 
 	//Split the DateVal, so we can get the individual year, month and date.
@@ -41,14 +39,12 @@ func CheckInputDate(StrDate string) (validatedDate string) {
 	//Ensure the date is greater than 1970, for Unix time.
 	testYear, _ := strconv.Atoi(arrDate[0])
 	if testYear < 1970 {
-		fmt.Printf("Please enter the year above or equals 1970.\n")
-		os.Exit(1)
+		return validatedDate, &InputError{Value: validatedDate, Message: "please enter the year above or equals 1970", Err: ErrInvalidDate}
 	}
 
 	//Check if the date inputted is a valid date.
 	if Checkdate(arrDate[1], arrDate[2], arrDate[0]) == false {
-		fmt.Printf("The inputed Data '%s' not a valid date. Please check again.\n", validatedDate)
-		os.Exit(1)
+		return validatedDate, &InputError{Value: validatedDate, Err: ErrInvalidDate}
 	}
 
 	//Create the Inputted Date/Time object, the created object will without UTC/Timezone
@@ -64,15 +60,14 @@ func CheckInputDate(StrDate string) (validatedDate string) {
 	EarliestDateUnix := EarliestDate.Unix()
 
 	if InputDateUnix < EarliestDateUnix {
-		fmt.Printf("The earliest Data we had for this API is: '%s'\n Please refer to https://data.gov.sg/dataset/realtime-weather-readings under the 'Coverage'", EarliestDataAvail)
-		os.Exit(1)
+		return validatedDate, &InputError{Value: validatedDate, Message: fmt.Sprintf("the earliest Data we had for this API is '%s', please refer to https://data.gov.sg/dataset/realtime-weather-readings under the 'Coverage'", EarliestDataAvail), Err: ErrDateBeforeEarliest}
 	}
 
-	return validatedDate
+	return validatedDate, nil
 }
 
 //CheckInputTime - a function to validated the input time
-func CheckInputTime(StrTime string) (validatedTime string) {
+func CheckInputTime(StrTime string) (validatedTime string, err error) {
 	currentTime := time.Now()
 	curDateTimeStr := currentTime.Format("2006-01-02T15:04:05")
 	curDateTimeArr := strings.Split(curDateTimeStr, "T")
@@ -89,15 +84,19 @@ func CheckInputTime(StrTime string) (validatedTime string) {
 		validatedTime = curTimeStr
 	}
 
+	//Regex Format for HH:mm
+	if regexp.MustCompile(`^\d{2}:\d{2}$`).MatchString(validatedTime) == false {
+		return validatedTime, &InputError{Value: validatedTime, Message: "please use HH:mm format", Err: ErrInvalidTime}
+	}
+
 	arrTime := strings.Split(validatedTime, ":")
 	intHour, _ := strconv.Atoi(arrTime[0])
 	intMin, _ := strconv.Atoi(arrTime[1])
 	if intHour < 0 || intHour > 23 || intMin < 0 || intMin > 59 {
-		fmt.Printf("The inputed Data '%s' not a valid time. Please check again.\n", validatedTime)
-		os.Exit(1)
+		return validatedTime, &InputError{Value: validatedTime, Err: ErrInvalidTime}
 	}
 
-	return validatedTime
+	return validatedTime, nil
 }
 
 //ValidateDateTimeLessThanNow - a function to validated the input date
@@ -152,3 +151,8 @@ func ValidateInputDateMaxYesterday(ValDate string) (validatedResult bool) {
 	return NowDateUnix > InputDateUnix
 
 }
+
+//errNotLaterThanYesterday - the ErrFutureDate for the date which must be not later than yesterday.
+func errNotLaterThanYesterday(ValDate string) error {
+	return &InputError{Value: ValDate, Message: "must be not later than yesterday", Err: ErrFutureDate}
+}
@@ -0,0 +1,408 @@
+package SGAirTemp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+//MetricWBGT - the Wet Bulb Globe Temperature, from the v2 API (not the environment/NAME API of the other Metric), saved on the readings table.
+var MetricWBGT = Metric{Name: "wbgt", Label: "WBGT", Unit: "deg C"}
+
+//The heat stress categories of the WBGT, from the lowest.
+const (
+	HeatStressLow      = "low"
+	HeatStressModerate = "moderate"
+	HeatStressHigh     = "high"
+)
+
+//HeatStressCategories - all the heat stress categories, from the lowest.
+func HeatStressCategories() []string {
+	return []string{HeatStressLow, HeatStressModerate, HeatStressHigh}
+}
+
+//HeatStressCategory - the heat stress category of the WBGT (deg C) by the NEA thresholds, used when the API gives no category.
+func HeatStressCategory(wbgt float64) string {
+	if wbgt >= 33 {
+		return HeatStressHigh
+	} else if wbgt >= 31 {
+		return HeatStressModerate
+	}
+	return HeatStressLow
+}
+
+//wbgtQuery - the v2 API query of the WBGT, the key of the response on the ResponseStore starts with it.
+const wbgtQuery = "real-time/api/weather?api=wbgt"
+
+//WBGTStation struct - the station of the WBGT reading, the v2 API gives the location as string.
+type WBGTStation struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+//WBGTLocation struct - the location of the WBGT station.
+type WBGTLocation struct {
+	Latitude  json.Number `json:"latitude"`
+	Longitude json.Number `json:"longitude"`
+}
+
+//WBGTReading struct - the WBGT and heat stress category of the station.
+type WBGTReading struct {
+	Station    WBGTStation  `json:"station"`
+	Location   WBGTLocation `json:"location"`
+	WBGT       json.Number  `json:"wbgt"`
+	HeatStress string       `json:"heatStress"`
+}
+
+//WBGTRecord struct - the WBGT readings of every station at the DateTime (RFC3339).
+type WBGTRecord struct {
+	DateTime string `json:"datetime"`
+	Item     struct {
+		IsStationData bool          `json:"isStationData"`
+		Type          string        `json:"type"`
+		Readings      []WBGTReading `json:"readings"`
+	} `json:"item"`
+	UpdatedTimestamp string `json:"updatedTimestamp"`
+}
+
+//WBGTResponse struct - response body of the v2 WBGT API, the next page is requested with the PaginationToken until it is empty.
+type WBGTResponse struct {
+	Code     int    `json:"code"`
+	ErrorMsg string `json:"errorMsg"`
+	Data     struct {
+		Records         []WBGTRecord `json:"records"`
+		PaginationToken string       `json:"paginationToken,omitempty"`
+	} `json:"data"`
+}
+
+//GetWBGT - call the v2 WBGT API for the Date (YYYY-MM-DD) and Time (HH:mm), empty Time for the FULL day.
+//All the pages are fetched, the records of every page are returned in one response.
+func (c *APIClient) GetWBGT(ctx context.Context, ValDate, ValTime string) (response WBGTResponse, err error) {
+	if len(ValDate) == 0 {
+		return response, &InputError{Message: "please provide Date and Time", Err: ErrInvalidDateFormat}
+	}
+	dateCondition := ValDate
+	if len(ValTime) > 0 {
+		dateCondition = ValDate + "T" + ValTime + ":00"
+	}
+
+	paginationToken := ""
+	for {
+		query := wbgtQuery + "&date=" + url.QueryEscape(dateCondition)
+		if len(paginationToken) > 0 {
+			query += "&paginationToken=" + url.QueryEscape(paginationToken)
+		}
+		strAPICall := c.v2BaseURL() + "/" + query
+		body, statusCode, err := c.getStored(ctx, "v2/"+query, strAPICall, ValDate)
+		if err != nil {
+			return response, err
+		}
+
+		page := WBGTResponse{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return response, &APIError{URL: strAPICall, StatusCode: statusCode, Kind: ErrAPIUnavailable, Err: err}
+		}
+		if page.Code != 0 {
+			return response, &APIError{URL: strAPICall, StatusCode: statusCode, Kind: ErrAPIUnavailable, Err: fmt.Errorf("code %v: %v", page.Code, page.ErrorMsg)}
+		}
+		response.Data.Records = append(response.Data.Records, page.Data.Records...)
+		if len(page.Data.PaginationToken) == 0 || len(page.Data.Records) == 0 {
+			break
+		}
+		paginationToken = page.Data.PaginationToken
+	}
+
+	if len(response.Data.Records) == 0 {
+		return response, &APIError{URL: c.v2BaseURL() + "/" + wbgtQuery + "&date=" + url.QueryEscape(dateCondition), StatusCode: 200, Kind: ErrAPIEmptyBody}
+	}
+	return response, nil
+}
+
+//CallWBGTAPIAndSave - call the v2 WBGT API and save the stations, readings and heat stress to the Database, return the total readings saved.
+func (dbc *DB) CallWBGTAPIAndSave(ctx context.Context, ValDate, ValTime string) (int, error) {
+	response, err := dbc.apiClient().GetWBGT(ctx, ValDate, ValTime)
+	if err != nil {
+		return 0, err
+	}
+	return dbc.SaveWBGTResponse(response)
+}
+
+//SaveWBGTResponse - save the stations, the WBGT readings and the heat stress of the response in a single transaction, return the total readings saved.
+func (dbc *DB) SaveWBGTResponse(response WBGTResponse) (int, error) {
+	tx, err := dbc.Begin()
+	if err != nil {
+		return 0, dbError("begin ingestion", err)
+	}
+	defer tx.Rollback()
+
+	rw, err := newResponseWriter(tx)
+	if err != nil {
+		return 0, err
+	}
+	defer rw.Close()
+
+	totalSaved, err := saveWBGTResponse(tx, rw, response)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, dbError("commit ingestion", err)
+	}
+	return totalSaved, nil
+}
+
+//sqlInsertWBGTStation - the WBGT station is only added when it is not saved yet, the name and location of the weather API are kept.
+const sqlInsertWBGTStation = "INSERT OR IGNORE INTO stations (station_id, station_name, loc_latitude, loc_longitude) VALUES (?, ?, ?, ?)"
+
+//saveWBGTResponse - save the WBGT as the readings of MetricWBGT with the responseWriter, and insert the new stations and upsert the heat stress on the transaction.
+func saveWBGTResponse(tx *sql.Tx, rw *responseWriter, response WBGTResponse) (int, error) {
+	weatherResponse, heatStress, err := wbgtWeatherResponse(response)
+	if err != nil {
+		return 0, err
+	}
+
+	stmtStation, err := tx.Prepare(sqlInsertWBGTStation)
+	if err != nil {
+		return 0, dbError("prepare station insert", err)
+	}
+	defer stmtStation.Close()
+	for _, st := range weatherResponse.Metadata.Station {
+		if _, err := stmtStation.Exec(st.StationID, st.StationName, st.Location.Latitude, st.Location.Longitude); err != nil {
+			return 0, dbError("insert station", err)
+		}
+	}
+	//The stations are saved, only the readings are left to the responseWriter (its upsert would replace the station of the weather API).
+	weatherResponse.Metadata.Station = nil
+	totalSaved, err := rw.Save(MetricWBGT, weatherResponse)
+	if err != nil {
+		return 0, err
+	}
+
+	stmtHeatStress, err := tx.Prepare("INSERT INTO heat_stress (station_id, ts, ts_sgt, category) VALUES (?, ?, ?, ?) " +
+		"ON CONFLICT (station_id, ts) DO UPDATE SET ts_sgt = excluded.ts_sgt, category = excluded.category")
+	if err != nil {
+		return 0, dbError("prepare heat stress upsert", err)
+	}
+	defer stmtHeatStress.Close()
+	for _, hs := range heatStress {
+		if _, err := stmtHeatStress.Exec(hs.StationID, hs.Timestamp.Unix(), hs.Timestamp.In(SGTLocation).Format(time.RFC3339), hs.Category); err != nil {
+			return 0, dbError("upsert heat stress", err)
+		}
+	}
+	return totalSaved, nil
+}
+
+//heatStressReading - the heat stress category of the station at the time.
+type heatStressReading struct {
+	StationID string
+	Timestamp time.Time
+	Category  string
+}
+
+//wbgtWeatherResponse - the WBGT response in the shape of the WeatherResponse (every station once), and the heat stress of every reading.
+func wbgtWeatherResponse(response WBGTResponse) (WeatherResponse, []heatStressReading, error) {
+	weatherResponse := WeatherResponse{Metadata: Metadata{Station: []Station{}, ReadingUnit: MetricWBGT.Unit}, Items: []WeatherData{}}
+	heatStress := []heatStressReading{}
+	savedStations := map[string]bool{}
+
+	for _, record := range response.Data.Records {
+		ts, err := time.Parse(time.RFC3339, record.DateTime)
+		if err != nil {
+			return weatherResponse, heatStress, &InputError{Value: record.DateTime, Message: "reading timestamp is not RFC3339", Err: ErrInvalidDateFormat}
+		}
+		WeatherData := WeatherData{Timestamp: record.DateTime}
+		for _, rd := range record.Item.Readings {
+			wbgt, err := rd.WBGT.Float64()
+			if err != nil {
+				return weatherResponse, heatStress, &InputError{Value: rd.WBGT.String(), Message: "WBGT of " + rd.Station.ID + " is not a number", Err: ErrAPIUnavailable}
+			}
+			if savedStations[rd.Station.ID] == false {
+				savedStations[rd.Station.ID] = true
+				latitude, _ := rd.Location.Latitude.Float64()
+				longitude, _ := rd.Location.Longitude.Float64()
+				weatherResponse.Metadata.Station = append(weatherResponse.Metadata.Station, Station{StationID: rd.Station.ID, StationName: rd.Station.Name, Location: Location{Latitude: latitude, Longitude: longitude}})
+			}
+			WeatherData.Readings = append(WeatherData.Readings, Reading{StationID: rd.Station.ID, Value: wbgt})
+
+			category := strings.ToLower(strings.TrimSpace(rd.HeatStress))
+			if category != HeatStressLow && category != HeatStressModerate && category != HeatStressHigh {
+				category = HeatStressCategory(wbgt)
+			}
+			heatStress = append(heatStress, heatStressReading{StationID: rd.Station.ID, Timestamp: ts, Category: category})
+		}
+		weatherResponse.Items = append(weatherResponse.Items, WeatherData)
+	}
+	return weatherResponse, heatStress, nil
+}
+
+//HeatRiskQuery struct - the date range (YYYY-MM-DD, empty for no bound) and the station (empty for all) of the heat risk report.
+type HeatRiskQuery struct {
+	DateFrom  string
+	DateTo    string
+	StationID string
+}
+
+//HeatRiskStation struct - the station in the heat risk hour, with its highest WBGT of the hour.
+type HeatRiskStation struct {
+	StationID   string
+	StationName string
+	MaxWBGT     float64
+}
+
+//HeatRiskHour struct - the stations with the category as their highest heat stress of the hour (SGT).
+type HeatRiskHour struct {
+	Hour     time.Time
+	Stations []HeatRiskStation
+}
+
+//HeatRiskCategory struct - every hour with at least one station in the category, StationHours is the total of the stations of all the hours.
+type HeatRiskCategory struct {
+	Category     string
+	StationHours int
+	Hours        []HeatRiskHour
+}
+
+//HeatRiskStationSummary struct - the total hours of the station in every category.
+type HeatRiskStationSummary struct {
+	StationID   string
+	StationName string
+	Hours       map[string]int
+}
+
+//HeatRiskReport struct - the heat risk of the query, one HeatRiskCategory for every category (from the lowest) and the summary of every station.
+type HeatRiskReport struct {
+	Query      HeatRiskQuery
+	Categories []HeatRiskCategory
+	Stations   []HeatRiskStationSummary
+	Elapsed    time.Duration
+}
+
+//GetHeatRiskReport - the heat risk of every station and hour, the station is counted on its highest heat stress category of the hour.
+func (dbc *DB) GetHeatRiskReport(query HeatRiskQuery) (report HeatRiskReport, err error) {
+	var StationID string
+	var StationName string
+	var hour int64
+	var rank int
+	var maxWBGT float64
+
+	StartExecutionTime := time.Now()
+	report.Query = query
+	WhereCondition := []string{}
+	args := []interface{}{MetricWBGT.Name}
+	if len(query.DateFrom) > 0 {
+		DateFrom, err := sgtDayStart(query.DateFrom)
+		if err != nil {
+			return report, err
+		}
+		WhereCondition = append(WhereCondition, "h.ts >= ?")
+		args = append(args, DateFrom.Unix())
+	}
+	if len(query.DateTo) > 0 {
+		DateTo, err := sgtDayStart(query.DateTo)
+		if err != nil {
+			return report, err
+		}
+		WhereCondition = append(WhereCondition, "h.ts < ?")
+		args = append(args, DateTo.AddDate(0, 0, 1).Unix())
+	}
+	if len(query.StationID) > 0 {
+		WhereCondition = append(WhereCondition, "h.station_id = ?")
+		args = append(args, query.StationID)
+	}
+
+	whereSQL := ""
+	if len(WhereCondition) > 0 {
+		whereSQL = "WHERE " + strings.Join(WhereCondition, " AND ")
+	}
+	StrQuery := fmt.Sprintf("SELECT h.station_id, IFNULL(s.station_name, h.station_id), (h.ts + %v) / 3600 AS hour, "+
+		"MAX(CASE h.category WHEN 'high' THEN 2 WHEN 'moderate' THEN 1 ELSE 0 END), IFNULL(MAX(r.value), 0) "+
+		"FROM heat_stress h LEFT JOIN stations s ON s.station_id = h.station_id "+
+		"LEFT JOIN readings r ON r.metric = ? AND r.station_id = h.station_id AND r.ts = h.ts "+
+		"%v GROUP BY h.station_id, hour ORDER BY hour, 2", sgtOffsetSeconds, whereSQL)
+	rows, err := dbc.Query(StrQuery, args...)
+	if err != nil {
+		return report, dbError("query heat risk", err)
+	}
+	defer rows.Close()
+
+	categories := HeatStressCategories()
+	for _, category := range categories {
+		report.Categories = append(report.Categories, HeatRiskCategory{Category: category, Hours: []HeatRiskHour{}})
+	}
+	summaries := map[string]*HeatRiskStationSummary{}
+	for rows.Next() {
+		if err := rows.Scan(&StationID, &StationName, &hour, &rank, &maxWBGT); err != nil {
+			return report, dbError("query heat risk", err)
+		}
+		cat := &report.Categories[rank]
+		hourStart := time.Unix(hour*3600-sgtOffsetSeconds, 0).In(SGTLocation)
+		if len(cat.Hours) == 0 || cat.Hours[len(cat.Hours)-1].Hour.Equal(hourStart) == false {
+			cat.Hours = append(cat.Hours, HeatRiskHour{Hour: hourStart})
+		}
+		lastHour := &cat.Hours[len(cat.Hours)-1]
+		lastHour.Stations = append(lastHour.Stations, HeatRiskStation{StationID: StationID, StationName: StationName, MaxWBGT: maxWBGT})
+		cat.StationHours++
+
+		summary, ok := summaries[StationID]
+		if ok == false {
+			summary = &HeatRiskStationSummary{StationID: StationID, StationName: StationName, Hours: map[string]int{}}
+			summaries[StationID] = summary
+		}
+		summary.Hours[categories[rank]]++
+	}
+	if err := rows.Err(); err != nil {
+		return report, dbError("query heat risk", err)
+	}
+
+	for _, summary := range summaries {
+		report.Stations = append(report.Stations, *summary)
+	}
+	sort.Slice(report.Stations, func(i, j int) bool {
+		return report.Stations[i].StationName < report.Stations[j].StationName
+	})
+	report.Elapsed = time.Since(StartExecutionTime)
+	return report, nil
+}
+
+//PrintHeatRiskReport - print the hours of every station by the category, then the hours with the stations of the categories to list (all if empty).
+func PrintHeatRiskReport(report HeatRiskReport, listCategories []string) {
+	if len(report.Stations) == 0 {
+		fmt.Println("No WBGT reading saved for the period, please fetch the WBGT first.")
+		return
+	}
+	fmt.Printf("%9s | %-30s | %8s | %8s | %8s\n", "StationID", "StationName", "Low", "Moderate", "High")
+	fmt.Printf("%s\n", strings.Repeat("=", 76))
+	for _, st := range report.Stations {
+		fmt.Printf("%9s | %-30s | %8v | %8v | %8v\n", st.StationID, st.StationName, st.Hours[HeatStressLow], st.Hours[HeatStressModerate], st.Hours[HeatStressHigh])
+	}
+
+	for _, cat := range report.Categories {
+		if len(listCategories) > 0 && containsString(listCategories, cat.Category) == false {
+			continue
+		}
+		fmt.Printf("\n%v: %v station-hours in %v hours\n", strings.ToUpper(cat.Category[:1])+cat.Category[1:], cat.StationHours, len(cat.Hours))
+		for _, hr := range cat.Hours {
+			stations := []string{}
+			for _, st := range hr.Stations {
+				stations = append(stations, fmt.Sprintf("%v %v (%.1f)", st.StationID, st.StationName, st.MaxWBGT))
+			}
+			fmt.Printf("  - %v -> %v\n", hr.Hour.Format("2006-01-02 15:04"), strings.Join(stations, ", "))
+		}
+	}
+	fmt.Printf("\nTime Needed: %v\n", report.Elapsed)
+}
+
+//containsString - the value is one of the values.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package SGAirTemp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSaveWBGTResponseKeepsStations(t *testing.T) {
+	dbc := newTestDB(t)
+	client, _ := newMockClient(t, MockOptions{Stations: 2})
+	dbc.API = client
+	//The station saved by the weather API, with its own name and location.
+	saveTestReading(t, dbc, MetricAirTemperature, mockStations[0].StationID, "2024-05-01 12:00", 31)
+
+	totalSaved, err := dbc.CallWBGTAPIAndSave(context.Background(), "2024-05-01", "12:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totalSaved != 2 {
+		t.Errorf("WBGT readings saved = %v, want 2", totalSaved)
+	}
+	if cnt, err := dbc.GetScalar("SELECT count(*) FROM heat_stress"); err != nil || cnt != "2" {
+		t.Errorf("heat stress saved = %v, %v, want 2", cnt, err)
+	}
+
+	stations, _, err := dbc.Stations(Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]Station{}
+	for _, st := range stations {
+		byID[st.StationID] = st
+	}
+	if st := byID[mockStations[0].StationID]; st.StationName != "Station "+mockStations[0].StationID || st.Location.Latitude != 1.35 || st.Location.Longitude != 103.8 {
+		t.Errorf("weather station after the WBGT = %+v, want its saved name and location", st)
+	}
+	//The station only known by the WBGT is added.
+	if st := byID[mockStations[1].StationID]; st != mockStations[1] {
+		t.Errorf("WBGT station = %+v, want %+v", st, mockStations[1])
+	}
+}
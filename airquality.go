@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//runAirQuality - fetch and print the PSI / PM2.5 readings of the regions, and the region of every station.
+func runAirQuality(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("please provide the airquality action: fetch, stats or regions")
+	}
+	switch args[0] {
+	case "fetch":
+		return runAirQualityFetch(ctx, args[1:])
+	case "stats":
+		return runAirQualityStats(args[1:])
+	case "regions":
+		return runAirQualityRegions(args[1:])
+	}
+	return fmt.Errorf("unknown airquality action '%v', please choose fetch, stats or regions", args[0])
+}
+
+func runAirQualityFetch(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("airquality fetch")
+	sourceName := fs.String("source", SGAirTemp.SourcePSI.Name, "Air quality API: psi, pm25")
+	dateVal := fs.String("date", "", "Date to fetch (YYYY-MM-DD), empty for today")
+	dateTo := fs.String("to", "", "Last date to fetch (YYYY-MM-DD) for the FULL days from --date, empty for only --date")
+	timeVal := fs.String("time", "", "Time to fetch (HH:mm), empty for the FULL day")
+	applyAPI := apiFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	source, err := SGAirTemp.AirQualitySourceByName(*sourceName)
+	if err != nil {
+		return err
+	}
+	if len(*dateVal) == 0 {
+		*dateVal = time.Now().In(SGAirTemp.SGTLocation).Format("2006-01-02")
+	}
+	dates, err := dateRange(*dateVal, *dateTo)
+	if err != nil {
+		return err
+	}
+	if len(*timeVal) > 0 {
+		if len(dates) > 1 {
+			return errors.New("--time can not be used with --to")
+		}
+		validatedTime, err := SGAirTemp.CheckInputTime(*timeVal)
+		if err != nil {
+			return err
+		}
+		*timeVal = validatedTime
+	}
+
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	applyAPI(DBConn)
+
+	for _, d := range dates {
+		totalSaved, err := DBConn.CallAirQualityAPIAndSave(ctx, source, d, *timeVal)
+		if err != nil {
+			return fmt.Errorf("%v %v: %w", source.Label, d, err)
+		}
+		fmt.Printf("%v | %v | %6v readings\n", d, source.Label, totalSaved)
+	}
+	return nil
+}
+
+func runAirQualityStats(args []string) error {
+	fs, dbPath := newFlagSet("airquality stats")
+	readingType := fs.String("reading", SGAirTemp.DefaultReadingType, "Reading type: "+strings.Join(SGAirTemp.AirQualityReadingTypes(), ", "))
+	dateFrom := fs.String("from", "", "First date (YYYY-MM-DD), empty for no bound")
+	dateTo := fs.String("to", "", "Last date (YYYY-MM-DD), empty for no bound")
+	region := fs.String("region", "", "Region (national, central, east, north, south, west), empty for ALL regions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	validatedReadingType, err := SGAirTemp.CheckReadingType(*readingType)
+	if err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+
+	stats, err := DBConn.GetRegionStatistics(SGAirTemp.RegionStatisticQuery{ReadingType: validatedReadingType, DateFrom: *dateFrom, DateTo: *dateTo, Region: *region})
+	if err != nil {
+		return err
+	}
+	SGAirTemp.PrintStatistics(stats)
+	fmt.Println()
+	return nil
+}
+
+func runAirQualityRegions(args []string) error {
+	fs, dbPath := newFlagSet("airquality regions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+
+	stationRegions, err := DBConn.StationRegions()
+	if err != nil {
+		return err
+	}
+	if len(stationRegions) == 0 {
+		fmt.Println("No station saved yet, please fetch the readings first.")
+		return nil
+	}
+	fmt.Printf("%-7s | %9s | %-30s | %s\n", "Region", "StationID", "StationName", "Distance (km)")
+	fmt.Printf("%s\n", strings.Repeat("=", 70))
+	for _, sr := range stationRegions {
+		fmt.Printf("%-7s | %9s | %-30s | %6.2f\n", sr.Region, sr.StationID, sr.StationName, sr.DistanceKm)
+	}
+	return nil
+}
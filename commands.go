@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//defaultDBPath - the Sqlite database file used when --db is not provided.
+const defaultDBPath = "sg-airtemp.db"
+
+//defaultStoreDir - the directory of the raw API responses read by replay when --store is not provided, the other commands only save them with --store.
+const defaultStoreDir = "sg-airtemp-responses"
+
+//command struct - a sub command of the sgairtemp CLI.
+type command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(ctx context.Context, args []string) error
+}
+
+//commands - all the available sub commands, filled on init so the help command can refer to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"stations", "stations [--db FILE]", "Print Recorded Stations", runStations},
+		{"readings", "readings [--date YYYY-MM-DD] [--time HH:mm] [--metric NAME] [--db FILE]", "Print Recorded Readings Order by Stations", runReadings},
+		{"fetch", "fetch [--date YYYY-MM-DD] [--time HH:mm] [--metric NAME] [--api-url URL] [--api-v2-url URL] [--timeout D] [--retries N] [--store DIR] [--refresh] [--db FILE]", "Get the Readings (Temperature by default) from the API and save it (--date without --time fetch the FULL day)", runFetch},
+		{"stats", "stats day|month|all|fullday [--date YYYY-MM-DD] [--month YYYY-MM] [--station ID] [--metric NAME] [--workers N] [--rate N] [--api-url URL] [--api-v2-url URL] [--timeout D] [--retries N] [--store DIR] [--refresh] [--db FILE]", "Print the Statistic of the Readings (Temperature by default)", runStats},
+		{"backfill", "backfill [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--retry-empty] [--max-attempts N] [--status] [--metric NAME] [--workers N] [--rate N] [--api-url URL] [--api-v2-url URL] [--timeout D] [--retries N] [--store DIR] [--refresh] [--db FILE]", "Fetch every FULL day in the range not done yet, it can be interrupted and run again to resume", runBackfill},
+		{"replay", "replay [--store DIR] [--rebuild] [--db FILE]", "Save the readings from the raw API responses on --store, without calling the API", runReplay},
+		{"airquality", "airquality fetch|stats|regions [--source psi|pm25] [--date YYYY-MM-DD] [--to YYYY-MM-DD] [--time HH:mm] [--reading TYPE] [--from YYYY-MM-DD] [--region NAME] [--api-url URL] [--api-v2-url URL] [--timeout D] [--retries N] [--store DIR] [--refresh] [--db FILE]", "Fetch and print the PSI / PM2.5 readings of the regions, or the region of every station", runAirQuality},
+		{"forecast", "forecast fetch|verify [--source 2-hour|24-hour|4-day|all] [--date YYYY-MM-DD] [--to YYYY-MM-DD] [--time HH:mm] [--from YYYY-MM-DD] [--min-readings N] [--api-url URL] [--api-v2-url URL] [--timeout D] [--retries N] [--store DIR] [--refresh] [--db FILE]", "Fetch the weather forecasts, or verify the forecasted temperature against the observed readings", runForecast},
+		{"wbgt", "wbgt fetch|stats|heatrisk [--date YYYY-MM-DD] [--to YYYY-MM-DD] [--time HH:mm] [--from YYYY-MM-DD] [--station ID] [--category LIST] [--api-url URL] [--api-v2-url URL] [--timeout D] [--retries N] [--store DIR] [--refresh] [--db FILE]", "Fetch the WBGT and heat stress of the stations, or print their statistic or the heat risk report", runWBGT},
+		{"migrate", "migrate status|up|down [--to VERSION] [--db FILE]", "Show or apply the database schema migrations (down revert one version by default)", runMigrate},
+		{"export", "export readings|stats|stations [--format csv|json|ndjson|parquet|geojson] [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--date YYYY-MM-DD] [--station LIST] [--metric NAME] [--period day|month] [--hourly] [--out FILE] [--db FILE]", "Export the saved readings, or the statistic of every day/month, to stdout or a file (parquet: a directory partitioned by year/month), or the stations as GeoJSON", runExport},
+		{"import", "import [--dry-run] [--errors FILE] [--metric NAME] [--db FILE] FILE...", "Import the historical readings from CSV files (station_id, timestamp, value; .gz or - for stdin), skipping the duplicates and reporting the invalid lines", runImport},
+		{"interpolate", "interpolate [--lat LAT --lon LON | --grid [--bbox MINLAT,MINLON,MAXLAT,MAXLON] [--cell DEG] [--format csv|geojson|asc] [--out FILE]] [--date YYYY-MM-DD] [--time HH:mm] [--method idw|kriging] [--power P] [--tolerance D] [--metric NAME] [--db FILE]", "Estimate the reading at any location from the stations (inverse distance weighting or kriging), or the grid of a bounding box over Singapore", runInterpolate},
+		{"serve", "serve [--addr HOST:PORT] [--metric NAME] [--db FILE]", "Serve the saved stations, readings and statistics as a REST JSON API (GET /stations, /readings, /stats, /openapi.json) and the Prometheus metrics (/metrics)", runServe},
+		{"daemon", "daemon [--addr HOST:PORT] [--interval D] [--metric LIST] [--gap-threshold D] [--max-gap-days N] [--heartbeat FILE] [--check] [--max-age D] [--api-url URL] [--api-v2-url URL] [--timeout D] [--retries N] [--store DIR] [--refresh] [--db FILE]", "Poll the latest readings every minute until stopped, filling the gap after a downtime, serving the Prometheus metrics on /metrics and the REST API", runDaemon},
+		{"mockserver", "mockserver [--addr HOST:PORT] [--stations N] [--empty DATES] [--error DATES] [--error-status CODE] [--rate-limit-every N]", "Serve a fake data.gov.sg API with deterministic readings for offline testing", runMockServer},
+		{"interactive", "interactive [--metric NAME] [--db FILE]", "Choose the option from the numbered menu", runInteractive},
+		{"help", "help", "Print this help", runHelp},
+	}
+}
+
+//runCommand - find the sub command from the first argument and run it with the rest of the arguments.
+func runCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return errors.New("please provide the command")
+	}
+	for _, cmd := range commands {
+		if cmd.Name == args[0] {
+			return cmd.Run(ctx, args[1:])
+		}
+	}
+	printUsage(os.Stderr)
+	return fmt.Errorf("unknown command '%v'", args[0])
+}
+
+//printUsage - print the list of the commands.
+func printUsage(out io.Writer) {
+	fmt.Fprintf(out, "Usage: sgairtemp <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-12s %v\n", cmd.Name, cmd.Summary)
+		fmt.Fprintf(out, "  %-12s   sgairtemp %v\n", "", cmd.Usage)
+	}
+}
+
+//newFlagSet - create the flag set for the sub command with the common --db flag.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	dbPath := fs.String("db", defaultDBPath, "Sqlite database file")
+	return fs, dbPath
+}
+
+//metricFlag - add the --metric flag of the sub command reading or fetching the readings.
+//The returned function applies the flag to the DB, it fails for the unknown metric.
+func metricFlag(fs *flag.FlagSet) func(DBConn *SGAirTemp.DB) error {
+	metricName := fs.String("metric", SGAirTemp.DefaultMetric.Name, "Measurement type: "+strings.Join(SGAirTemp.MetricNames(), ", "))
+	return func(DBConn *SGAirTemp.DB) error {
+		metric, err := SGAirTemp.MetricByName(*metricName)
+		DBConn.Metric = metric
+		return err
+	}
+}
+
+//apiFlags - add the --api-url, --api-v2-url, --timeout, --retries, --store and --refresh flags of the sub command calling the API.
+//The returned function applies the flags to the DB API client.
+func apiFlags(fs *flag.FlagSet) func(DBConn *SGAirTemp.DB) {
+	apiURL := fs.String("api-url", SGAirTemp.DefaultAPIBaseURL, "Base URL of the API, ie: the URL printed by the mockserver command")
+	apiV2URL := fs.String("api-v2-url", SGAirTemp.DefaultAPIV2BaseURL, "Base URL of the v2 API (WBGT), ie: the v2 URL printed by the mockserver command")
+	timeout := fs.Duration("timeout", SGAirTemp.DefaultAPITimeout, "Timeout of every API call, 0 for no timeout")
+	retries := fs.Int("retries", SGAirTemp.DefaultAPIMaxRetries, "Total retries of the API call failed by network error, 429 or 5xx")
+	storeDir := fs.String("store", "", "Directory to save the raw API responses (ie: "+defaultStoreDir+"), empty to not save them")
+	refresh := fs.Bool("refresh", false, "Call the API even if the response is already on --store")
+	return func(DBConn *SGAirTemp.DB) {
+		DBConn.API = SGAirTemp.NewAPIClient(*timeout, *retries)
+		DBConn.API.BaseURL = *apiURL
+		DBConn.API.V2BaseURL = *apiV2URL
+		DBConn.API.Refresh = *refresh
+		if len(*storeDir) > 0 {
+			DBConn.API.Store = SGAirTemp.NewResponseStore(*storeDir)
+		}
+	}
+}
+
+//schedulerFlags - add the --workers and --rate flags (and the apiFlags) of the sub command calling the API many times.
+//The returned function applies the flags to the DB Scheduler and API client.
+func schedulerFlags(fs *flag.FlagSet) func(DBConn *SGAirTemp.DB) {
+	workers := fs.Int("workers", SGAirTemp.DefaultFetchWorkers, "Total concurrent API calls")
+	rate := fs.Float64("rate", SGAirTemp.DefaultRequestsPerSecond, "Maximum API calls per second, 0 for no limit")
+	applyAPI := apiFlags(fs)
+	return func(DBConn *SGAirTemp.DB) {
+		DBConn.Scheduler = SGAirTemp.NewFetchScheduler(*workers, *rate)
+		applyAPI(DBConn)
+	}
+}
+
+//dateRange - the validated dates from dateFrom until dateTo (included), only dateFrom if dateTo is empty.
+func dateRange(dateFrom, dateTo string) ([]string, error) {
+	validatedFrom, err := SGAirTemp.CheckInputDate(dateFrom)
+	if err != nil {
+		return nil, err
+	}
+	if len(dateTo) == 0 {
+		return []string{validatedFrom}, nil
+	}
+	validatedTo, err := SGAirTemp.CheckInputDate(dateTo)
+	if err != nil {
+		return nil, err
+	}
+	if validatedTo < validatedFrom {
+		return nil, fmt.Errorf("--to %v is before --date %v", validatedTo, validatedFrom)
+	}
+
+	dates := []string{}
+	d, _ := time.Parse("2006-01-02", validatedFrom)
+	for ; d.Format("2006-01-02") <= validatedTo; d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+	return dates, nil
+}
+
+//openDB - open the Sqlite database and make sure the tables are ready.
+func openDB(dbPath string) (*SGAirTemp.DB, error) {
+	DBConn, err := SGAirTemp.InitDBConn("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	//Apply the schema migrations not applied yet.
+	if err := DBConn.PrepareDBTable(); err != nil {
+		DBConn.Close()
+		return nil, err
+	}
+	return DBConn, nil
+}
+
+func runHelp(ctx context.Context, args []string) error {
+	printUsage(os.Stdout)
+	return nil
+}
+
+func runStations(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("stations")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+
+	return DBConn.PrintStations()
+}
+
+func runReadings(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("readings")
+	dateVal := fs.String("date", "", "Date of the readings (YYYY-MM-DD), empty for all dates")
+	timeVal := fs.String("time", "", "Time of the readings (HH:mm), empty for all times")
+	applyMetric := metricFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+
+	return DBConn.PrintTemperatureReading(*dateVal, *timeVal)
+}
+
+func runFetch(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("fetch")
+	dateVal := fs.String("date", "", "Date to fetch (YYYY-MM-DD), empty for today")
+	timeVal := fs.String("time", "", "Time to fetch (HH:mm), empty with --date for the FULL day, otherwise current time")
+	applyMetric := metricFlag(fs)
+	applyAPI := apiFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+	applyAPI(DBConn)
+
+	//Only Date is provided, get the FULL day of data.
+	if len(*dateVal) > 0 && len(*timeVal) == 0 {
+		validatedDate, err := SGAirTemp.CheckInputDate(*dateVal)
+		if err != nil {
+			return err
+		}
+		return DBConn.CallTemperatureAPIAndSave(ctx, validatedDate, "", false)
+	}
+	return DBConn.FetchAndSaveTemperatureData(ctx, *dateVal, *timeVal)
+}
+
+func runStats(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("please provide the statistic: day, month, all or fullday")
+	}
+	kind := args[0]
+	fs, dbPath := newFlagSet("stats " + kind)
+	dateVal := fs.String("date", "", "Date of the statistic (YYYY-MM-DD) for day and fullday")
+	monthVal := fs.String("month", "", "Month of the statistic (YYYY-MM) for month")
+	stationID := fs.String("station", "", "Station ID, empty for ALL Stations")
+	applyMetric := metricFlag(fs)
+	applyScheduler := schedulerFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch kind {
+	case "day", "fullday":
+		if len(*dateVal) == 0 {
+			return fmt.Errorf("stats %v needs --date", kind)
+		}
+	case "month":
+		if len(*monthVal) == 0 {
+			return errors.New("stats month needs --month")
+		}
+	case "all":
+	default:
+		return fmt.Errorf("unknown statistic '%v', please choose day, month, all or fullday", kind)
+	}
+
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+	applyScheduler(DBConn)
+
+	switch kind {
+	case "day":
+		err = DBConn.PrintOneDayStatistic(ctx, *dateVal, *stationID)
+	case "fullday":
+		err = DBConn.PrintOneFullDayStatistic(ctx, *dateVal, *stationID)
+	case "month":
+		err = DBConn.PrintOneMonthStatistic(ctx, *monthVal, *stationID)
+	case "all":
+		err = DBConn.PrintAllDataStatistic(*stationID)
+	}
+	fmt.Println()
+	return err
+}
+
+func runBackfill(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("backfill")
+	dateFrom := fs.String("from", SGAirTemp.EarliestDataAvail, "First date to fetch (YYYY-MM-DD)")
+	dateTo := fs.String("to", time.Now().AddDate(0, 0, -1).Format("2006-01-02"), "Last date to fetch (YYYY-MM-DD), default is yesterday")
+	retryEmpty := fs.Bool("retry-empty", false, "Fetch again the days the API had no data")
+	maxAttempts := fs.Int("max-attempts", 3, "Stop retrying the failed day after this total attempts, 0 for no limit")
+	statusOnly := fs.Bool("status", false, "Only print the status of the days in the range, without fetching")
+	applyMetric := metricFlag(fs)
+	applyScheduler := schedulerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+	applyScheduler(DBConn)
+
+	if *statusOnly == true {
+		jobs, err := DBConn.BackfillJobs(DBConn.Metric.Name, *dateFrom, *dateTo)
+		if err != nil {
+			return err
+		}
+		totalByStatus := map[SGAirTemp.JobStatus]int{}
+		for _, job := range jobs {
+			totalByStatus[job.Status]++
+			if job.Status == SGAirTemp.JobEmpty || job.Status == SGAirTemp.JobFailed {
+				fmt.Printf("%v | %-6s | attempts: %v | %v\n", job.Date, job.Status, job.Attempts, job.LastError)
+			}
+		}
+		fmt.Printf("\nDone: %v, Pending: %v, Empty: %v, Failed: %v\n", totalByStatus[SGAirTemp.JobDone], totalByStatus[SGAirTemp.JobPending], totalByStatus[SGAirTemp.JobEmpty], totalByStatus[SGAirTemp.JobFailed])
+		return nil
+	}
+
+	summary, err := DBConn.Backfill(ctx, SGAirTemp.BackfillOptions{
+		DateFrom:    *dateFrom,
+		DateTo:      *dateTo,
+		RetryEmpty:  *retryEmpty,
+		MaxAttempts: *maxAttempts,
+		Progress: func(job SGAirTemp.BackfillJob) {
+			fmt.Printf("%v | %-6s | %6v readings %v\n", job.Date, job.Status, job.TotalReadings, job.LastError)
+		},
+	})
+	fmt.Printf("\nDone: %v, Skipped (already done): %v, Empty: %v, Failed: %v\n", summary.Done, summary.Skipped, len(summary.EmptyDays), len(summary.FailedDays))
+	if len(summary.EmptyDays) > 0 {
+		fmt.Printf("The API had no data for: %v\n", strings.Join(summary.EmptyDays, ", "))
+	}
+	if len(summary.FailedDays) > 0 {
+		fmt.Printf("Failed, run the backfill again to retry: %v\n", strings.Join(summary.FailedDays, ", "))
+	}
+	return err
+}
+
+func runReplay(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("replay")
+	storeDir := fs.String("store", defaultStoreDir, "Directory of the raw API responses")
+	rebuild := fs.Bool("rebuild", false, "Delete all the readings first, so only the readings from --store are kept")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*storeDir); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+
+	summary, err := DBConn.Replay(SGAirTemp.NewResponseStore(*storeDir), SGAirTemp.ReplayOptions{
+		Rebuild: *rebuild,
+		Progress: func(stored SGAirTemp.StoredResponse, totalSaved int) {
+			fmt.Printf("%v | %6v readings | %v\n", stored.FetchedAt, totalSaved, stored.Query)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nReplayed %v responses (%v empty), %v readings saved\n", summary.Files, summary.EmptyFiles, summary.TotalReadings)
+	return nil
+}
+
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("please provide the migrate action: status, up or down")
+	}
+	action := args[0]
+	fs, dbPath := newFlagSet("migrate " + action)
+	target := fs.Int("to", -1, "Target schema version, default is the latest for up and one version before the current for down")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	//Open without PrepareDBTable, the migrations are controlled by this command.
+	DBConn, err := SGAirTemp.InitDBConn("sqlite3", *dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+
+	switch action {
+	case "status":
+		status, err := DBConn.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%7s | %-30s | %s\n", "Version", "Name", "Applied At")
+		fmt.Printf("%s\n", strings.Repeat("=", 65))
+		for _, st := range status {
+			appliedAt := st.AppliedAt
+			if len(appliedAt) == 0 {
+				appliedAt = "pending"
+			}
+			fmt.Printf("%7d | %-30s | %s\n", st.Version, st.Name, appliedAt)
+		}
+	case "up":
+		if *target < 0 {
+			*target = 0
+		}
+		applied, err := DBConn.MigrateUp(*target)
+		for _, m := range applied {
+			fmt.Printf("Applied  %04d_%v\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("The database schema is up to date.")
+		}
+	case "down":
+		if *target < 0 {
+			current, err := DBConn.SchemaVersion()
+			if err != nil {
+				return err
+			}
+			*target = current - 1
+		}
+		reverted, err := DBConn.MigrateDown(*target)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%v\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown migrate action '%v', please choose status, up or down", action)
+	}
+	return nil
+}
+
+//runInteractive - the numbered menu, every option will ask the input from the user.
+func runInteractive(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("interactive")
+	applyMetric := metricFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+
+	fmt.Println("Please choose:")
+	fmt.Println("1. Print Recorded Stations")
+	fmt.Println("2. Print Recorded Temperature Readings Order by Stations")
+	fmt.Println("3. Get the Temperature Recording based on User Inputted Date/Time")
+	fmt.Println("4. Get the 1 Day Temperature Statistic (Hourly Recording for 24 Hour)")
+	fmt.Println("5. Get the 1 Month Temperature Statistic (Hourly Recording for 24 Hours each day - max is Last Month)")
+	fmt.Println("6. Get the statistic from all the saved data")
+	fmt.Println("7. Get the statistic for 1 FULL day of data")
+
+	//Get the Input of Date from user.
+	inpChoiceValStr := SGAirTemp.GetUserInput("\nYour Choice: ")
+	inpChoiceValInt, _ := strconv.Atoi(inpChoiceValStr)
+
+	switch inpChoiceValInt {
+	case 1:
+		return DBConn.PrintStations()
+	case 2:
+		return DBConn.PrintTemperatureReading("", "")
+	case 3:
+		return DBConn.UserInputAndSaveTemperatureData(ctx)
+	case 4:
+		return DBConn.GetOneDayStatistic(ctx, "")
+	case 5:
+		return DBConn.GetOneMonthStatistic(ctx)
+	case 6:
+		return DBConn.GetAllDataStatistic()
+	case 7:
+		return DBConn.GetOneFullDayStatistic(ctx)
+	default:
+		fmt.Println("Please choose valid option.")
+	}
+	return nil
+}
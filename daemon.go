@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//defaultHeartbeatFile - the heartbeat of the daemon used when --heartbeat is not provided.
+const defaultHeartbeatFile = "sg-airtemp-heartbeat.json"
+
+//runDaemon - poll the latest readings every --interval until Ctrl+C or SIGTERM, serving the Prometheus metrics and the REST API on --addr.
+//With --check, only check the heartbeat written by the running daemon, for the monitoring.
+func runDaemon(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("daemon")
+	addr := fs.String("addr", "127.0.0.1:8000", "Address to serve /metrics and the REST API on, empty to disable")
+	interval := fs.Duration("interval", SGAirTemp.DefaultPollInterval, "Time between the polls of the realtime API")
+	metricNames := fs.String("metric", SGAirTemp.DefaultMetric.Name, "Comma separated metrics to poll: "+strings.Join(SGAirTemp.MetricNames(), ", "))
+	gapThreshold := fs.Duration("gap-threshold", SGAirTemp.DefaultGapThreshold, "Fetch the FULL days when the newest reading is older than this, ie: after a downtime")
+	maxGapDays := fs.Int("max-gap-days", SGAirTemp.DefaultMaxGapDays, "Maximum days fetched to fill a gap, the older days are left to the backfill command")
+	heartbeatFile := fs.String("heartbeat", defaultHeartbeatFile, "File the heartbeat is written to after every poll, empty to disable")
+	check := fs.Bool("check", false, "Print the heartbeat of the running daemon and fail if it is not fresh, without polling")
+	maxAge := fs.Duration("max-age", 10*time.Minute, "Maximum age of the heartbeat and the newest readings for --check")
+	applyAPI := apiFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *check == true {
+		heartbeat, err := SGAirTemp.ReadHeartbeat(*heartbeatFile)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		SGAirTemp.PrintHeartbeat(heartbeat, now)
+		return SGAirTemp.CheckHeartbeat(heartbeat, now, *maxAge)
+	}
+	metrics := []SGAirTemp.Metric{}
+	for _, name := range splitList(*metricNames) {
+		metric, err := SGAirTemp.MetricByName(name)
+		if err != nil {
+			return err
+		}
+		metrics = append(metrics, metric)
+	}
+
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	applyAPI(DBConn)
+
+	daemon := SGAirTemp.NewDaemon(DBConn, metrics...)
+	daemon.Interval = *interval
+	daemon.GapThreshold = *gapThreshold
+	daemon.MaxGapDays = *maxGapDays
+	daemon.HeartbeatFile = *heartbeatFile
+	daemon.Log = os.Stdout
+
+	serverErr := make(chan error, 1)
+	if len(*addr) > 0 {
+		listener, err := net.Listen("tcp", *addr)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: apiHandler(DBConn, daemon.Exporter), ReadHeaderTimeout: 10 * time.Second}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+		go func() {
+			if err := server.Serve(listener); err != nil && errors.Is(err, http.ErrServerClosed) == false {
+				serverErr <- err
+			}
+		}()
+		fmt.Printf("Metrics on http://%v/metrics and the REST API on http://%v\n", listener.Addr(), listener.Addr())
+	}
+
+	daemonCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case err := <-serverErr:
+			fmt.Fprintf(os.Stderr, "Server failed: %v\n", err)
+			cancel()
+		case <-daemonCtx.Done():
+		}
+	}()
+	fmt.Printf("Polling %v every %v\n", *metricNames, daemon.Interval)
+	if err := daemon.Run(daemonCtx); err != nil {
+		return err
+	}
+	fmt.Println("Stopped, the readings saved so far are kept")
+	return nil
+}
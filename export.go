@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//runExport - write the saved readings, or the statistic of every day/month, as CSV, JSON or NDJSON to stdout or --out.
+//The readings can also be written as Parquet files partitioned by the year and month to the --out directory,
+//the stations are written as GeoJSON with their latest reading and the statistic of --date.
+func runExport(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("please provide what to export: readings, stats or stations")
+	}
+	kind := args[0]
+	if kind != "readings" && kind != "stats" && kind != "stations" {
+		return fmt.Errorf("unknown export '%v', please choose readings, stats or stations", kind)
+	}
+	fs, dbPath := newFlagSet("export " + kind)
+	formatName := fs.String("format", string(SGAirTemp.ExportCSV), "Output format: csv, json, ndjson, parquet (readings only, --out is the directory) or geojson (stations only)")
+	dateFrom := fs.String("from", "", "First date (YYYY-MM-DD), empty for the first saved reading")
+	dateTo := fs.String("to", "", "Last date (YYYY-MM-DD), empty for the last saved reading")
+	stationIDs := fs.String("station", "", "Comma separated station IDs, empty for ALL Stations")
+	period := fs.String("period", string(SGAirTemp.PeriodDay), "Period of the statistic (stats): day or month")
+	hourly := fs.Bool("hourly", false, "Only the hourly readings (HH:00) for the statistic (stats), like the day and month statistic")
+	dateVal := fs.String("date", "", "Date of the daily statistic of the stations (YYYY-MM-DD), empty for the date of the newest reading")
+	outPath := fs.String("out", "", "Output file, empty for stdout (the output directory for parquet)")
+	applyMetric := metricFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	format, err := SGAirTemp.ExportFormatByName(*formatName)
+	if err != nil {
+		return err
+	}
+	if kind == "stations" {
+		//The stations are only exported as GeoJSON, the default csv format is not asked for.
+		formatSet := false
+		fs.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+		if formatSet == true && format != SGAirTemp.ExportGeoJSON {
+			return errors.New("the stations can only be exported as geojson")
+		}
+		format = SGAirTemp.ExportGeoJSON
+	}
+
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+
+	if format == SGAirTemp.ExportParquet {
+		if kind != "readings" {
+			return errors.New("only the readings can be exported as parquet")
+		}
+		if len(*outPath) == 0 {
+			return errors.New("please provide the output directory of the parquet files with --out")
+		}
+		partitions, err := DBConn.ExportParquet(ctx, *outPath, SGAirTemp.Filter{DateFrom: *dateFrom, DateTo: *dateTo, StationIDs: splitList(*stationIDs)})
+		total := 0
+		for _, partition := range partitions {
+			fmt.Fprintf(os.Stderr, "%v | %8v rows\n", partition.Path, partition.Rows)
+			total += partition.Rows
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %v rows of readings to %v parquet files\n", total, len(partitions))
+		return nil
+	}
+
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if len(*outPath) > 0 {
+		if file, err = os.Create(*outPath); err != nil {
+			return err
+		}
+		out = file
+	}
+
+	var total int
+	if kind == "stations" {
+		total, err = DBConn.WriteGeoJSON(out, SGAirTemp.GeoJSONQuery{Date: *dateVal, StationIDs: splitList(*stationIDs)})
+	} else if kind == "readings" {
+		total, err = DBConn.ExportReadings(ctx, out, format, SGAirTemp.Filter{DateFrom: *dateFrom, DateTo: *dateTo, StationIDs: splitList(*stationIDs)})
+	} else {
+		query := SGAirTemp.ExportStatisticsQuery{DateFrom: *dateFrom, DateTo: *dateTo, StationIDs: splitList(*stationIDs), Period: SGAirTemp.Period(*period), Granularity: SGAirTemp.GranularityMinute}
+		if *hourly == true {
+			query.Granularity = SGAirTemp.GranularityHourly
+		}
+		total, err = DBConn.ExportStatistics(ctx, out, format, query)
+	}
+	if file != nil {
+		//The error of the Close is the write failed at the end (ie: disk full), the file is incomplete.
+		if errClose := file.Close(); err == nil && errClose != nil {
+			err = fmt.Errorf("%v: %w", *outPath, errClose)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	//The summary is on stderr, so stdout only has the exported data.
+	fmt.Fprintf(os.Stderr, "Exported %v rows of %v\n", total, kind)
+	return nil
+}
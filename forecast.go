@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//runForecast - fetch the weather forecasts, and verify them against the observed temperature.
+func runForecast(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("please provide the forecast action: fetch or verify")
+	}
+	switch args[0] {
+	case "fetch":
+		return runForecastFetch(ctx, args[1:])
+	case "verify":
+		return runForecastVerify(args[1:])
+	}
+	return fmt.Errorf("unknown forecast action '%v', please choose fetch or verify", args[0])
+}
+
+func runForecastFetch(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("forecast fetch")
+	sourceName := fs.String("source", "all", "Forecast: 2-hour, 24-hour, 4-day or all")
+	dateVal := fs.String("date", "", "Date to fetch (YYYY-MM-DD), empty for today")
+	dateTo := fs.String("to", "", "Last date to fetch (YYYY-MM-DD) for every issuance of the days from --date, empty for only --date")
+	timeVal := fs.String("time", "", "Time to fetch (HH:mm) for the latest issuance at that time, empty for every issuance of the day")
+	applyAPI := apiFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	sources := SGAirTemp.ForecastSources()
+	if *sourceName != "all" {
+		source, err := SGAirTemp.ForecastSourceByName(*sourceName)
+		if err != nil {
+			return err
+		}
+		sources = []SGAirTemp.ForecastSource{source}
+	}
+	if len(*dateVal) == 0 {
+		*dateVal = time.Now().In(SGAirTemp.SGTLocation).Format("2006-01-02")
+	}
+	dates, err := dateRange(*dateVal, *dateTo)
+	if err != nil {
+		return err
+	}
+	if len(*timeVal) > 0 {
+		if len(dates) > 1 {
+			return errors.New("--time can not be used with --to")
+		}
+		validatedTime, err := SGAirTemp.CheckInputTime(*timeVal)
+		if err != nil {
+			return err
+		}
+		*timeVal = validatedTime
+	}
+
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	applyAPI(DBConn)
+
+	for _, d := range dates {
+		for _, source := range sources {
+			totalSaved, err := DBConn.CallForecastAPIAndSave(ctx, source, d, *timeVal)
+			if err != nil {
+				return fmt.Errorf("%v forecast %v: %w", source.Label, d, err)
+			}
+			fmt.Printf("%v | %-7s | %6v forecasts\n", d, source.Label, totalSaved)
+		}
+	}
+	return nil
+}
+
+func runForecastVerify(args []string) error {
+	fs, dbPath := newFlagSet("forecast verify")
+	sourceName := fs.String("source", "", "Forecast: 24-hour or 4-day, empty for both")
+	dateFrom := fs.String("from", "", "First date (YYYY-MM-DD) of the forecast valid period, empty for no bound")
+	dateTo := fs.String("to", "", "Last date (YYYY-MM-DD) of the forecast valid period, empty for no bound")
+	minReadings := fs.Int("min-readings", 1, "Skip the forecast with less observed readings in its valid period")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+
+	verifications, err := DBConn.VerifyForecasts(SGAirTemp.ForecastVerificationQuery{Source: *sourceName, DateFrom: *dateFrom, DateTo: *dateTo, MinReadings: *minReadings})
+	if err != nil {
+		return err
+	}
+	SGAirTemp.PrintForecastVerification(verifications)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//runImport - save the readings of the historical CSV files (.gz too, - for stdin), every file in its own transaction.
+//Ctrl+C stops the import, the file being imported is rolled back.
+//The invalid lines are skipped and printed, --errors writes all of them to a CSV file.
+func runImport(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("import")
+	dryRun := fs.Bool("dry-run", false, "Validate the files and print the summary without saving the readings")
+	errorsPath := fs.String("errors", "", "CSV file of every invalid line (line, error and its fields), empty to only print the first ones")
+	applyMetric := metricFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("please provide the CSV files to import")
+	}
+
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+
+	opts := SGAirTemp.ImportOptions{Metric: DBConn.Metric, DryRun: *dryRun}
+	if len(*errorsPath) > 0 {
+		file, err := os.Create(*errorsPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		opts.ErrorReport = file
+	}
+
+	total := SGAirTemp.ImportSummary{}
+	for _, path := range fs.Args() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		summary, err := importFile(ctx, DBConn, path, opts)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		printImportSummary(path, summary)
+		total.Lines += summary.Lines
+		total.Imported += summary.Imported
+		total.Duplicates += summary.Duplicates
+		total.Invalid += summary.Invalid
+	}
+
+	verb := "Imported"
+	if *dryRun == true {
+		verb = "Dry run, nothing saved, would import"
+	}
+	fmt.Printf("%v %v readings of %v lines (%v duplicates, %v invalid)\n", verb, total.Imported, total.Lines, total.Duplicates, total.Invalid)
+	return nil
+}
+
+//importFile - import one CSV file, gunzipped if its name ends with .gz.
+func importFile(ctx context.Context, DBConn *SGAirTemp.DB, path string, opts SGAirTemp.ImportOptions) (SGAirTemp.ImportSummary, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return SGAirTemp.ImportSummary{}, err
+		}
+		defer file.Close()
+		in = file
+	}
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return SGAirTemp.ImportSummary{}, err
+		}
+		defer gz.Close()
+		in = gz
+	}
+	return DBConn.ImportReadings(ctx, in, opts)
+}
+
+//printImportSummary - the counts of the file, its date range and the first invalid lines.
+func printImportSummary(path string, summary SGAirTemp.ImportSummary) {
+	fmt.Printf("%v | %v lines | %v imported | %v duplicates | %v invalid\n", path, summary.Lines, summary.Imported, summary.Duplicates, summary.Invalid)
+	if summary.Imported > 0 {
+		fmt.Printf("  Readings from %v to %v\n", summary.First.In(SGAirTemp.SGTLocation).Format("2006-01-02 15:04"), summary.Last.In(SGAirTemp.SGTLocation).Format("2006-01-02 15:04"))
+	}
+	for _, lineError := range summary.Errors {
+		fmt.Printf("  Line %v: %v\n", lineError.Line, lineError.Err)
+	}
+	if summary.Invalid > len(summary.Errors) {
+		fmt.Printf("  ... and %v more invalid lines\n", summary.Invalid-len(summary.Errors))
+	}
+}
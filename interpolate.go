@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//runInterpolate - estimate the reading at --lat/--lon from the stations with a location, or the grid of the bounding box with --grid.
+func runInterpolate(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("interpolate")
+	dateVal := fs.String("date", "", "Date of the readings (YYYY-MM-DD), empty with --time for today, both empty for the newest reading")
+	timeVal := fs.String("time", "", "Time of the readings (HH:mm) in SGT, empty with --date for the current time")
+	lat := fs.Float64("lat", 0, "Latitude of the estimate")
+	lon := fs.Float64("lon", 0, "Longitude of the estimate")
+	grid := fs.Bool("grid", false, "Estimate every cell of --bbox instead of --lat/--lon")
+	bbox := fs.String("bbox", "", "Bounding box of the grid: min latitude,min longitude,max latitude,max longitude, empty for Singapore")
+	cellSize := fs.Float64("cell", SGAirTemp.DefaultGridCellSize, "Cell size of the grid in degrees (0.01 is about 1.1 km)")
+	method := fs.String("method", string(SGAirTemp.InterpolationIDW), "Interpolation method: idw or kriging")
+	power := fs.Float64("power", SGAirTemp.DefaultIDWPower, "Power of the distance for idw, more than 0")
+	tolerance := fs.Duration("tolerance", SGAirTemp.DefaultSampleTolerance, "The reading of every station nearest to the time within the tolerance is used")
+	formatName := fs.String("format", string(SGAirTemp.GridCSV), "Grid format: csv, geojson or asc (ESRI ASCII raster)")
+	outPath := fs.String("out", "", "Grid output file, empty for stdout")
+	applyMetric := metricFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	//The library takes the zero power as the default, but --power 0 is a mistake of the user.
+	if *power <= 0 {
+		return fmt.Errorf("invalid --power %v, it must be more than 0 (default: %v)", *power, SGAirTemp.DefaultIDWPower)
+	}
+	format, err := SGAirTemp.GridFormatByName(*formatName)
+	if err != nil {
+		return err
+	}
+	box := SGAirTemp.SingaporeBoundingBox
+	if len(*bbox) > 0 {
+		if box, err = parseBoundingBox(*bbox); err != nil {
+			return err
+		}
+	}
+	if *grid == false && (*lat < -90 || *lat > 90 || *lon < -180 || *lon > 180 || (*lat == 0 && *lon == 0)) {
+		return errors.New("please provide the location with --lat and --lon, or --grid for the bounding box")
+	}
+
+	opts := SGAirTemp.InterpolationOptions{Method: SGAirTemp.InterpolationMethod(strings.ToLower(*method)), Power: *power, Tolerance: *tolerance}
+	if len(*dateVal) > 0 || len(*timeVal) > 0 {
+		validatedDate, err := SGAirTemp.CheckInputDate(*dateVal)
+		if err != nil {
+			return err
+		}
+		validatedTime, err := SGAirTemp.CheckInputTime(*timeVal)
+		if err != nil {
+			return err
+		}
+		opts.At, _ = time.ParseInLocation("2006-01-02 15:04", validatedDate+" "+validatedTime, SGAirTemp.SGTLocation)
+	}
+
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+	opts.Metric = DBConn.Metric.Name
+
+	interpolator, err := DBConn.NewInterpolator(opts)
+	if err != nil {
+		return err
+	}
+
+	if *grid == false {
+		estimate := interpolator.Estimate(*lat, *lon)
+		fmt.Printf("%v at %v, %v on %v (%v of %v stations): %.2f %v\n", interpolator.Metric.Label, *lat, *lon, interpolator.At.Format("2006-01-02 15:04"),
+			interpolator.Method, len(interpolator.Samples), estimate.Value, interpolator.Metric.Unit)
+		for _, weight := range estimate.Weights {
+			fmt.Printf("%-8v %-30v | %8.2f km | %6.2f %v at %v | weight %.4f\n", weight.StationID, weight.StationName, weight.DistanceKm,
+				weight.Value, interpolator.Metric.Unit, weight.Timestamp.Format("15:04"), weight.Weight)
+		}
+		return nil
+	}
+
+	result, err := interpolator.Grid(box, *cellSize)
+	if err != nil {
+		return err
+	}
+	var out io.Writer = os.Stdout
+	if len(*outPath) > 0 {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := result.Write(out, format); err != nil {
+		return err
+	}
+	//The summary is on stderr, so stdout only has the grid.
+	fmt.Fprintf(os.Stderr, "Interpolated %v x %v cells of %v on %v from %v stations (%v)\n", result.Rows, result.Cols, interpolator.Metric.Name,
+		interpolator.At.Format("2006-01-02 15:04"), len(interpolator.Samples), interpolator.Method)
+	return nil
+}
+
+//parseBoundingBox - the bounding box of "min latitude,min longitude,max latitude,max longitude".
+func parseBoundingBox(value string) (SGAirTemp.BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return SGAirTemp.BoundingBox{}, fmt.Errorf("invalid --bbox '%v', please use min latitude,min longitude,max latitude,max longitude", value)
+	}
+	coordinates := make([]float64, 4)
+	for i, part := range parts {
+		coordinate, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return SGAirTemp.BoundingBox{}, fmt.Errorf("invalid --bbox '%v', '%v' is not a number", value, part)
+		}
+		coordinates[i] = coordinate
+	}
+	return SGAirTemp.BoundingBox{MinLat: coordinates[0], MinLon: coordinates[1], MaxLat: coordinates[2], MaxLon: coordinates[3]}, nil
+}
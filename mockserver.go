@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//runMockServer - serve the fake data.gov.sg API until Ctrl+C, so the other commands can run offline with --api-url.
+func runMockServer(ctx context.Context, args []string) error {
+	defaults := SGAirTemp.DefaultMockOptions()
+	fs := flag.NewFlagSet("mockserver", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to listen on")
+	totalStations := fs.Int("stations", 0, "Total stations served (max 6), 0 for all")
+	emptyDates := fs.String("empty", strings.Join(defaults.EmptyDates, ","), "Comma separated dates (YYYY-MM-DD) with no data")
+	errorDates := fs.String("error", "", "Comma separated dates (YYYY-MM-DD) always answered with --error-status")
+	errorStatus := fs.Int("error-status", 500, "Status code of the --error dates")
+	rateLimitEvery := fs.Int("rate-limit-every", 0, "Answer every N-th request with 429 and Retry-After, 0 to disable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	handler := SGAirTemp.NewMockHandler(SGAirTemp.MockOptions{
+		Stations:       *totalStations,
+		EmptyDates:     splitList(*emptyDates),
+		ErrorDates:     splitList(*errorDates),
+		ErrorStatus:    *errorStatus,
+		RateLimitEvery: *rateLimitEvery,
+	})
+	mux.Handle("/v1/", http.StripPrefix("/v1", handler))
+	mux.Handle("/v2/", http.StripPrefix("/v2", handler))
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Mock data.gov.sg API listening, run the other commands with: --api-url http://%v/v1 --api-v2-url http://%v/v2\n", listener.Addr(), listener.Addr())
+	if err := server.Serve(listener); err != nil && errors.Is(err, http.ErrServerClosed) == false {
+		return err
+	}
+	return nil
+}
+
+//splitList - the comma separated values, without the empty value.
+func splitList(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
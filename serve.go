@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//runServe - serve the REST JSON API of the saved stations, readings and statistics until Ctrl+C.
+func runServe(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("serve")
+	addr := fs.String("addr", "127.0.0.1:8000", "Address to listen on")
+	applyMetric := metricFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	if err := applyMetric(DBConn); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: apiHandler(DBConn, SGAirTemp.NewExporter(DBConn)), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("SGAirTemp API listening on http://%v (OpenAPI document: http://%v/openapi.json)\n", listener.Addr(), listener.Addr())
+	if err := server.Serve(listener); err != nil && errors.Is(err, http.ErrServerClosed) == false {
+		return err
+	}
+	return nil
+}
+
+//apiHandler - the Prometheus metrics of the exporter on /metrics, and the REST API on the other paths.
+func apiHandler(DBConn *SGAirTemp.DB, exporter *SGAirTemp.Exporter) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	mux.Handle("/", SGAirTemp.NewServer(DBConn))
+	return mux
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/suryajap/SJGoLang/SGAirTemp"
+)
+
+//runWBGT - fetch the WBGT readings and heat stress, and print their statistic or the heat risk report.
+func runWBGT(ctx context.Context, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("please provide the wbgt action: fetch, stats or heatrisk")
+	}
+	switch args[0] {
+	case "fetch":
+		return runWBGTFetch(ctx, args[1:])
+	case "stats":
+		return runWBGTStats(args[1:])
+	case "heatrisk":
+		return runWBGTHeatRisk(args[1:])
+	}
+	return fmt.Errorf("unknown wbgt action '%v', please choose fetch, stats or heatrisk", args[0])
+}
+
+func runWBGTFetch(ctx context.Context, args []string) error {
+	fs, dbPath := newFlagSet("wbgt fetch")
+	dateVal := fs.String("date", "", "Date to fetch (YYYY-MM-DD), empty for today")
+	dateTo := fs.String("to", "", "Last date to fetch (YYYY-MM-DD) for the FULL days from --date, empty for only --date")
+	timeVal := fs.String("time", "", "Time to fetch (HH:mm), empty for the FULL day")
+	applyAPI := apiFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*dateVal) == 0 {
+		*dateVal = time.Now().In(SGAirTemp.SGTLocation).Format("2006-01-02")
+	}
+	dates, err := dateRange(*dateVal, *dateTo)
+	if err != nil {
+		return err
+	}
+	if len(*timeVal) > 0 {
+		if len(dates) > 1 {
+			return errors.New("--time can not be used with --to")
+		}
+		validatedTime, err := SGAirTemp.CheckInputTime(*timeVal)
+		if err != nil {
+			return err
+		}
+		*timeVal = validatedTime
+	}
+
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+	applyAPI(DBConn)
+
+	for _, d := range dates {
+		totalSaved, err := DBConn.CallWBGTAPIAndSave(ctx, d, *timeVal)
+		if err != nil {
+			return fmt.Errorf("WBGT %v: %w", d, err)
+		}
+		fmt.Printf("%v | WBGT | %6v readings\n", d, totalSaved)
+	}
+	return nil
+}
+
+func runWBGTStats(args []string) error {
+	fs, dbPath := newFlagSet("wbgt stats")
+	dateFrom := fs.String("from", "", "First date (YYYY-MM-DD), empty for no bound")
+	dateTo := fs.String("to", "", "Last date (YYYY-MM-DD), empty for no bound")
+	stationID := fs.String("station", "", "Station ID, empty for ALL Stations")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+
+	stats, err := DBConn.GetStatistics(SGAirTemp.StatisticQuery{DateFrom: *dateFrom, DateTo: *dateTo, StationID: *stationID, Granularity: SGAirTemp.GranularityMinute, Metric: SGAirTemp.MetricWBGT.Name})
+	if err != nil {
+		return err
+	}
+	SGAirTemp.PrintStatistics(stats)
+	fmt.Println()
+	return nil
+}
+
+func runWBGTHeatRisk(args []string) error {
+	fs, dbPath := newFlagSet("wbgt heatrisk")
+	dateFrom := fs.String("from", "", "First date (YYYY-MM-DD), empty for no bound")
+	dateTo := fs.String("to", "", "Last date (YYYY-MM-DD), empty for no bound")
+	stationID := fs.String("station", "", "Station ID, empty for ALL Stations")
+	categories := fs.String("category", "moderate,high", "Comma separated categories (low, moderate, high) to list the hours of, empty for all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	listCategories := splitList(*categories)
+	for _, category := range listCategories {
+		if category != SGAirTemp.HeatStressLow && category != SGAirTemp.HeatStressModerate && category != SGAirTemp.HeatStressHigh {
+			return fmt.Errorf("unknown heat stress category '%v', please choose %v", category, strings.Join(SGAirTemp.HeatStressCategories(), ", "))
+		}
+	}
+	DBConn, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer DBConn.Close()
+
+	report, err := DBConn.GetHeatRiskReport(SGAirTemp.HeatRiskQuery{DateFrom: *dateFrom, DateTo: *dateTo, StationID: *stationID})
+	if err != nil {
+		return err
+	}
+	SGAirTemp.PrintHeatRiskReport(report, listCategories)
+	return nil
+}